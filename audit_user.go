@@ -0,0 +1,211 @@
+package mongodbr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	FieldLastModificationTime = "lastModificationTime"
+	FieldLastModifierId       = "lastModifierId"
+)
+
+type currentUserKey struct{}
+
+// resolve the id of current user from context,
+// such as reading the claims which are set by web framework, return "" if no user
+type UserResolver func(ctx context.Context) string
+
+var (
+	_userResolverLock    sync.RWMutex
+	_defaultUserResolver UserResolver
+)
+
+// entity whose creator can be set
+type IUserCreatorSetter interface {
+	SetUserCreator(userId string)
+}
+
+// entity whose last modifier can be set
+type IUserModifierSetter interface {
+	SetUserModifier(userId string)
+}
+
+// return a context which carries the id of current user,
+// pass it by WithCtx of Mongodbr*Options to fill the audit fields
+func WithUser(ctx context.Context, userId string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, currentUserKey{}, userId)
+}
+
+// get user id which is set by WithUser
+func UserFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	userId, ok := ctx.Value(currentUserKey{}).(string)
+	return userId, ok
+}
+
+// set the global UserResolver, which is used when WithUser is not called and the repository has no UserResolver
+func SetDefaultUserResolver(resolver UserResolver) {
+	_userResolverLock.Lock()
+	defer _userResolverLock.Unlock()
+
+	_defaultUserResolver = resolver
+}
+
+// set UserResolver of repository
+func WithUserResolver(resolver UserResolver) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.userResolver = resolver
+	}
+}
+
+// get the id of current user, WithUser takes precedence over the UserResolver of repository,
+// and the UserResolver of repository takes precedence over the global one
+func (c *Configuration) CurrentUser(ctx context.Context) string {
+	if userId, ok := UserFromContext(ctx); ok {
+		return userId
+	}
+	if c != nil && c.userResolver != nil {
+		return c.userResolver(ctx)
+	}
+	_userResolverLock.RLock()
+	resolver := _defaultUserResolver
+	_userResolverLock.RUnlock()
+	if resolver != nil {
+		return resolver(ctx)
+	}
+	return ""
+}
+
+// fill creator with current user if creator is not set
+func (c *Configuration) fillCreationAudit(ctx context.Context, item interface{}) {
+	setter, ok := item.(IUserCreatorSetter)
+	if !ok {
+		return
+	}
+	if entity, ok := item.(ICreationAuditedEntity); ok && len(entity.GetCreatorId()) > 0 {
+		return
+	}
+	if userId := c.CurrentUser(ctx); len(userId) > 0 {
+		setter.SetUserCreator(userId)
+	}
+}
+
+// fill last modifier with current user
+func (c *Configuration) fillModificationAudit(ctx context.Context, item interface{}) {
+	setter, ok := item.(IUserModifierSetter)
+	if !ok {
+		return
+	}
+	if userId := c.CurrentUser(ctx); len(userId) > 0 {
+		setter.SetUserModifier(userId)
+	}
+}
+
+// add lastModificationTime and lastModifierId to the $set of the update documents
+// passed to UpdateOne, UpdateMany, UpdateOneById and FindOneAndUpdateWithId, such as for collections of AuditedEntity.
+// without it only the updates by entity such as FindOneAndUpdate are audited
+func WithUpdateAudit() RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.updateAudit = true
+	}
+}
+
+// add the modification audit fields to update when WithUpdateAudit is used,
+// the fields which are set by update are kept, an entity or a replacement document is returned unchanged
+func (r *MongoCol) auditUpdate(ctx context.Context, update interface{}) (interface{}, error) {
+	if !r.configuration.updateAudit || update == nil {
+		return update, nil
+	}
+	// entity is audited by hooks
+	switch update.(type) {
+	case IModificationTimeSetter, IUserModifierSetter:
+		return update, nil
+	}
+	set := bson.D{{Key: FieldLastModificationTime, Value: r.configuration.Now()}}
+	if userId := r.configuration.CurrentUser(ctx); len(userId) > 0 {
+		set = append(set, bson.E{Key: FieldLastModifierId, Value: userId})
+	}
+	if isUpdatePipeline(update) {
+		stageList, err := toStageList(update)
+		if err != nil {
+			return nil, err
+		}
+		return append(bson.A(stageList), bson.D{{Key: "$set", Value: set}}), nil
+	}
+	document, err := marshalDocument(r.bsonRegistry(), update)
+	if err != nil {
+		return nil, err
+	}
+	if len(document) <= 0 || !strings.HasPrefix(document[0].Key, "$") {
+		return update, nil
+	}
+	// skip the fields which are targeted by any update operator, such as $currentDate or $unset
+	auditSet := make(bson.D, 0, len(set))
+	for _, eachField := range set {
+		if !isUpdateTarget(document, eachField.Key) {
+			auditSet = append(auditSet, eachField)
+		}
+	}
+	if len(auditSet) <= 0 {
+		return document, nil
+	}
+	for index, eachElement := range document {
+		if eachElement.Key != "$set" {
+			continue
+		}
+		existing, ok := eachElement.Value.(bson.D)
+		if !ok {
+			return update, nil
+		}
+		document[index].Value = append(append(bson.D{}, existing...), auditSet...)
+		return document, nil
+	}
+	return append(document, bson.E{Key: "$set", Value: auditSet}), nil
+}
+
+// check if update is an aggregation pipeline, bson.D and bson.Raw are update documents
+func isUpdatePipeline(update interface{}) bool {
+	updateType := reflect.TypeOf(update)
+	for updateType.Kind() == reflect.Ptr {
+		updateType = updateType.Elem()
+	}
+	if updateType.Kind() != reflect.Slice && updateType.Kind() != reflect.Array {
+		return false
+	}
+	elemType := updateType.Elem()
+	return elemType != reflect.TypeOf(bson.E{}) && elemType.Kind() != reflect.Uint8
+}
+
+func isSameOrSubField(path string, field string) bool {
+	return path == field || strings.HasPrefix(path, field+".")
+}
+
+// check if field or its sub fields are targeted by any operator of update document
+func isUpdateTarget(update bson.D, field string) bool {
+	for _, eachOp := range update {
+		fields, ok := eachOp.Value.(bson.D)
+		if !ok {
+			continue
+		}
+		for _, eachField := range fields {
+			if isSameOrSubField(eachField.Key, field) {
+				return true
+			}
+			// the new name of $rename
+			if newName, ok := eachField.Value.(string); ok && eachOp.Key == "$rename" && isSameOrSubField(newName, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
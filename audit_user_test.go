@@ -0,0 +1,107 @@
+package mongodbr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/abmpio/mongodbr/builder"
+)
+
+func TestAuditUpdate(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	audit := bson.D{
+		{Key: FieldLastModificationTime, Value: now},
+		{Key: FieldLastModifierId, Value: "u1"},
+	}
+	cases := []struct {
+		name   string
+		update interface{}
+		want   interface{}
+	}{
+		{
+			name:   "$set is added",
+			update: bson.D{{Key: "$inc", Value: bson.D{{Key: "n", Value: int32(1)}}}},
+			want: bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "n", Value: int32(1)}}},
+				{Key: "$set", Value: audit},
+			},
+		},
+		{
+			name:   "$set is merged",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: int32(1)}}}},
+			want: bson.D{
+				{Key: "$set", Value: append(bson.D{{Key: "a", Value: int32(1)}}, audit...)},
+			},
+		},
+		{
+			name:   "field of $set is kept",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: FieldLastModifierId, Value: "u2"}}}},
+			want: bson.D{
+				{Key: "$set", Value: bson.D{{Key: FieldLastModifierId, Value: "u2"}, audit[0]}},
+			},
+		},
+		{
+			name:   "field of $currentDate is skipped",
+			update: builder.NewBsonBuilder().CurrentDate(FieldLastModificationTime).ToValue(),
+			want: bson.D{
+				{Key: "$currentDate", Value: bson.D{{Key: FieldLastModificationTime, Value: true}}},
+				{Key: "$set", Value: bson.D{audit[1]}},
+			},
+		},
+		{
+			name: "fields of $setOnInsert and $unset are skipped",
+			update: bson.D{
+				{Key: "$setOnInsert", Value: bson.D{{Key: FieldLastModificationTime, Value: now}}},
+				{Key: "$unset", Value: bson.D{{Key: FieldLastModifierId, Value: ""}}},
+			},
+			want: bson.D{
+				{Key: "$setOnInsert", Value: bson.D{{Key: FieldLastModificationTime, Value: bson.NewDateTimeFromTime(now)}}},
+				{Key: "$unset", Value: bson.D{{Key: FieldLastModifierId, Value: ""}}},
+			},
+		},
+		{
+			name:   "sub field and new name of $rename are skipped",
+			update: bson.D{{Key: "$rename", Value: bson.D{{Key: "a", Value: FieldLastModifierId}, {Key: FieldLastModificationTime + ".x", Value: "b"}}}},
+			want: bson.D{
+				{Key: "$rename", Value: bson.D{{Key: "a", Value: FieldLastModifierId}, {Key: FieldLastModificationTime + ".x", Value: "b"}}},
+			},
+		},
+		{
+			name:   "replacement document is unchanged",
+			update: bson.D{{Key: "a", Value: int32(1)}},
+			want:   bson.D{{Key: "a", Value: int32(1)}},
+		},
+		{
+			name:   "pipeline",
+			update: mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "a", Value: int32(1)}}}}},
+			want: bson.A{
+				bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: int32(1)}}}},
+				bson.D{{Key: "$set", Value: audit}},
+			},
+		},
+	}
+	configuration := NewConfiguration()
+	WithUpdateAudit()(configuration)
+	WithClock(NewFixedClock(now))(configuration)
+	WithUserResolver(func(ctx context.Context) string { return "u1" })(configuration)
+	col := &MongoCol{
+		configuration: configuration,
+		getCollection: func() *mongo.Collection { return nil },
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := col.auditUpdate(context.Background(), c.update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("update = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
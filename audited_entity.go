@@ -11,7 +11,19 @@ type ICreationAuditedEntity interface {
 	GetCreationTime() time.Time
 }
 
+// entity whose creation time can be set by the Clock of repository
+type ICreationTimeSetter interface {
+	SetCreationTime(t time.Time)
+}
+
+// entity whose last modification time can be set by the Clock of repository
+type IModificationTimeSetter interface {
+	SetLastModificationTime(t time.Time)
+}
+
 var _ ICreationAuditedEntity = (*CreationAuditedEntity)(nil)
+var _ IUserCreatorSetter = (*CreationAuditedEntity)(nil)
+var _ ICreationTimeSetter = (*CreationAuditedEntity)(nil)
 
 // can audit creation entity
 type CreationAuditedEntity struct {
@@ -26,6 +38,10 @@ func (p *CreationAuditedEntity) SetUserCreator(userId string) {
 	p.CreatorId = userId
 }
 
+func (p *CreationAuditedEntity) SetCreationTime(t time.Time) {
+	p.CreationTime = t
+}
+
 // #region ICreationAuditedEntity Members
 
 func (e *CreationAuditedEntity) GetCreatorId() string {
@@ -44,6 +60,8 @@ type IModificationEntity interface {
 }
 
 var _ IModificationEntity = (*AuditedEntity)(nil)
+var _ IUserModifierSetter = (*AuditedEntity)(nil)
+var _ IModificationTimeSetter = (*AuditedEntity)(nil)
 
 // auditable entity
 type AuditedEntity struct {
@@ -61,13 +79,20 @@ func (e AuditedEntity) GetObjectId() bson.ObjectID {
 func (entity *CreationAuditedEntity) BeforeCreate() {
 	entity.Entity.BeforeCreate()
 	if entity.CreationTime.IsZero() {
-		entity.CreationTime = time.Now()
+		entity.CreationTime = DefaultClock().Now()
 	}
 }
 
 func (entity *AuditedEntity) BeforeUpdate() {
-	now := time.Now()
-	entity.LastModificationTime = &now
+	entity.SetLastModificationTime(DefaultClock().Now())
+}
+
+func (e *AuditedEntity) SetUserModifier(userId string) {
+	e.LastModifierId = userId
+}
+
+func (e *AuditedEntity) SetLastModificationTime(t time.Time) {
+	e.LastModificationTime = &t
 }
 
 // #region IModificationEntity Members
@@ -77,7 +102,46 @@ func (e *AuditedEntity) GetLastModificationTime() *time.Time {
 }
 
 func (e *AuditedEntity) GetLastModifierId() string {
-	return e.CreatorId
+	return e.LastModifierId
+}
+
+// #endregion
+
+type ISoftDeleteEntity interface {
+	GetIsDeleted() bool
+}
+
+type IDeletionAuditedEntity interface {
+	ISoftDeleteEntity
+	GetDeletionTime() *time.Time
+	GetDeleterId() string
+}
+
+var _ IDeletionAuditedEntity = (*SoftDeleteAuditedEntity)(nil)
+
+// auditable entity which can be soft deleted
+type SoftDeleteAuditedEntity struct {
+	AuditedEntity `bson:",inline"`
+	// is deleted
+	IsDeleted bool `json:"isDeleted,omitempty" bson:"isDeleted"`
+	// deletion time
+	DeletionTime *time.Time `json:"deletionTime,omitempty" bson:"deletionTime"`
+	// delete user
+	DeleterId string `json:"deleterId,omitempty" bson:"deleterId"`
+}
+
+// #region IDeletionAuditedEntity Members
+
+func (e *SoftDeleteAuditedEntity) GetIsDeleted() bool {
+	return e.IsDeleted
+}
+
+func (e *SoftDeleteAuditedEntity) GetDeletionTime() *time.Time {
+	return e.DeletionTime
+}
+
+func (e *SoftDeleteAuditedEntity) GetDeleterId() string {
+	return e.DeleterId
 }
 
 // #endregion
@@ -0,0 +1,32 @@
+package mongodbr
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// exponential backoff with equal jitter for the attempt-th retry, attempt starts from 1,
+// the result is between half and all of initial*2^(attempt-1), which does not exceed max if max > 0,
+// and does not overflow for any attempt when max <= 0
+func ExponentialBackoff(initial time.Duration, max time.Duration, attempt int) time.Duration {
+	if initial <= 0 {
+		return 0
+	}
+	backoff := initial
+	for i := 1; i < attempt; i++ {
+		if max > 0 && backoff >= max {
+			break
+		}
+		if backoff > math.MaxInt64/2 {
+			backoff = math.MaxInt64
+			break
+		}
+		backoff *= 2
+	}
+	if max > 0 && backoff > max {
+		backoff = max
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
@@ -0,0 +1,39 @@
+package mongodbr
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	cases := []struct {
+		name    string
+		initial time.Duration
+		max     time.Duration
+		attempt int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "no initial", initial: 0, max: time.Second, attempt: 3},
+		{name: "attempt 0 is the first", initial: time.Second, attempt: 0, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "one nanosecond", initial: 1, attempt: 1, wantMin: 0, wantMax: 1},
+		{name: "doubled", initial: time.Second, attempt: 4, wantMin: 4 * time.Second, wantMax: 8 * time.Second},
+		{name: "capped by max", initial: time.Second, max: time.Minute, attempt: 64, wantMin: 30 * time.Second, wantMax: time.Minute},
+		{name: "last doubling without max", initial: time.Second, attempt: 34, wantMin: time.Second << 32, wantMax: time.Second << 33},
+		{name: "overflow without max at attempt 35", initial: time.Second, attempt: 35, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+		{name: "overflow without max at attempt 64", initial: time.Second, attempt: 64, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+		{name: "overflow without max at attempt 10000", initial: time.Nanosecond, attempt: 10000, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// jitter is random, so check the bounds several times
+			for i := 0; i < 100; i++ {
+				if got := ExponentialBackoff(c.initial, c.max, c.attempt); got < c.wantMin || got > c.wantMax {
+					t.Fatalf("ExponentialBackoff(%v, %v, %d) = %v, want between %v and %v",
+						c.initial, c.max, c.attempt, got, c.wantMin, c.wantMax)
+				}
+			}
+		})
+	}
+}
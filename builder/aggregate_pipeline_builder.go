@@ -12,22 +12,48 @@ const (
 
 	op_meta = "$meta"
 
+	op_stage_addFields       = "$addFields"
+	op_stage_unwind          = "$unwind"
+	op_stage_lookup          = "$lookup"
+	op_stage_facet           = "$facet"
+	op_stage_bucket          = "$bucket"
+	op_stage_limit           = "$limit"
+	op_stage_skip            = "$skip"
+	op_stage_count           = "$count"
+	op_stage_replaceRoot     = "$replaceRoot"
+	op_stage_sample          = "$sample"
+	op_stage_unionWith       = "$unionWith"
+	op_stage_setWindowFields = "$setWindowFields"
+	op_stage_out             = "$out"
+	op_stage_merge           = "$merge"
+
 	field_group_id = "_id"
 )
 
+// a pipeline which can be built to mongo.Pipeline
+type IPipeline interface {
+	Build() mongo.Pipeline
+}
+
+// ordered aggregate pipeline builder, every stage method appends a stage,
+// so the order of stages is the same as the order of calls
 type AggregatePipelineBuilder struct {
 	pipeline mongo.Pipeline
 
 	match bson.M
 	group bson.M
-	sort  bson.M
+	// index of the $sort stage created by WithSortField
+	sortIndex int
 }
 
+var _ IPipeline = (*AggregatePipelineBuilder)(nil)
+
 func NewAggregatePipelineBuilder() *AggregatePipelineBuilder {
 	builder := &AggregatePipelineBuilder{
 		pipeline: make(mongo.Pipeline, 0),
 
-		match: bson.M{},
+		match:     bson.M{},
+		sortIndex: -1,
 	}
 	builder.pipeline = append(builder.pipeline, bson.D{{
 		Key:   op_match,
@@ -36,6 +62,7 @@ func NewAggregatePipelineBuilder() *AggregatePipelineBuilder {
 	return builder
 }
 
+// merge filter into the first $match stage
 func (b *AggregatePipelineBuilder) MatchWith(filter bson.M) *AggregatePipelineBuilder {
 	if len(filter) <= 0 {
 		return b
@@ -64,24 +91,237 @@ func (b *AggregatePipelineBuilder) WithGroupField(fieldName string, value bson.M
 	return b
 }
 
-// append sort field
+// append sort field to the $sort stage created by the first call,
+// the order of sort fields is the same as the order of calls
 func (b *AggregatePipelineBuilder) WithSortField(fieldName string, isSortAsc bool, metaDataKeyword string) *AggregatePipelineBuilder {
 	b.ensureSortSetup()
-	if isSortAsc {
-		b.sort[fieldName] = 1
-	} else {
-		b.sort[fieldName] = -1
+	var sortValue interface{} = 1
+	if !isSortAsc {
+		sortValue = -1
 	}
 	if len(metaDataKeyword) > 0 {
-		b.sort[fieldName] = bson.E{
-			Key:   op_meta,
-			Value: metaDataKeyword,
-		}
+		sortValue = bson.D{{Key: op_meta, Value: metaDataKeyword}}
+	}
+	sort := b.pipeline[b.sortIndex][0].Value.(bson.D)
+	b.pipeline[b.sortIndex][0].Value = append(sort, bson.E{Key: fieldName, Value: sortValue})
+	return b
+}
+
+// #region stage members
+
+// append $match stage, filter can be bson.D,bson.M or IFilter
+func (b *AggregatePipelineBuilder) Match(filter interface{}) *AggregatePipelineBuilder {
+	if f, ok := filter.(IFilter); ok {
+		filter = f.Build()
+	}
+	return b.Stage(bson.D{{Key: op_match, Value: filter}})
+}
+
+// append $group stage, fields are the accumulator fields
+func (b *AggregatePipelineBuilder) Group(id interface{}, fields bson.D) *AggregatePipelineBuilder {
+	group := bson.D{{Key: field_group_id, Value: id}}
+	group = append(group, fields...)
+	return b.Stage(bson.D{{Key: op_group, Value: group}})
+}
+
+// append $sort stage
+func (b *AggregatePipelineBuilder) Sort(sort bson.D) *AggregatePipelineBuilder {
+	if len(sort) <= 0 {
+		return b
+	}
+	return b.Stage(bson.D{{Key: op_sort, Value: sort}})
+}
+
+// append $project stage
+func (b *AggregatePipelineBuilder) Project(projection interface{}) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_project, Value: projection}})
+}
+
+// append $addFields stage
+func (b *AggregatePipelineBuilder) AddFields(fields bson.D) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_addFields, Value: fields}})
+}
+
+// append $unset stage
+func (b *AggregatePipelineBuilder) Unset(fieldList ...string) *AggregatePipelineBuilder {
+	if len(fieldList) <= 0 {
+		return b
+	}
+	return b.Stage(bson.D{{Key: op_stage_unset, Value: fieldList}})
+}
+
+// append $unwind stage, path is the field name without $ prefix
+func (b *AggregatePipelineBuilder) Unwind(path string) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_unwind, Value: fieldPath(path)}})
+}
+
+// append $unwind stage with options, includeArrayIndex can be empty
+func (b *AggregatePipelineBuilder) UnwindWith(path string, includeArrayIndex string, preserveNullAndEmptyArrays bool) *AggregatePipelineBuilder {
+	unwind := bson.D{{Key: "path", Value: fieldPath(path)}}
+	if len(includeArrayIndex) > 0 {
+		unwind = append(unwind, bson.E{Key: "includeArrayIndex", Value: includeArrayIndex})
+	}
+	unwind = append(unwind, bson.E{Key: "preserveNullAndEmptyArrays", Value: preserveNullAndEmptyArrays})
+	return b.Stage(bson.D{{Key: op_stage_unwind, Value: unwind}})
+}
+
+// append $lookup stage with equality match
+func (b *AggregatePipelineBuilder) Lookup(from string, localField string, foreignField string, as string) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_lookup, Value: bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	}}})
+}
+
+// append $lookup stage with sub pipeline, let can be nil,
+// pipeline can be mongo.Pipeline or IPipeline
+func (b *AggregatePipelineBuilder) LookupPipeline(from string, let bson.D, pipeline interface{}, as string) *AggregatePipelineBuilder {
+	lookup := bson.D{{Key: "from", Value: from}}
+	if len(let) > 0 {
+		lookup = append(lookup, bson.E{Key: "let", Value: let})
+	}
+	lookup = append(lookup,
+		bson.E{Key: "pipeline", Value: buildPipeline(pipeline)},
+		bson.E{Key: "as", Value: as},
+	)
+	return b.Stage(bson.D{{Key: op_stage_lookup, Value: lookup}})
+}
+
+// add a sub pipeline to $facet stage,
+// consecutive Facet are merged into one $facet stage
+func (b *AggregatePipelineBuilder) Facet(name string, pipeline interface{}) *AggregatePipelineBuilder {
+	if len(name) <= 0 {
+		return b
+	}
+	e := bson.E{Key: name, Value: buildPipeline(pipeline)}
+	if last := lastStageOf(b.pipeline, op_stage_facet); last != nil {
+		last.Value = append(last.Value.(bson.D), e)
+		return b
+	}
+	return b.Stage(bson.D{{Key: op_stage_facet, Value: bson.D{e}}})
+}
+
+// append $bucket stage, defaultBucket and output can be nil
+func (b *AggregatePipelineBuilder) Bucket(groupBy interface{}, boundaries interface{}, defaultBucket interface{}, output bson.D) *AggregatePipelineBuilder {
+	bucket := bson.D{
+		{Key: "groupBy", Value: groupBy},
+		{Key: "boundaries", Value: boundaries},
+	}
+	if defaultBucket != nil {
+		bucket = append(bucket, bson.E{Key: "default", Value: defaultBucket})
+	}
+	if len(output) > 0 {
+		bucket = append(bucket, bson.E{Key: "output", Value: output})
+	}
+	return b.Stage(bson.D{{Key: op_stage_bucket, Value: bucket}})
+}
+
+// append $limit stage
+func (b *AggregatePipelineBuilder) Limit(limit int64) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_limit, Value: limit}})
+}
+
+// append $skip stage
+func (b *AggregatePipelineBuilder) Skip(skip int64) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_skip, Value: skip}})
+}
+
+// append $count stage, field is the output field name of count
+func (b *AggregatePipelineBuilder) Count(field string) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_count, Value: field}})
+}
+
+// append $replaceRoot stage
+func (b *AggregatePipelineBuilder) ReplaceRoot(newRoot interface{}) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_replaceRoot, Value: bson.D{{Key: "newRoot", Value: newRoot}}}})
+}
+
+// append $sample stage
+func (b *AggregatePipelineBuilder) Sample(size int64) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_sample, Value: bson.D{{Key: "size", Value: size}}}})
+}
+
+// append $unionWith stage, pipeline can be nil,mongo.Pipeline or IPipeline
+func (b *AggregatePipelineBuilder) UnionWith(collection string, pipeline interface{}) *AggregatePipelineBuilder {
+	if pipeline == nil {
+		return b.Stage(bson.D{{Key: op_stage_unionWith, Value: collection}})
+	}
+	return b.Stage(bson.D{{Key: op_stage_unionWith, Value: bson.D{
+		{Key: "coll", Value: collection},
+		{Key: "pipeline", Value: buildPipeline(pipeline)},
+	}}})
+}
+
+// append $setWindowFields stage, partitionBy and sortBy can be nil
+func (b *AggregatePipelineBuilder) SetWindowFields(partitionBy interface{}, sortBy bson.D, output bson.D) *AggregatePipelineBuilder {
+	windowFields := bson.D{}
+	if partitionBy != nil {
+		windowFields = append(windowFields, bson.E{Key: "partitionBy", Value: partitionBy})
 	}
+	if len(sortBy) > 0 {
+		windowFields = append(windowFields, bson.E{Key: "sortBy", Value: sortBy})
+	}
+	windowFields = append(windowFields, bson.E{Key: "output", Value: output})
+	return b.Stage(bson.D{{Key: op_stage_setWindowFields, Value: windowFields}})
+}
+
+// append $out stage, it must be the last stage
+func (b *AggregatePipelineBuilder) Out(collection string) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_out, Value: collection}})
+}
+
+// append $out stage to collection of another database, it must be the last stage
+func (b *AggregatePipelineBuilder) OutToDatabase(database string, collection string) *AggregatePipelineBuilder {
+	return b.Stage(bson.D{{Key: op_stage_out, Value: bson.D{
+		{Key: "db", Value: database},
+		{Key: "coll", Value: collection},
+	}}})
+}
+
+// append $merge stage, it must be the last stage
+func (b *AggregatePipelineBuilder) Merge(into interface{}, opts ...MergeStageOption) *AggregatePipelineBuilder {
+	mergeOptions := &MergeStageOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(mergeOptions)
+	}
+	merge := bson.D{{Key: "into", Value: into}}
+	if mergeOptions.On != nil {
+		merge = append(merge, bson.E{Key: "on", Value: mergeOptions.On})
+	}
+	if len(mergeOptions.Let) > 0 {
+		merge = append(merge, bson.E{Key: "let", Value: mergeOptions.Let})
+	}
+	if mergeOptions.WhenMatched != nil {
+		merge = append(merge, bson.E{Key: "whenMatched", Value: mergeOptions.WhenMatched})
+	}
+	if len(mergeOptions.WhenNotMatched) > 0 {
+		merge = append(merge, bson.E{Key: "whenNotMatched", Value: mergeOptions.WhenNotMatched})
+	}
+	return b.Stage(bson.D{{Key: op_stage_merge, Value: merge}})
+}
+
+// append stage as it is
+func (b *AggregatePipelineBuilder) Stage(stage bson.D) *AggregatePipelineBuilder {
+	if len(stage) <= 0 {
+		return b
+	}
+	b.pipeline = append(b.pipeline, stage)
 	return b
 }
 
+// #endregion
+
 func (b *AggregatePipelineBuilder) BuildAggregatePipeline() interface{} {
+	return b.Build()
+}
+
+// build the pipeline, the empty $match stage created by NewAggregatePipelineBuilder is removed
+func (b *AggregatePipelineBuilder) Build() mongo.Pipeline {
+	if len(b.pipeline) > 0 && len(b.match) <= 0 {
+		return b.pipeline[1:]
+	}
 	return b.pipeline
 }
 
@@ -97,12 +337,84 @@ func (b *AggregatePipelineBuilder) ensureGroupSetup() {
 }
 
 func (b *AggregatePipelineBuilder) ensureSortSetup() {
-	if b.sort != nil {
+	if b.sortIndex >= 0 {
 		return
 	}
-	b.sort = bson.M{}
 	b.pipeline = append(b.pipeline, bson.D{{
 		Key:   op_sort,
-		Value: b.sort,
+		Value: bson.D{},
 	}})
+	b.sortIndex = len(b.pipeline) - 1
+}
+
+// get the last stage of pipeline if it's key is stageKey and it's value is bson.D
+func lastStageOf(pipeline mongo.Pipeline, stageKey string) *bson.E {
+	if len(pipeline) <= 0 {
+		return nil
+	}
+	last := pipeline[len(pipeline)-1]
+	if len(last) != 1 || last[0].Key != stageKey {
+		return nil
+	}
+	if _, ok := last[0].Value.(bson.D); !ok {
+		return nil
+	}
+	return &last[0]
+}
+
+// options of $merge stage
+type MergeStageOptions struct {
+	// field or fields list that act as a unique identifier
+	On interface{}
+	// variables used by whenMatched pipeline
+	Let bson.D
+	// replace,keepExisting,merge,fail or a pipeline
+	WhenMatched interface{}
+	// insert,discard or fail
+	WhenNotMatched string
+}
+
+type MergeStageOption func(*MergeStageOptions)
+
+func MergeStageOptionWithOn(on interface{}) MergeStageOption {
+	return func(mso *MergeStageOptions) {
+		mso.On = on
+	}
+}
+
+func MergeStageOptionWithLet(let bson.D) MergeStageOption {
+	return func(mso *MergeStageOptions) {
+		mso.Let = let
+	}
+}
+
+func MergeStageOptionWithWhenMatched(whenMatched interface{}) MergeStageOption {
+	return func(mso *MergeStageOptions) {
+		mso.WhenMatched = whenMatched
+	}
+}
+
+func MergeStageOptionWithWhenNotMatched(whenNotMatched string) MergeStageOption {
+	return func(mso *MergeStageOptions) {
+		mso.WhenNotMatched = whenNotMatched
+	}
+}
+
+// convert pipeline to value of sub pipeline
+func buildPipeline(pipeline interface{}) interface{} {
+	switch p := pipeline.(type) {
+	case nil:
+		return mongo.Pipeline{}
+	case IPipeline:
+		return p.Build()
+	}
+	return pipeline
+}
+
+// add $ prefix to field name
+func fieldPath(path string) string {
+	if len(path) > 0 && path[0] == '$' {
+		return path
+	}
+	return "$" + path
 }
@@ -0,0 +1,122 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestAggregatePipelineBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *AggregatePipelineBuilder
+		want    mongo.Pipeline
+	}{
+		{
+			name:    "initial empty $match is dropped",
+			builder: NewAggregatePipelineBuilder(),
+			want:    mongo.Pipeline{},
+		},
+		{
+			name:    "initial empty $match is dropped before stages",
+			builder: NewAggregatePipelineBuilder().Limit(10).MatchWith(nil),
+			want:    mongo.Pipeline{{{Key: "$limit", Value: int64(10)}}},
+		},
+		{
+			name:    "MatchWith fills the initial $match",
+			builder: NewAggregatePipelineBuilder().Limit(10).MatchWith(bson.M{"a": 1}).MatchWith(bson.M{"b": 2}),
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"a": 1, "b": 2}}},
+				{{Key: "$limit", Value: int64(10)}},
+			},
+		},
+		{
+			name: "stages keep the order of calls",
+			builder: NewAggregatePipelineBuilder().
+				Match(Where("age").Gte(18)).
+				Unwind("tags").
+				Group("$tags", bson.D{{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}).
+				Sort(bson.D{{Key: "count", Value: -1}}).
+				Sort(nil).
+				Skip(5).
+				Limit(10).
+				Project(bson.D{{Key: "_id", Value: 0}}).
+				Unset().
+				Count("total"),
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}}}}}},
+				{{Key: "$unwind", Value: "$tags"}},
+				{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+				{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+				{{Key: "$skip", Value: int64(5)}},
+				{{Key: "$limit", Value: int64(10)}},
+				{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}}}},
+				{{Key: "$count", Value: "total"}},
+			},
+		},
+		{
+			name: "group and sort fields are added to their own stages",
+			builder: NewAggregatePipelineBuilder().
+				SetGroupId("$a").
+				WithSortField("b", true, "").
+				WithGroupField("n", bson.M{"$sum": 1}).
+				WithSortField("score", false, "textScore"),
+			want: mongo.Pipeline{
+				{{Key: "$group", Value: bson.M{"_id": "$a", "n": bson.M{"$sum": 1}}}},
+				{{Key: "$sort", Value: bson.D{{Key: "b", Value: 1}, {Key: "score", Value: bson.D{{Key: "$meta", Value: "textScore"}}}}}},
+			},
+		},
+		{
+			name: "consecutive facets are merged",
+			builder: NewAggregatePipelineBuilder().
+				Facet("count", NewAggregatePipelineBuilder().Count("n")).
+				Facet("items", mongo.Pipeline{{{Key: "$limit", Value: 1}}}).
+				Limit(1).
+				Facet("other", nil),
+			want: mongo.Pipeline{
+				{{Key: "$facet", Value: bson.D{
+					{Key: "count", Value: mongo.Pipeline{{{Key: "$count", Value: "n"}}}},
+					{Key: "items", Value: mongo.Pipeline{{{Key: "$limit", Value: 1}}}},
+				}}},
+				{{Key: "$limit", Value: int64(1)}},
+				{{Key: "$facet", Value: bson.D{{Key: "other", Value: mongo.Pipeline{}}}}},
+			},
+		},
+		{
+			name: "lookup and unwind with options",
+			builder: NewAggregatePipelineBuilder().
+				LookupPipeline("orders", nil, NewAggregatePipelineBuilder().Match(bson.D{{Key: "x", Value: 1}}), "orders").
+				UnwindWith("$orders", "", true),
+			want: mongo.Pipeline{
+				{{Key: "$lookup", Value: bson.D{
+					{Key: "from", Value: "orders"},
+					{Key: "pipeline", Value: mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "x", Value: 1}}}}}},
+					{Key: "as", Value: "orders"},
+				}}},
+				{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$orders"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+			},
+		},
+		{
+			name: "merge is the last stage",
+			builder: NewAggregatePipelineBuilder().
+				Stage(bson.D{}).
+				Merge("target", MergeStageOptionWithOn("_id"), MergeStageOptionWithWhenNotMatched("discard")),
+			want: mongo.Pipeline{
+				{{Key: "$merge", Value: bson.D{
+					{Key: "into", Value: "target"},
+					{Key: "on", Value: "_id"},
+					{Key: "whenNotMatched", Value: "discard"},
+				}}},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.builder.Build(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("pipeline = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ const (
 
 type BsonBuilder struct {
 	bson bson.M
+	// arrayFilters for filtered positional operator $[<identifier>]
+	arrayFilters []interface{}
 }
 
 func NewBsonBuilder() *BsonBuilder {
@@ -29,27 +31,26 @@ func (b *BsonBuilder) NewOrUpdateSet(v interface{}) *BsonBuilder {
 // get $set value
 // if not exist,then add $set value
 func (b *BsonBuilder) SetOrDefault() bson.M {
-	b.ensureBson()
-	setValue, ok := b.bson[setKey].(bson.M)
-	if ok && setValue != nil {
-		return setValue
-	}
-	setValue = bson.M{}
-	b.bson[setKey] = setValue
-	return setValue
+	return b.OperatorOrDefault(Op_Set())
 }
 
 // get $unset value
 // if not exist,then add $unset value
 func (b *BsonBuilder) UnsetOrDefault() bson.M {
+	return b.OperatorOrDefault(Op_Unset())
+}
+
+// get value of update operator op
+// if not exist,then add op value
+func (b *BsonBuilder) OperatorOrDefault(op *Op) bson.M {
 	b.ensureBson()
-	unsetValue, ok := (b.bson[unsetKey]).(bson.M)
-	if ok && unsetValue != nil {
-		return unsetValue
+	opValue, ok := b.bson[op.String()].(bson.M)
+	if ok && opValue != nil {
+		return opValue
 	}
-	unsetValue = bson.M{}
-	b.bson[unsetKey] = unsetValue
-	return unsetValue
+	opValue = bson.M{}
+	b.bson[op.String()] = opValue
+	return opValue
 }
 
 // append field value to $set field
@@ -86,6 +87,58 @@ func (b *BsonBuilder) AppendUnsetField(fieldList []string) *BsonBuilder {
 	return b
 }
 
+// append field value to $setOnInsert field
+func (b *BsonBuilder) AppendSetOnInsertField(fieldName string, fieldValue interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_SetOnInsert(), fieldName, fieldValue)
+}
+
+// increment field by v, v can be negative
+func (b *BsonBuilder) Inc(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_Inc(), fieldName, v)
+}
+
+// multiply field by v
+func (b *BsonBuilder) Mul(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_Mul(), fieldName, v)
+}
+
+// update field to v only if v is less than the existing value
+func (b *BsonBuilder) Min(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_Min(), fieldName, v)
+}
+
+// update field to v only if v is greater than the existing value
+func (b *BsonBuilder) Max(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_Max(), fieldName, v)
+}
+
+// rename field to newFieldName
+func (b *BsonBuilder) Rename(fieldName string, newFieldName string) *BsonBuilder {
+	if len(newFieldName) <= 0 {
+		return b
+	}
+	return b.appendOperatorField(Op_Rename(), fieldName, newFieldName)
+}
+
+// set field to current date as Date
+func (b *BsonBuilder) CurrentDate(fieldName string) *BsonBuilder {
+	return b.appendOperatorField(Op_CurrentDate(), fieldName, true)
+}
+
+// set field to current date as Timestamp
+func (b *BsonBuilder) CurrentTimestamp(fieldName string) *BsonBuilder {
+	return b.appendOperatorField(Op_CurrentDate(), fieldName, bson.M{"$type": "timestamp"})
+}
+
+func (b *BsonBuilder) appendOperatorField(op *Op, fieldName string, fieldValue interface{}) *BsonBuilder {
+	if len(fieldName) <= 0 {
+		return b
+	}
+	opValue := b.OperatorOrDefault(op)
+	opValue[fieldName] = fieldValue
+	return b
+}
+
 // 构建一个$set类型的值，用于设置字段值
 func NewOrUpdateSetBsonBuilder(v interface{}) *BsonBuilder {
 	b := NewBsonBuilder()
@@ -115,3 +168,8 @@ func (b *BsonBuilder) ensureBson() *BsonBuilder {
 func (b *BsonBuilder) ToValue() bson.M {
 	return b.bson
 }
+
+// check if there is no update operator
+func (b *BsonBuilder) IsEmpty() bool {
+	return len(b.bson) <= 0
+}
@@ -0,0 +1,158 @@
+package builder
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	positionalFirst = "$"
+	positionalAll   = "$[]"
+)
+
+// modifiers of $push with $each
+type PushModifiers struct {
+	Position *int
+	Slice    *int
+	Sort     interface{}
+}
+
+type PushModifierOption func(*PushModifiers)
+
+// insert the elements at position
+func PushModifierWithPosition(position int) PushModifierOption {
+	return func(pm *PushModifiers) {
+		pm.Position = &position
+	}
+}
+
+// limit the array size after push, negative slice keeps the last elements
+func PushModifierWithSlice(slice int) PushModifierOption {
+	return func(pm *PushModifiers) {
+		pm.Slice = &slice
+	}
+}
+
+// sort the array after push, sort can be 1,-1 or a sort document such as bson.D{{Key: "score", Value: -1}}
+func PushModifierWithSort(sort interface{}) PushModifierOption {
+	return func(pm *PushModifiers) {
+		pm.Sort = sort
+	}
+}
+
+// append v to array field
+func (b *BsonBuilder) Push(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_Push(), fieldName, v)
+}
+
+// append every element of values to array field, values should be a slice
+func (b *BsonBuilder) PushEach(fieldName string, values interface{}, opts ...PushModifierOption) *BsonBuilder {
+	modifiers := &PushModifiers{}
+	for _, eachOpt := range opts {
+		eachOpt(modifiers)
+	}
+	value := bson.D{{Key: Op_Each().String(), Value: values}}
+	if modifiers.Position != nil {
+		value = append(value, bson.E{Key: Op_Position().String(), Value: *modifiers.Position})
+	}
+	if modifiers.Slice != nil {
+		value = append(value, bson.E{Key: Op_Slice().String(), Value: *modifiers.Slice})
+	}
+	if modifiers.Sort != nil {
+		value = append(value, bson.E{Key: Op_Sort().String(), Value: modifiers.Sort})
+	}
+	return b.appendOperatorField(Op_Push(), fieldName, value)
+}
+
+// add v to array field only if it does not exist
+func (b *BsonBuilder) AddToSet(fieldName string, v interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_AddToSet(), fieldName, v)
+}
+
+// add every element of values to array field only if it does not exist, values should be a slice
+func (b *BsonBuilder) AddToSetEach(fieldName string, values interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_AddToSet(), fieldName, bson.D{{Key: Op_Each().String(), Value: values}})
+}
+
+// remove the first element of array field
+func (b *BsonBuilder) PopFirst(fieldName string) *BsonBuilder {
+	return b.appendOperatorField(Op_Pop(), fieldName, -1)
+}
+
+// remove the last element of array field
+func (b *BsonBuilder) PopLast(fieldName string) *BsonBuilder {
+	return b.appendOperatorField(Op_Pop(), fieldName, 1)
+}
+
+// remove all elements matched condition from array field,
+// condition can be a value, a query document or an IFilter
+func (b *BsonBuilder) Pull(fieldName string, condition interface{}) *BsonBuilder {
+	if filter, ok := condition.(IFilter); ok {
+		condition = filter.Build()
+	}
+	return b.appendOperatorField(Op_Pull(), fieldName, condition)
+}
+
+// remove all elements equal to any of values from array field, values should be a slice
+func (b *BsonBuilder) PullAll(fieldName string, values interface{}) *BsonBuilder {
+	return b.appendOperatorField(Op_PullAll(), fieldName, values)
+}
+
+// append array filter for identifier of $[<identifier>],
+// the field names of filter are prefixed with identifier,
+// e.g. AppendArrayFilter("elem", Where("grade").Gte(85)) => {"elem.grade": {"$gte": 85}},
+// AppendArrayFilter("elem", Cond().Gte(85)) => {"elem": {"$gte": 85}}
+func (b *BsonBuilder) AppendArrayFilter(identifier string, filter IFilter) *BsonBuilder {
+	if len(identifier) <= 0 || filter == nil {
+		return b
+	}
+	built := filter.Build()
+	if isOperatorDocument(built) {
+		return b.AppendRawArrayFilter(bson.D{{Key: identifier, Value: built}})
+	}
+	arrayFilter := make(bson.D, 0, len(built))
+	for _, e := range built {
+		if !strings.HasPrefix(e.Key, "$") {
+			e.Key = identifier + "." + e.Key
+		}
+		arrayFilter = append(arrayFilter, e)
+	}
+	return b.AppendRawArrayFilter(arrayFilter)
+}
+
+// append array filter as it is
+func (b *BsonBuilder) AppendRawArrayFilter(filter interface{}) *BsonBuilder {
+	if filter == nil {
+		return b
+	}
+	b.arrayFilters = append(b.arrayFilters, filter)
+	return b
+}
+
+// get arrayFilters, pass it to update by MongodbrUpdateOptionWithArrayFilters
+func (b *BsonBuilder) ArrayFilters() []interface{} {
+	return b.arrayFilters
+}
+
+// path of the first array element matched the query, such as items.$.name
+func PositionalFirst(arrayField string, subFields ...string) string {
+	return positionalPath(arrayField, positionalFirst, subFields...)
+}
+
+// path of all array elements, such as items.$[].name
+func PositionalAll(arrayField string, subFields ...string) string {
+	return positionalPath(arrayField, positionalAll, subFields...)
+}
+
+// path of array elements matched the arrayFilters of identifier, such as items.$[elem].name
+func PositionalFiltered(arrayField string, identifier string, subFields ...string) string {
+	return positionalPath(arrayField, "$["+identifier+"]", subFields...)
+}
+
+func positionalPath(arrayField string, positional string, subFields ...string) string {
+	parts := make([]string, 0, len(subFields)+2)
+	parts = append(parts, arrayField, positional)
+	parts = append(parts, subFields...)
+	return strings.Join(parts, ".")
+}
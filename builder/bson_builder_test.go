@@ -0,0 +1,193 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestBsonBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *BsonBuilder
+		want    bson.M
+	}{
+		{
+			name:    "empty",
+			builder: NewBsonBuilder(),
+			want:    bson.M{},
+		},
+		{
+			name:    "operators",
+			builder: NewBsonBuilder().AppendSetField("a", 1).AppendUnsetField([]string{"b"}).Inc("c", -1).Max("d", 5).Rename("e", "f").CurrentDate("g"),
+			want: bson.M{
+				"$set":         bson.M{"a": 1},
+				"$unset":       bson.M{"b": ""},
+				"$inc":         bson.M{"c": -1},
+				"$max":         bson.M{"d": 5},
+				"$rename":      bson.M{"e": "f"},
+				"$currentDate": bson.M{"g": true},
+			},
+		},
+		{
+			name:    "empty field names are ignored",
+			builder: NewBsonBuilder().AppendSetField("", 1).Inc("", 1).Rename("a", ""),
+			want:    bson.M{},
+		},
+		{
+			name:    "push",
+			builder: NewBsonBuilder().Push("tags", "a"),
+			want:    bson.M{"$push": bson.M{"tags": "a"}},
+		},
+		{
+			name:    "push each without modifiers",
+			builder: NewBsonBuilder().PushEach("tags", bson.A{"a", "b"}),
+			want:    bson.M{"$push": bson.M{"tags": bson.D{{Key: "$each", Value: bson.A{"a", "b"}}}}},
+		},
+		{
+			name: "push each with modifiers in mongodb order",
+			builder: NewBsonBuilder().PushEach("scores", bson.A{1, 2},
+				PushModifierWithSort(bson.D{{Key: "score", Value: -1}}),
+				PushModifierWithSlice(-5),
+				PushModifierWithPosition(0)),
+			want: bson.M{"$push": bson.M{"scores": bson.D{
+				{Key: "$each", Value: bson.A{1, 2}},
+				{Key: "$position", Value: 0},
+				{Key: "$slice", Value: -5},
+				{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}}},
+			}}},
+		},
+		{
+			name:    "add to set each",
+			builder: NewBsonBuilder().AddToSetEach("tags", bson.A{"a"}).PopFirst("queue"),
+			want: bson.M{
+				"$addToSet": bson.M{"tags": bson.D{{Key: "$each", Value: bson.A{"a"}}}},
+				"$pop":      bson.M{"queue": -1},
+			},
+		},
+		{
+			name:    "pull with filter",
+			builder: NewBsonBuilder().Pull("items", Where("qty").Lte(0)).PullAll("tags", bson.A{"x"}),
+			want: bson.M{
+				"$pull":    bson.M{"items": bson.D{{Key: "qty", Value: bson.D{{Key: "$lte", Value: 0}}}}},
+				"$pullAll": bson.M{"tags": bson.A{"x"}},
+			},
+		},
+		{
+			name:    "set by filtered positional path",
+			builder: NewBsonBuilder().AppendSetField(PositionalFiltered("grades", "elem", "mean"), 100),
+			want:    bson.M{"$set": bson.M{"grades.$[elem].mean": 100}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.builder.ToValue(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("update = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBsonBuilderArrayFilters(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *BsonBuilder
+		want    []interface{}
+	}{
+		{
+			name:    "no array filters",
+			builder: NewBsonBuilder(),
+			want:    nil,
+		},
+		{
+			name:    "fields are prefixed with identifier",
+			builder: NewBsonBuilder().AppendArrayFilter("elem", Where("grade").Gte(85).Or(Where("std").Lt(5))),
+			want: []interface{}{bson.D{
+				{Key: "elem.grade", Value: bson.D{{Key: "$gte", Value: 85}}},
+				{Key: "$or", Value: bson.A{bson.D{{Key: "std", Value: bson.D{{Key: "$lt", Value: 5}}}}}},
+			}},
+		},
+		{
+			name:    "operator only condition",
+			builder: NewBsonBuilder().AppendArrayFilter("x", Cond().Gte(100)),
+			want:    []interface{}{bson.D{{Key: "x", Value: bson.D{{Key: "$gte", Value: 100}}}}},
+		},
+		{
+			name: "raw array filters are passed through in order",
+			builder: NewBsonBuilder().
+				AppendRawArrayFilter(bson.M{"a.b": 1}).
+				AppendArrayFilter("", Cond().Eq(1)).
+				AppendArrayFilter("c", nil).
+				AppendRawArrayFilter(nil).
+				AppendRawArrayFilter(bson.D{{Key: "c", Value: 2}}),
+			want: []interface{}{bson.M{"a.b": 1}, bson.D{{Key: "c", Value: 2}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.builder.ArrayFilters(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("arrayFilters = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPositionalPath(t *testing.T) {
+	cases := map[string]string{
+		PositionalFirst("items"):                   "items.$",
+		PositionalFirst("items", "name"):           "items.$.name",
+		PositionalAll("items", "a", "b"):           "items.$[].a.b",
+		PositionalFiltered("items", "elem", "qty"): "items.$[elem].qty",
+	}
+	for got, want := range cases {
+		if got != want {
+			t.Errorf("path = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestUpdatePipelineBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *UpdatePipelineBuilder
+		want    mongo.Pipeline
+	}{
+		{
+			name:    "empty",
+			builder: NewUpdatePipelineBuilder(),
+			want:    mongo.Pipeline{},
+		},
+		{
+			name:    "consecutive set are merged",
+			builder: NewUpdatePipelineBuilder().Set("a", 1).Set("b", "$a").Unset("c").Set("d", 2),
+			want: mongo.Pipeline{
+				{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: "$a"}}}},
+				{{Key: "$unset", Value: []string{"c"}}},
+				{{Key: "$set", Value: bson.D{{Key: "d", Value: 2}}}},
+			},
+		},
+		{
+			name: "stages keep the order of calls",
+			builder: NewUpdatePipelineBuilder().
+				ReplaceWith("$doc").
+				Project(bson.D{{Key: "a", Value: 1}}).
+				Stage(bson.D{}).
+				Unset().
+				Stage(bson.D{{Key: "$addFields", Value: bson.D{{Key: "b", Value: 1}}}}),
+			want: mongo.Pipeline{
+				{{Key: "$replaceWith", Value: "$doc"}},
+				{{Key: "$project", Value: bson.D{{Key: "a", Value: 1}}}},
+				{{Key: "$addFields", Value: bson.D{{Key: "b", Value: 1}}}},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.builder.Build(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("pipeline = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package builder
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// a filter which can be built to bson.D
+type IFilter interface {
+	Build() bson.D
+}
+
+// fluent filter builder, the conditions are joined with implicit and,
+// the output is bson.D so key order is the same as the order of calls
+//
+//	builder.Where("age").Gte(18).Lt(60).
+//		Where("tags").All([]string{"a", "b"}).
+//		Or(builder.Where("vip").Eq(true), builder.Where("score").Not().Lt(80))
+type FilterBuilder struct {
+	elements bson.D
+}
+
+// condition of one field, every operator method appends an operator to the field
+type FieldCondition struct {
+	parent *FilterBuilder
+	field  string
+	not    bool
+}
+
+var _ IFilter = (*FilterBuilder)(nil)
+var _ IFilter = (*FieldCondition)(nil)
+
+func NewFilterBuilder() *FilterBuilder {
+	return &FilterBuilder{
+		elements: bson.D{},
+	}
+}
+
+// start a new filter with condition of field
+func Where(field string) *FieldCondition {
+	return NewFilterBuilder().Where(field)
+}
+
+// start a operator only condition without field,
+// used as the value of $elemMatch for array of scalar values,
+// e.g. Where("scores").ElemMatch(Cond().Gte(80).Lt(85))
+func Cond() *FieldCondition {
+	return NewFilterBuilder().Where("")
+}
+
+// new filter which matches all of filters
+func And(filters ...IFilter) *FilterBuilder {
+	return NewFilterBuilder().And(filters...)
+}
+
+// new filter which matches any of filters
+func Or(filters ...IFilter) *FilterBuilder {
+	return NewFilterBuilder().Or(filters...)
+}
+
+// new filter which matches none of filters
+func Nor(filters ...IFilter) *FilterBuilder {
+	return NewFilterBuilder().Nor(filters...)
+}
+
+// #region FilterBuilder Members
+
+// append a condition of field
+func (b *FilterBuilder) Where(field string) *FieldCondition {
+	return &FieldCondition{
+		parent: b,
+		field:  field,
+	}
+}
+
+// append field:value as it is
+func (b *FilterBuilder) Field(field string, value interface{}) *FilterBuilder {
+	b.elements = append(b.elements, bson.E{Key: field, Value: value})
+	return b
+}
+
+// append $and clause, filters are appended to the existing $and clause if there is one
+func (b *FilterBuilder) And(filters ...IFilter) *FilterBuilder {
+	return b.appendLogical(Op_And(), filters...)
+}
+
+// append $or clause, the builder matches (existing conditions) and (any of filters)
+func (b *FilterBuilder) Or(filters ...IFilter) *FilterBuilder {
+	return b.appendLogical(Op_Or(), filters...)
+}
+
+// append $nor clause, the builder matches (existing conditions) and (none of filters)
+func (b *FilterBuilder) Nor(filters ...IFilter) *FilterBuilder {
+	return b.appendLogical(Op_Nor(), filters...)
+}
+
+// build filter as bson.D
+func (b *FilterBuilder) Build() bson.D {
+	if b.elements == nil {
+		return bson.D{}
+	}
+	return b.elements
+}
+
+// #endregion
+
+// #region FieldCondition Members
+
+// negate the next operator with $not
+func (c *FieldCondition) Not() *FieldCondition {
+	c.not = true
+	return c
+}
+
+func (c *FieldCondition) Eq(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Eq(), v)
+}
+
+func (c *FieldCondition) Ne(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Ne(), v)
+}
+
+func (c *FieldCondition) Gt(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Gt(), v)
+}
+
+func (c *FieldCondition) Gte(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Gte(), v)
+}
+
+func (c *FieldCondition) Lt(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Lt(), v)
+}
+
+func (c *FieldCondition) Lte(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Lte(), v)
+}
+
+// v should be a slice
+func (c *FieldCondition) In(v interface{}) *FieldCondition {
+	return c.appendOp(Op_In(), v)
+}
+
+// v should be a slice
+func (c *FieldCondition) Nin(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Nin(), v)
+}
+
+// $regex with options, options can be empty
+func (c *FieldCondition) Regex(pattern string, options string) *FieldCondition {
+	if len(options) <= 0 {
+		return c.appendOp(Op_Regex(), pattern)
+	}
+	return c.appendOps(bson.E{Key: Op_Regex().String(), Value: pattern},
+		bson.E{Key: Op_Options().String(), Value: options})
+}
+
+func (c *FieldCondition) Exists(exists bool) *FieldCondition {
+	return c.appendOp(Op_Exists(), exists)
+}
+
+// v can be type number or alias,such as "string", 2
+func (c *FieldCondition) Type(v interface{}) *FieldCondition {
+	return c.appendOp(Op_Type(), v)
+}
+
+// v should be a slice
+func (c *FieldCondition) All(v interface{}) *FieldCondition {
+	return c.appendOp(Op_All(), v)
+}
+
+func (c *FieldCondition) Size(size int) *FieldCondition {
+	return c.appendOp(Op_Size(), size)
+}
+
+// match array field with at least one element matched filter
+func (c *FieldCondition) ElemMatch(filter IFilter) *FieldCondition {
+	return c.appendOp(Op_ElemMatch(), buildFilter(filter))
+}
+
+// append a condition of another field to the same filter
+func (c *FieldCondition) Where(field string) *FieldCondition {
+	return c.parent.Where(field)
+}
+
+// see FilterBuilder.And
+func (c *FieldCondition) And(filters ...IFilter) *FilterBuilder {
+	return c.parent.And(filters...)
+}
+
+// see FilterBuilder.Or
+func (c *FieldCondition) Or(filters ...IFilter) *FilterBuilder {
+	return c.parent.Or(filters...)
+}
+
+// see FilterBuilder.Nor
+func (c *FieldCondition) Nor(filters ...IFilter) *FilterBuilder {
+	return c.parent.Nor(filters...)
+}
+
+// get the filter builder which this condition belongs to
+func (c *FieldCondition) Builder() *FilterBuilder {
+	return c.parent
+}
+
+// build the whole filter as bson.D
+func (c *FieldCondition) Build() bson.D {
+	return c.parent.Build()
+}
+
+// #endregion
+
+func (c *FieldCondition) appendOp(op *Op, v interface{}) *FieldCondition {
+	return c.appendOps(bson.E{Key: op.String(), Value: v})
+}
+
+func (c *FieldCondition) appendOps(opList ...bson.E) *FieldCondition {
+	not := c.not
+	c.not = false
+
+	// operator only condition, append to the root document
+	if len(c.field) <= 0 {
+		c.parent.elements = appendOperators(c.parent.elements, not, opList)
+		return c
+	}
+	// merge into the existing operator document of the same field
+	for i := range c.parent.elements {
+		if c.parent.elements[i].Key != c.field {
+			continue
+		}
+		operators, ok := c.parent.elements[i].Value.(bson.D)
+		if !ok || !isOperatorDocument(operators) {
+			continue
+		}
+		if not && hasKey(operators, Op_Not().String()) {
+			// a field can only have one $not, so add another negation as $and clause
+			c.parent.And(NewFilterBuilder().Field(c.field, appendOperators(bson.D{}, not, opList)))
+			return c
+		}
+		c.parent.elements[i].Value = appendOperators(operators, not, opList)
+		return c
+	}
+	c.parent.elements = append(c.parent.elements, bson.E{
+		Key:   c.field,
+		Value: appendOperators(bson.D{}, not, opList),
+	})
+	return c
+}
+
+// append operators to d, wrap them with $not if not is true
+func appendOperators(d bson.D, not bool, opList []bson.E) bson.D {
+	if !not {
+		return append(d, opList...)
+	}
+	return append(d, bson.E{Key: Op_Not().String(), Value: append(bson.D{}, opList...)})
+}
+
+func (b *FilterBuilder) appendLogical(op *Op, filters ...IFilter) *FilterBuilder {
+	clauseList := bson.A{}
+	for _, eachFilter := range filters {
+		if eachFilter == nil {
+			continue
+		}
+		clauseList = append(clauseList, buildFilter(eachFilter))
+	}
+	if len(clauseList) <= 0 {
+		return b
+	}
+	if op == Op_And() {
+		for i := range b.elements {
+			if b.elements[i].Key != op.String() {
+				continue
+			}
+			if andValue, ok := b.elements[i].Value.(bson.A); ok {
+				b.elements[i].Value = append(andValue, clauseList...)
+				return b
+			}
+		}
+	}
+	b.elements = append(b.elements, bson.E{Key: op.String(), Value: clauseList})
+	return b
+}
+
+func buildFilter(filter IFilter) bson.D {
+	if filter == nil {
+		return bson.D{}
+	}
+	return filter.Build()
+}
+
+func isOperatorDocument(d bson.D) bool {
+	for _, e := range d {
+		if len(e.Key) <= 0 || e.Key[0] != '$' {
+			return false
+		}
+	}
+	return true
+}
+
+func hasKey(d bson.D, key string) bool {
+	for _, e := range d {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
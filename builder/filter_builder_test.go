@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestFilterBuilder(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter IFilter
+		want   bson.D
+	}{
+		{
+			name:   "empty",
+			filter: NewFilterBuilder(),
+			want:   bson.D{},
+		},
+		{
+			name:   "operators of a field are merged",
+			filter: Where("age").Gte(18).Lt(60),
+			want:   bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: 18}, {Key: "$lt", Value: 60}}}},
+		},
+		{
+			name:   "fields keep the order of calls",
+			filter: Where("b").Eq(1).Where("a").In([]int{1, 2}).Where("b").Ne(2),
+			want: bson.D{
+				{Key: "b", Value: bson.D{{Key: "$eq", Value: 1}, {Key: "$ne", Value: 2}}},
+				{Key: "a", Value: bson.D{{Key: "$in", Value: []int{1, 2}}}},
+			},
+		},
+		{
+			name:   "regex with options",
+			filter: Where("name").Regex("^a", "i"),
+			want:   bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^a"}, {Key: "$options", Value: "i"}}}},
+		},
+		{
+			name:   "$not is merged into the operators of field",
+			filter: Where("score").Gte(10).Not().Lt(80),
+			want: bson.D{{Key: "score", Value: bson.D{
+				{Key: "$gte", Value: 10},
+				{Key: "$not", Value: bson.D{{Key: "$lt", Value: 80}}},
+			}}},
+		},
+		{
+			name:   "$not only negates the next operator",
+			filter: Where("score").Not().Regex("^a", "i").Exists(true),
+			want: bson.D{{Key: "score", Value: bson.D{
+				{Key: "$not", Value: bson.D{{Key: "$regex", Value: "^a"}, {Key: "$options", Value: "i"}}},
+				{Key: "$exists", Value: true},
+			}}},
+		},
+		{
+			name:   "second $not of a field falls back to $and",
+			filter: Where("score").Not().Lt(10).Not().Gt(90),
+			want: bson.D{
+				{Key: "score", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$lt", Value: 10}}}}},
+				{Key: "$and", Value: bson.A{
+					bson.D{{Key: "score", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: 90}}}}}},
+				}},
+			},
+		},
+		{
+			name:   "$and clauses are folded",
+			filter: Where("a").Eq(1).And(Where("b").Eq(2)).And(Where("c").Eq(3), nil),
+			want: bson.D{
+				{Key: "a", Value: bson.D{{Key: "$eq", Value: 1}}},
+				{Key: "$and", Value: bson.A{
+					bson.D{{Key: "b", Value: bson.D{{Key: "$eq", Value: 2}}}},
+					bson.D{{Key: "c", Value: bson.D{{Key: "$eq", Value: 3}}}},
+				}},
+			},
+		},
+		{
+			name:   "$or clauses are not folded",
+			filter: Or(Where("a").Eq(1)).Or(Where("b").Eq(2)),
+			want: bson.D{
+				{Key: "$or", Value: bson.A{bson.D{{Key: "a", Value: bson.D{{Key: "$eq", Value: 1}}}}}},
+				{Key: "$or", Value: bson.A{bson.D{{Key: "b", Value: bson.D{{Key: "$eq", Value: 2}}}}}},
+			},
+		},
+		{
+			name:   "logical without filters is ignored",
+			filter: Nor().And(nil),
+			want:   bson.D{},
+		},
+		{
+			name:   "$elemMatch of operator only condition",
+			filter: Where("scores").ElemMatch(Cond().Gte(80).Not().Gt(85)),
+			want: bson.D{{Key: "scores", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+				{Key: "$gte", Value: 80},
+				{Key: "$not", Value: bson.D{{Key: "$gt", Value: 85}}},
+			}}}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.filter.Build(); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("filter = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
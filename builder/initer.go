@@ -7,8 +7,23 @@ func init() {
 	_opList[op_array_push] = &Op{name: op_array_push}
 	_opList[op_array_pullAll] = &Op{name: op_array_pullAll}
 	_opList[op_array_elemMatch] = &Op{name: op_array_elemMatch}
+	_opList[op_array_all] = &Op{name: op_array_all}
+	_opList[op_array_size] = &Op{name: op_array_size}
+	_opList[op_array_each] = &Op{name: op_array_each}
+	_opList[op_array_position] = &Op{name: op_array_position}
+	_opList[op_array_slice] = &Op{name: op_array_slice}
+	_opList[op_array_sort] = &Op{name: op_array_sort}
 
 	_opList[setKey] = &Op{name: setKey}
+	_opList[unsetKey] = &Op{name: unsetKey}
+
+	_opList[op_field_currentDate] = &Op{name: op_field_currentDate}
+	_opList[op_field_inc] = &Op{name: op_field_inc}
+	_opList[op_field_min] = &Op{name: op_field_min}
+	_opList[op_field_max] = &Op{name: op_field_max}
+	_opList[op_field_mul] = &Op{name: op_field_mul}
+	_opList[op_field_rename] = &Op{name: op_field_rename}
+	_opList[op_field_setOnInsert] = &Op{name: op_field_setOnInsert}
 
 	_opList[op_comparison_eq] = &Op{name: op_comparison_eq}
 	_opList[op_comparison_gt] = &Op{name: op_comparison_gt}
@@ -19,6 +34,7 @@ func init() {
 	_opList[op_comparison_ne] = &Op{name: op_comparison_ne}
 	_opList[op_comparison_nin] = &Op{name: op_comparison_nin}
 	_opList[op_comparison_regex] = &Op{name: op_comparison_regex}
+	_opList[op_comparison_options] = &Op{name: op_comparison_options}
 
 	_opList[op_comparison_exists] = &Op{name: op_comparison_exists}
 	_opList[op_comparison_type] = &Op{name: op_comparison_type}
@@ -17,6 +17,19 @@ const (
 	//The $elemMatch operator matches documents that contain an array field with at least one element
 	// that matches all the specified query criteria.
 	op_array_elemMatch string = "$elemMatch"
+	// Matches arrays that contain all elements specified in the query.
+	op_array_all string = "$all"
+	// Selects documents if the array field is a specified size.
+	op_array_size string = "$size"
+
+	// Modifies the $push and $addToSet operators to append multiple items for array updates.
+	op_array_each string = "$each"
+	// Modifies the $push operator to specify the position in the array to add elements.
+	op_array_position string = "$position"
+	// Modifies the $push operator to limit the size of updated arrays.
+	op_array_slice string = "$slice"
+	// Modifies the $push operator to reorder documents stored in an array.
+	op_array_sort string = "$sort"
 )
 
 func Op_AddToSet() *Op {
@@ -42,3 +55,27 @@ func Op_PullAll() *Op {
 func Op_ElemMatch() *Op {
 	return _opList[op_array_elemMatch]
 }
+
+func Op_All() *Op {
+	return _opList[op_array_all]
+}
+
+func Op_Size() *Op {
+	return _opList[op_array_size]
+}
+
+func Op_Each() *Op {
+	return _opList[op_array_each]
+}
+
+func Op_Position() *Op {
+	return _opList[op_array_position]
+}
+
+func Op_Slice() *Op {
+	return _opList[op_array_slice]
+}
+
+func Op_Sort() *Op {
+	return _opList[op_array_sort]
+}
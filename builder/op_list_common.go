@@ -3,3 +3,7 @@ package builder
 func Op_Set() *Op {
 	return _opList[setKey]
 }
+
+func Op_Unset() *Op {
+	return _opList[unsetKey]
+}
@@ -21,6 +21,8 @@ const (
 	op_comparison_nin string = "$nin"
 
 	op_comparison_regex string = "$regex"
+	// options of $regex, such as i,m,x,s
+	op_comparison_options string = "$options"
 )
 
 type Op struct {
@@ -70,3 +72,7 @@ func Op_Nin() *Op {
 func Op_Regex() *Op {
 	return _opList[op_comparison_regex]
 }
+
+func Op_Options() *Op {
+	return _opList[op_comparison_options]
+}
@@ -0,0 +1,48 @@
+package builder
+
+const (
+	//https://www.mongodb.com/docs/manual/reference/operator/update-field/
+
+	// Sets the value of a field to current date, either as a Date or a Timestamp.
+	op_field_currentDate string = "$currentDate"
+	// Increments the value of the field by the specified amount.
+	op_field_inc string = "$inc"
+	// Only updates the field if the specified value is less than the existing field value.
+	op_field_min string = "$min"
+	// Only updates the field if the specified value is greater than the existing field value.
+	op_field_max string = "$max"
+	// Multiplies the value of the field by the specified amount.
+	op_field_mul string = "$mul"
+	// Renames a field.
+	op_field_rename string = "$rename"
+	// Sets the value of a field if an update results in an insert of a document.
+	op_field_setOnInsert string = "$setOnInsert"
+)
+
+func Op_CurrentDate() *Op {
+	return _opList[op_field_currentDate]
+}
+
+func Op_Inc() *Op {
+	return _opList[op_field_inc]
+}
+
+func Op_Min() *Op {
+	return _opList[op_field_min]
+}
+
+func Op_Max() *Op {
+	return _opList[op_field_max]
+}
+
+func Op_Mul() *Op {
+	return _opList[op_field_mul]
+}
+
+func Op_Rename() *Op {
+	return _opList[op_field_rename]
+}
+
+func Op_SetOnInsert() *Op {
+	return _opList[op_field_setOnInsert]
+}
@@ -0,0 +1,77 @@
+package builder
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	op_stage_set         = "$set"
+	op_stage_unset       = "$unset"
+	op_stage_project     = "$project"
+	op_stage_replaceWith = "$replaceWith"
+)
+
+// builder of pipeline-style update (update with aggregation pipeline),
+// only $set(alias $addFields),$unset,$project and $replaceWith(alias $replaceRoot) stages are allowed by mongodb
+type UpdatePipelineBuilder struct {
+	pipeline mongo.Pipeline
+}
+
+var _ IPipeline = (*UpdatePipelineBuilder)(nil)
+
+func NewUpdatePipelineBuilder() *UpdatePipelineBuilder {
+	return &UpdatePipelineBuilder{
+		pipeline: make(mongo.Pipeline, 0),
+	}
+}
+
+// set field to aggregation expression,
+// consecutive Set are merged into one $set stage
+func (b *UpdatePipelineBuilder) Set(fieldName string, expression interface{}) *UpdatePipelineBuilder {
+	if len(fieldName) <= 0 {
+		return b
+	}
+	e := bson.E{Key: fieldName, Value: expression}
+	if last := lastStageOf(b.pipeline, op_stage_set); last != nil {
+		last.Value = append(last.Value.(bson.D), e)
+		return b
+	}
+	b.pipeline = append(b.pipeline, bson.D{{Key: op_stage_set, Value: bson.D{e}}})
+	return b
+}
+
+// remove fields
+func (b *UpdatePipelineBuilder) Unset(fieldList ...string) *UpdatePipelineBuilder {
+	if len(fieldList) <= 0 {
+		return b
+	}
+	b.pipeline = append(b.pipeline, bson.D{{Key: op_stage_unset, Value: fieldList}})
+	return b
+}
+
+// append $project stage
+func (b *UpdatePipelineBuilder) Project(projection interface{}) *UpdatePipelineBuilder {
+	b.pipeline = append(b.pipeline, bson.D{{Key: op_stage_project, Value: projection}})
+	return b
+}
+
+// replace the document with the result of expression
+func (b *UpdatePipelineBuilder) ReplaceWith(expression interface{}) *UpdatePipelineBuilder {
+	b.pipeline = append(b.pipeline, bson.D{{Key: op_stage_replaceWith, Value: expression}})
+	return b
+}
+
+// append stage as it is
+func (b *UpdatePipelineBuilder) Stage(stage bson.D) *UpdatePipelineBuilder {
+	if len(stage) <= 0 {
+		return b
+	}
+	b.pipeline = append(b.pipeline, stage)
+	return b
+}
+
+// build the pipeline, it can be used as the update parameter of UpdateOne/UpdateMany/FindOneAndUpdate
+func (b *UpdatePipelineBuilder) Build() mongo.Pipeline {
+	return b.pipeline
+}
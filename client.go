@@ -1,6 +1,7 @@
 package mongodbr
 
 import (
+	"context"
 	"fmt"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -9,12 +10,11 @@ import (
 )
 
 var (
-	DefaultAlias                           = "default"
-	_cachedClient map[string]*mongo.Client = make(map[string]*mongo.Client)
+	DefaultAlias = "default"
 )
 
 func DefaultClient() *mongo.Client {
-	return _cachedClient[DefaultAlias]
+	return GetClient(DefaultAlias)
 }
 
 // 构建默认的client
@@ -22,25 +22,48 @@ func SetupDefaultClient(uri string, opts ...func(*options.ClientOptions)) (*mong
 	return RegistClient(DefaultAlias, uri, opts...)
 }
 
+// create client and register it with key in DefaultClientRegistry,
+// the client registered with the same key is replaced and disconnected in background after draining
 func RegistClient(key string, uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
 	client, clientOptions, err := CreateClient(uri, opts...)
 	if err != nil {
 		return nil, err
 	}
-	_cachedClient[key] = client
-	_cachedClientOptions[key] = options.MergeClientOptions(clientOptions)
+	if err := DefaultClientRegistry.Register(key, client, options.MergeClientOptions(clientOptions)); err != nil {
+		return client, err
+	}
 	return client, nil
 }
 
-// get client by key
+// get client by key, return nil if key is not registered, use LookupClient to get the error
 func GetClient(key string) *mongo.Client {
-	client, ok := _cachedClient[key]
-	if !ok {
+	client, err := DefaultClientRegistry.Get(key)
+	if err != nil {
 		return nil
 	}
 	return client
 }
 
+// get client by key, return ErrClientNotFound if key is not registered
+func LookupClient(key string) (*mongo.Client, error) {
+	return DefaultClientRegistry.Get(key)
+}
+
+// remove client by key and disconnect it
+func UnregisterClient(ctx context.Context, key string) error {
+	return DefaultClientRegistry.Unregister(ctx, key)
+}
+
+// remove and disconnect all clients, used for graceful shutdown
+func DisconnectAllClients(ctx context.Context) error {
+	return DefaultClientRegistry.DisconnectAll(ctx)
+}
+
+// keys of registered clients
+func ClientKeys() []string {
+	return DefaultClientRegistry.Keys()
+}
+
 func CreateClient(uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, *options.ClientOptions, error) {
 	mongoRegistry := bson.NewRegistry()
 	continRegistry := false
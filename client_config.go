@@ -0,0 +1,615 @@
+package mongodbr
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+	"gopkg.in/yaml.v3"
+)
+
+// default prefix of environment variables
+const DefaultConfigEnvPrefix = "MONGODBR"
+
+// config is invalid
+var ErrInvalidClientConfig = errors.New("invalid mongodb client config")
+
+// config of all clients, the key of Clients is the client key, such as DefaultAlias
+//
+//	clients:
+//	  default:
+//	    uri: mongodb://localhost:27017
+//	    maxPoolSize: 100
+//	    connectTimeout: 10s
+//	    readPreference: secondaryPreferred
+//	    writeConcern:
+//	      w: majority
+//	    monitoring:
+//	      commandLog: true
+//	      slowThreshold: 200ms
+type ClientsConfig struct {
+	Clients map[string]*ClientConfig `json:"clients" yaml:"clients"`
+}
+
+// config of one client, zero values are not applied, so the options in uri are kept
+type ClientConfig struct {
+	URI     string `json:"uri" yaml:"uri" env:"URI"`
+	AppName string `json:"appName,omitempty" yaml:"appName,omitempty" env:"APP_NAME"`
+
+	MinPoolSize     uint64   `json:"minPoolSize,omitempty" yaml:"minPoolSize,omitempty" env:"MIN_POOL_SIZE"`
+	MaxPoolSize     uint64   `json:"maxPoolSize,omitempty" yaml:"maxPoolSize,omitempty" env:"MAX_POOL_SIZE"`
+	MaxConnecting   uint64   `json:"maxConnecting,omitempty" yaml:"maxConnecting,omitempty" env:"MAX_CONNECTING"`
+	MaxConnIdleTime Duration `json:"maxConnIdleTime,omitempty" yaml:"maxConnIdleTime,omitempty" env:"MAX_CONN_IDLE_TIME"`
+
+	ConnectTimeout         Duration `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty" env:"CONNECT_TIMEOUT"`
+	ServerSelectionTimeout Duration `json:"serverSelectionTimeout,omitempty" yaml:"serverSelectionTimeout,omitempty" env:"SERVER_SELECTION_TIMEOUT"`
+	// timeout of every operation
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" env:"TIMEOUT"`
+
+	// primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	ReadPreference string              `json:"readPreference,omitempty" yaml:"readPreference,omitempty" env:"READ_PREFERENCE"`
+	WriteConcern   *WriteConcernConfig `json:"writeConcern,omitempty" yaml:"writeConcern,omitempty" env:"WRITE_CONCERN"`
+	TLS            *TLSConfig          `json:"tls,omitempty" yaml:"tls,omitempty" env:"TLS"`
+	Monitoring     *MonitoringConfig   `json:"monitoring,omitempty" yaml:"monitoring,omitempty" env:"MONITORING"`
+
+	// ping the server after connected
+	Ping bool `json:"ping,omitempty" yaml:"ping,omitempty" env:"PING"`
+}
+
+type WriteConcernConfig struct {
+	// "majority", a number of nodes or a tag
+	W       string `json:"w,omitempty" yaml:"w,omitempty" env:"W"`
+	Journal *bool  `json:"journal,omitempty" yaml:"journal,omitempty" env:"JOURNAL"`
+}
+
+type TLSConfig struct {
+	Enabled            bool   `json:"enabled,omitempty" yaml:"enabled,omitempty" env:"ENABLED"`
+	CAFile             string `json:"caFile,omitempty" yaml:"caFile,omitempty" env:"CA_FILE"`
+	CertFile           string `json:"certFile,omitempty" yaml:"certFile,omitempty" env:"CERT_FILE"`
+	KeyFile            string `json:"keyFile,omitempty" yaml:"keyFile,omitempty" env:"KEY_FILE"`
+	ServerName         string `json:"serverName,omitempty" yaml:"serverName,omitempty" env:"SERVER_NAME"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty" env:"INSECURE_SKIP_VERIFY"`
+}
+
+type MonitoringConfig struct {
+	// log commands with EnableCommandLogger
+	CommandLog    bool     `json:"commandLog,omitempty" yaml:"commandLog,omitempty" env:"COMMAND_LOG"`
+	LogCommand    bool     `json:"logCommand,omitempty" yaml:"logCommand,omitempty" env:"LOG_COMMAND"`
+	LogReply      bool     `json:"logReply,omitempty" yaml:"logReply,omitempty" env:"LOG_REPLY"`
+	SlowOnly      bool     `json:"slowOnly,omitempty" yaml:"slowOnly,omitempty" env:"SLOW_ONLY"`
+	SlowThreshold Duration `json:"slowThreshold,omitempty" yaml:"slowThreshold,omitempty" env:"SLOW_THRESHOLD"`
+	// 0 means logging all commands
+	SampleRate   float64  `json:"sampleRate,omitempty" yaml:"sampleRate,omitempty" env:"SAMPLE_RATE"`
+	RedactFields []string `json:"redactFields,omitempty" yaml:"redactFields,omitempty" env:"REDACT_FIELDS"`
+	// collect metrics with DefaultMetricsCollector
+	Metrics bool `json:"metrics,omitempty" yaml:"metrics,omitempty" env:"METRICS"`
+}
+
+// #region Duration
+
+// time.Duration which is read from strings such as "10s" or "500ms", numbers are nanoseconds
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v)
+		return nil
+	case string:
+		return d.parse(v)
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(value string) error {
+	value = strings.TrimSpace(value)
+	if len(value) <= 0 {
+		*d = 0
+		return nil
+	}
+	if nanoseconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*d = Duration(nanoseconds)
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// #endregion
+
+// #region load
+
+// load config from json or yaml file, the format is decided by extension
+func LoadClientsConfigFile(path string) (*ClientsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseClientsConfigJSON(data)
+	case ".yaml", ".yml":
+		return ParseClientsConfigYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, must be .json,.yaml or .yml", path)
+	}
+}
+
+// parse json config, unknown fields are not allowed
+func ParseClientsConfigJSON(data []byte) (*ClientsConfig, error) {
+	config := &ClientsConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClientConfig, err)
+	}
+	return config, nil
+}
+
+// parse yaml config, unknown fields are not allowed
+func ParseClientsConfigYAML(data []byte) (*ClientsConfig, error) {
+	config := &ClientsConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClientConfig, err)
+	}
+	return config, nil
+}
+
+// load config from environment variables,
+// <prefix>_CLIENTS is the comma separated client keys, and each client is read from <prefix>_<KEY>_<FIELD>,
+// such as MONGODBR_DEFAULT_URI and MONGODBR_DEFAULT_WRITE_CONCERN_W,
+// when <prefix>_CLIENTS is not set, the default client is read from <prefix>_<FIELD>, such as MONGODBR_URI
+func LoadClientsConfigFromEnv(prefix string) (*ClientsConfig, error) {
+	return loadClientsConfigWith(prefix, os.LookupEnv)
+}
+
+func loadClientsConfigWith(prefix string, lookup func(string) (string, bool)) (*ClientsConfig, error) {
+	if len(prefix) <= 0 {
+		prefix = DefaultConfigEnvPrefix
+	}
+	config := &ClientsConfig{
+		Clients: make(map[string]*ClientConfig),
+	}
+	keyList, ok := lookup(prefix + "_CLIENTS")
+	if !ok {
+		clientConfig := &ClientConfig{}
+		found, err := loadEnvInto(reflect.ValueOf(clientConfig).Elem(), prefix+"_", lookup)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			config.Clients[DefaultAlias] = clientConfig
+		}
+		return config, nil
+	}
+	for _, eachKey := range strings.Split(keyList, ",") {
+		eachKey = strings.TrimSpace(eachKey)
+		if len(eachKey) <= 0 {
+			continue
+		}
+		clientConfig := &ClientConfig{}
+		if _, err := loadEnvInto(reflect.ValueOf(clientConfig).Elem(), prefix+"_"+envName(eachKey)+"_", lookup); err != nil {
+			return nil, err
+		}
+		config.Clients[eachKey] = clientConfig
+	}
+	return config, nil
+}
+
+// upper case and replace the chars which are not letters or digits with '_'
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+var _durationType = reflect.TypeOf(Duration(0))
+
+// set fields with env tag from environment variables, return whether any variable is found
+func loadEnvInto(v reflect.Value, prefix string, lookup func(string) (string, bool)) (bool, error) {
+	found := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("env")
+		if len(name) <= 0 {
+			continue
+		}
+		fieldValue := v.Field(i)
+		// nested struct, such as TLS_CA_FILE
+		if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+			nested := reflect.New(field.Type.Elem())
+			nestedFound, err := loadEnvInto(nested.Elem(), prefix+name+"_", lookup)
+			if err != nil {
+				return found, err
+			}
+			if nestedFound {
+				fieldValue.Set(nested)
+				found = true
+			}
+			continue
+		}
+		envKey := prefix + name
+		value, ok := lookup(envKey)
+		if !ok {
+			continue
+		}
+		found = true
+		if err := setEnvValue(fieldValue, value); err != nil {
+			return found, fmt.Errorf("%w: %s: %v", ErrInvalidClientConfig, envKey, err)
+		}
+	}
+	return found, nil
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if v.Type() == _durationType {
+		return v.Addr().Interface().(*Duration).parse(value)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setEnvValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		list := make([]string, 0)
+		for _, each := range strings.Split(value, ",") {
+			if each = strings.TrimSpace(each); len(each) > 0 {
+				list = append(list, each)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// #endregion
+
+// #region validate
+
+// validate all clients, all problems are returned together
+func (c *ClientsConfig) Validate() error {
+	if c == nil || len(c.Clients) <= 0 {
+		return fmt.Errorf("%w: no client is configured", ErrInvalidClientConfig)
+	}
+	errList := make([]error, 0)
+	for _, eachKey := range c.Keys() {
+		if err := c.Clients[eachKey].Validate(); err != nil {
+			errList = append(errList, fmt.Errorf("client %s: %w", eachKey, err))
+		}
+	}
+	if len(errList) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidClientConfig, errors.Join(errList...))
+	}
+	return nil
+}
+
+// sorted client keys
+func (c *ClientsConfig) Keys() []string {
+	keys := make([]string, 0, len(c.Clients))
+	for key := range c.Clients {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validate client config
+func (c *ClientConfig) Validate() error {
+	if c == nil {
+		return errors.New("config is nil")
+	}
+	errList := make([]error, 0)
+	if len(strings.TrimSpace(c.URI)) <= 0 {
+		errList = append(errList, errors.New("uri is required"))
+	} else if !strings.HasPrefix(c.URI, "mongodb://") && !strings.HasPrefix(c.URI, "mongodb+srv://") {
+		errList = append(errList, errors.New("uri must start with mongodb:// or mongodb+srv://"))
+	}
+	if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		errList = append(errList, fmt.Errorf("minPoolSize %d is greater than maxPoolSize %d", c.MinPoolSize, c.MaxPoolSize))
+	}
+	for name, d := range map[string]Duration{
+		"maxConnIdleTime":        c.MaxConnIdleTime,
+		"connectTimeout":         c.ConnectTimeout,
+		"serverSelectionTimeout": c.ServerSelectionTimeout,
+		"timeout":                c.Timeout,
+	} {
+		if d < 0 {
+			errList = append(errList, fmt.Errorf("%s cannot be negative", name))
+		}
+	}
+	if len(c.ReadPreference) > 0 {
+		if _, err := readpref.ModeFromString(c.ReadPreference); err != nil {
+			errList = append(errList, fmt.Errorf("readPreference: %w", err))
+		}
+	}
+	if c.WriteConcern != nil {
+		if !c.WriteConcern.writeConcern().IsValid() {
+			errList = append(errList, fmt.Errorf("writeConcern is invalid"))
+		}
+	}
+	if c.TLS != nil {
+		if (len(c.TLS.CertFile) > 0) != (len(c.TLS.KeyFile) > 0) {
+			errList = append(errList, errors.New("tls certFile and keyFile must be set together"))
+		}
+		for name, path := range map[string]string{
+			"caFile":   c.TLS.CAFile,
+			"certFile": c.TLS.CertFile,
+			"keyFile":  c.TLS.KeyFile,
+		} {
+			if len(path) <= 0 {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				errList = append(errList, fmt.Errorf("tls %s: %w", name, err))
+			}
+		}
+	}
+	if c.Monitoring != nil && (c.Monitoring.SampleRate < 0 || c.Monitoring.SampleRate > 1) {
+		errList = append(errList, fmt.Errorf("monitoring sampleRate %v must be between 0 and 1", c.Monitoring.SampleRate))
+	}
+	return errors.Join(errList...)
+}
+
+// #endregion
+
+// #region client options
+
+func (c *WriteConcernConfig) writeConcern() *writeconcern.WriteConcern {
+	wc := &writeconcern.WriteConcern{
+		Journal: c.Journal,
+	}
+	if len(c.W) > 0 {
+		if w, err := strconv.Atoi(c.W); err == nil {
+			wc.W = w
+		} else {
+			wc.W = c.W
+		}
+	}
+	return wc
+}
+
+func (c *TLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CAFile) > 0 {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate is found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(c.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// build the option funcs of CreateClient, only the configured values are applied
+func (c *ClientConfig) ClientOptions() ([]func(*options.ClientOptions), error) {
+	optList := make([]func(*options.ClientOptions), 0)
+	add := func(fn func(*options.ClientOptions)) {
+		optList = append(optList, fn)
+	}
+	if len(c.AppName) > 0 {
+		add(func(co *options.ClientOptions) { co.SetAppName(c.AppName) })
+	}
+	if c.MinPoolSize > 0 {
+		add(func(co *options.ClientOptions) { co.SetMinPoolSize(c.MinPoolSize) })
+	}
+	if c.MaxPoolSize > 0 {
+		add(func(co *options.ClientOptions) { co.SetMaxPoolSize(c.MaxPoolSize) })
+	}
+	if c.MaxConnecting > 0 {
+		add(func(co *options.ClientOptions) { co.SetMaxConnecting(c.MaxConnecting) })
+	}
+	if c.MaxConnIdleTime > 0 {
+		add(func(co *options.ClientOptions) { co.SetMaxConnIdleTime(c.MaxConnIdleTime.Duration()) })
+	}
+	if c.ConnectTimeout > 0 {
+		add(func(co *options.ClientOptions) { co.SetConnectTimeout(c.ConnectTimeout.Duration()) })
+	}
+	if c.ServerSelectionTimeout > 0 {
+		add(func(co *options.ClientOptions) { co.SetServerSelectionTimeout(c.ServerSelectionTimeout.Duration()) })
+	}
+	if c.Timeout > 0 {
+		add(func(co *options.ClientOptions) { co.SetTimeout(c.Timeout.Duration()) })
+	}
+	if len(c.ReadPreference) > 0 {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		add(func(co *options.ClientOptions) { co.SetReadPreference(rp) })
+	}
+	if c.WriteConcern != nil {
+		wc := c.WriteConcern.writeConcern()
+		add(func(co *options.ClientOptions) { co.SetWriteConcern(wc) })
+	}
+	if c.TLS != nil && (c.TLS.Enabled || len(c.TLS.CAFile) > 0 || len(c.TLS.CertFile) > 0) {
+		tlsConfig, err := c.TLS.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		add(func(co *options.ClientOptions) { co.SetTLSConfig(tlsConfig) })
+	}
+	if c.Monitoring != nil {
+		if c.Monitoring.CommandLog {
+			sampleRate := c.Monitoring.SampleRate
+			if sampleRate <= 0 {
+				sampleRate = 1
+			}
+			add(EnableCommandLogger(
+				CommandLoggerOptionWithSlowThreshold(c.Monitoring.SlowThreshold.Duration()),
+				CommandLoggerOptionWithSampleRate(sampleRate),
+				CommandLoggerOptionWithSlowOnly(c.Monitoring.SlowOnly),
+				CommandLoggerOptionWithCommand(c.Monitoring.LogCommand),
+				CommandLoggerOptionWithReply(c.Monitoring.LogReply),
+				CommandLoggerOptionWithRedactFields(c.Monitoring.RedactFields...),
+			))
+		}
+		if c.Monitoring.Metrics {
+			add(EnableMetrics(DefaultMetricsCollector))
+		}
+	}
+	return optList, nil
+}
+
+// #endregion
+
+// validate config, create all clients and register them with their keys in DefaultClientRegistry,
+// opts are applied to every client after the config,
+// nothing is registered if any client cannot be created
+func SetupClients(config *ClientsConfig, opts ...func(*options.ClientOptions)) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	type createdClient struct {
+		key           string
+		client        *mongo.Client
+		clientOptions *options.ClientOptions
+	}
+	createdList := make([]*createdClient, 0, len(config.Clients))
+	disconnectCreated := func() {
+		for _, each := range createdList {
+			_ = each.client.Disconnect(context.Background())
+		}
+	}
+	for _, eachKey := range config.Keys() {
+		clientConfig := config.Clients[eachKey]
+		optList, err := clientConfig.ClientOptions()
+		if err != nil {
+			disconnectCreated()
+			return fmt.Errorf("client %s: %w", eachKey, err)
+		}
+		client, clientOptions, err := CreateClient(clientConfig.URI, append(optList, opts...)...)
+		if err != nil {
+			disconnectCreated()
+			return fmt.Errorf("client %s: %w", eachKey, err)
+		}
+		createdList = append(createdList, &createdClient{
+			key:           eachKey,
+			client:        client,
+			clientOptions: clientOptions,
+		})
+		if clientConfig.Ping {
+			if err := Ping(client); err != nil {
+				disconnectCreated()
+				return fmt.Errorf("client %s: %w", eachKey, err)
+			}
+		}
+	}
+	errList := make([]error, 0)
+	for _, each := range createdList {
+		if err := DefaultClientRegistry.Register(each.key, each.client, options.MergeClientOptions(each.clientOptions)); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// load config from json or yaml file, then setup all clients
+func SetupClientsFromFile(path string, opts ...func(*options.ClientOptions)) error {
+	config, err := LoadClientsConfigFile(path)
+	if err != nil {
+		return err
+	}
+	return SetupClients(config, opts...)
+}
+
+// load config from environment variables, then setup all clients
+func SetupClientsFromEnv(prefix string, opts ...func(*options.ClientOptions)) error {
+	config, err := LoadClientsConfigFromEnv(prefix)
+	if err != nil {
+		return err
+	}
+	return SetupClients(config, opts...)
+}
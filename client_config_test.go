@@ -0,0 +1,145 @@
+package mongodbr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadClientsConfigWith(t *testing.T) {
+	journal := true
+	cases := []struct {
+		name    string
+		prefix  string
+		env     map[string]string
+		want    map[string]*ClientConfig
+		wantErr bool
+	}{
+		{
+			name: "nothing is set",
+			want: map[string]*ClientConfig{},
+		},
+		{
+			name: "default client without clients",
+			env: map[string]string{
+				"MONGODBR_URI":             "mongodb://localhost:27017",
+				"MONGODBR_MAX_POOL_SIZE":   "100",
+				"MONGODBR_CONNECT_TIMEOUT": "10s",
+				"MONGODBR_PING":            "true",
+			},
+			want: map[string]*ClientConfig{
+				DefaultAlias: {
+					URI:            "mongodb://localhost:27017",
+					MaxPoolSize:    100,
+					ConnectTimeout: Duration(10 * time.Second),
+					Ping:           true,
+				},
+			},
+		},
+		{
+			name:   "custom prefix",
+			prefix: "APP",
+			env: map[string]string{
+				"APP_URI":      "mongodb://a",
+				"MONGODBR_URI": "mongodb://b",
+			},
+			want: map[string]*ClientConfig{
+				DefaultAlias: {URI: "mongodb://a"},
+			},
+		},
+		{
+			name: "clients by key",
+			env: map[string]string{
+				"MONGODBR_CLIENTS":       "default, report-db,",
+				"MONGODBR_DEFAULT_URI":   "mongodb://a",
+				"MONGODBR_REPORT_DB_URI": "mongodb://b",
+				"MONGODBR_URI":           "mongodb://ignored",
+			},
+			want: map[string]*ClientConfig{
+				"default":   {URI: "mongodb://a"},
+				"report-db": {URI: "mongodb://b"},
+			},
+		},
+		{
+			name: "nested struct",
+			env: map[string]string{
+				"MONGODBR_URI":                       "mongodb://a",
+				"MONGODBR_WRITE_CONCERN_W":           "majority",
+				"MONGODBR_WRITE_CONCERN_JOURNAL":     "true",
+				"MONGODBR_MONITORING_SAMPLE_RATE":    "0.5",
+				"MONGODBR_MONITORING_SLOW_THRESHOLD": "200000000",
+				"MONGODBR_MONITORING_REDACT_FIELDS":  "password, token,,",
+			},
+			want: map[string]*ClientConfig{
+				DefaultAlias: {
+					URI:          "mongodb://a",
+					WriteConcern: &WriteConcernConfig{W: "majority", Journal: &journal},
+					Monitoring: &MonitoringConfig{
+						SampleRate:    0.5,
+						SlowThreshold: Duration(200 * time.Millisecond),
+						RedactFields:  []string{"password", "token"},
+					},
+				},
+			},
+		},
+		{
+			name: "nested struct is found without top level fields",
+			env: map[string]string{
+				"MONGODBR_TLS_ENABLED": "true",
+			},
+			want: map[string]*ClientConfig{
+				DefaultAlias: {TLS: &TLSConfig{Enabled: true}},
+			},
+		},
+		{
+			name:    "invalid number",
+			env:     map[string]string{"MONGODBR_MAX_POOL_SIZE": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			env:     map[string]string{"MONGODBR_TIMEOUT": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid bool",
+			env:     map[string]string{"MONGODBR_CLIENTS": "a", "MONGODBR_A_PING": "sure"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lookup := func(key string) (string, bool) {
+				value, ok := c.env[key]
+				return value, ok
+			}
+			config, err := loadClientsConfigWith(c.prefix, lookup)
+			if c.wantErr {
+				if !errors.Is(err, ErrInvalidClientConfig) {
+					t.Fatalf("err = %v, want ErrInvalidClientConfig", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Clients, c.want) {
+				t.Errorf("Clients = %+v, want %+v", config.Clients, c.want)
+			}
+		})
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	cases := map[string]string{
+		"default":   "DEFAULT",
+		"report-db": "REPORT_DB",
+		"db.v2":     "DB_V2",
+	}
+	for key, want := range cases {
+		if got := envName(key); got != want {
+			t.Errorf("envName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -10,15 +10,16 @@ import (
 )
 
 var (
-	DefaultConfiguration                                   = NewConfiguration()
-	_cachedClientOptions map[string]*options.ClientOptions = make(map[string]*options.ClientOptions)
+	DefaultConfiguration = NewConfiguration()
 	// 是否忽略uuid的自定义解码器
 	_ignoreUUIDDecoder = true
 	// 是否忽略time.Time的自定义解码器
 	_ignoreTimeDecoder = true
 )
 
-// enable mongodb monitor
+// enable mongodb monitor, which prints every command and reply with log.Println
+//
+// Deprecated: use EnableCommandLogger, which logs structured records and redacts fields
 func EnableMongodbMonitor() func(*options.ClientOptions) {
 	return func(co *options.ClientOptions) {
 		monitor := &event.CommandMonitor{
@@ -48,13 +49,13 @@ func IgnoreTimeDecoder(ignore bool) {
 }
 
 func DefaultClientOptions() *options.ClientOptions {
-	return _cachedClientOptions[DefaultAlias]
+	return GetClientOptions(DefaultAlias)
 }
 
-// get client options by key
+// get client options by key, return nil if key is not registered
 func GetClientOptions(key string) *options.ClientOptions {
-	clientOptions, ok := _cachedClientOptions[key]
-	if !ok {
+	clientOptions, err := DefaultClientRegistry.GetOptions(key)
+	if err != nil {
 		return nil
 	}
 	return clientOptions
@@ -67,6 +68,22 @@ type Configuration struct {
 	createItemFunc func() interface{}
 	//查询时设置默认的排序
 	setDefaultSort func(*options.FindOptions) *options.FindOptions
+	//是否启用软删除
+	softDelete bool
+	// repository hooks
+	hooks []*RepositoryHooks
+	// 是否禁用写入前的校验
+	disableValidation bool
+	// 获取当前用户
+	userResolver UserResolver
+	// 当前时间
+	clock Clock
+	// 生成_id
+	idGenerator IdGenerator
+	// 链路追踪
+	tracer Tracer
+	// 是否为更新文档添加修改人及修改时间
+	updateAudit bool
 }
 
 func CreateContextAndCancel(c *Configuration) (context.Context, context.CancelFunc) {
@@ -0,0 +1,199 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// default time to wait for in use connections of a replaced client
+const DefaultDrainTimeout = 30 * time.Second
+
+type registeredClient struct {
+	client        *mongo.Client
+	clientOptions *options.ClientOptions
+}
+
+// concurrency safe registry of mongo.Client by key
+type ClientRegistry struct {
+	lock    sync.RWMutex
+	clients map[string]*registeredClient
+	// replaced clients which are being disconnected
+	draining sync.WaitGroup
+	// time to wait for in use connections when a client is replaced, DefaultDrainTimeout if <= 0
+	DrainTimeout time.Duration
+	// called when the replaced client failed to disconnect, logged by slog if nil
+	OnDrainError func(key string, err error)
+}
+
+// the registry used by RegistClient, GetClient and so on
+var DefaultClientRegistry = NewClientRegistry()
+
+// new ClientRegistry
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{
+		clients: make(map[string]*registeredClient),
+	}
+}
+
+// register client with key, the client which is registered with the same key is replaced,
+// then it's disconnected in background after its in use connections are returned or DrainTimeout elapsed,
+// the repositories created by NewRepository resolve the client by key, so they use the new client at once
+func (r *ClientRegistry) Register(key string, client *mongo.Client, clientOptions *options.ClientOptions) error {
+	if client == nil {
+		return fmt.Errorf("client cannot be nil,key:%s", key)
+	}
+	r.lock.Lock()
+	old := r.clients[key]
+	r.clients[key] = &registeredClient{
+		client:        client,
+		clientOptions: clientOptions,
+	}
+	r.lock.Unlock()
+
+	if old == nil || old.client == client {
+		return nil
+	}
+	r.draining.Add(1)
+	go r.drain(key, old.client)
+	return nil
+}
+
+// disconnect replaced client, Disconnect waits for the in use connections until DrainTimeout
+func (r *ClientRegistry) drain(key string, client *mongo.Client) {
+	defer r.draining.Done()
+
+	drainTimeout := r.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := client.Disconnect(ctx); err != nil {
+		if r.OnDrainError != nil {
+			r.OnDrainError(key, err)
+			return
+		}
+		slog.Warn("mongodbr: disconnect replaced client failed", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+// wait for the replaced clients to be disconnected
+func (r *ClientRegistry) WaitDrained() {
+	r.draining.Wait()
+}
+
+// get client by key, return ErrClientNotFound if key is not registered
+func (r *ClientRegistry) Get(key string) (*mongo.Client, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	registered, ok := r.clients[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotFound, key)
+	}
+	return registered.client, nil
+}
+
+// get client options by key, return ErrClientNotFound if key is not registered
+func (r *ClientRegistry) GetOptions(key string) (*options.ClientOptions, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	registered, ok := r.clients[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotFound, key)
+	}
+	return registered.clientOptions, nil
+}
+
+// remove client by key and disconnect it, return ErrClientNotFound if key is not registered
+func (r *ClientRegistry) Unregister(ctx context.Context, key string) error {
+	r.lock.Lock()
+	registered, ok := r.clients[key]
+	delete(r.clients, key)
+	r.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrClientNotFound, key)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return registered.client.Disconnect(ctx)
+}
+
+// remove and disconnect all clients, used for graceful shutdown,
+// all clients are disconnected even if some of them fail, and the replaced clients are waited
+func (r *ClientRegistry) DisconnectAll(ctx context.Context) error {
+	r.lock.Lock()
+	clients := r.clients
+	r.clients = make(map[string]*registeredClient)
+	r.lock.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var errList []error
+	for key, registered := range clients {
+		wg.Add(1)
+		go func(key string, client *mongo.Client) {
+			defer wg.Done()
+			if err := client.Disconnect(ctx); err != nil {
+				errLock.Lock()
+				errList = append(errList, fmt.Errorf("disconnect client %s: %w", key, err))
+				errLock.Unlock()
+			}
+		}(key, registered.client)
+	}
+	wg.Wait()
+	r.WaitDrained()
+	return errors.Join(errList...)
+}
+
+// get the options of registered client, return nil if client is not registered
+func (r *ClientRegistry) optionsOf(client *mongo.Client) *options.ClientOptions {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, registered := range r.clients {
+		if registered.client == client {
+			if registered.clientOptions == nil {
+				return options.Client()
+			}
+			return registered.clientOptions
+		}
+	}
+	return nil
+}
+
+// sorted keys of registered clients
+func (r *ClientRegistry) Keys() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	keys := make([]string, 0, len(r.clients))
+	for key := range r.clients {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// is key registered
+func (r *ClientRegistry) Has(key string) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	_, ok := r.clients[key]
+	return ok
+}
@@ -0,0 +1,138 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// client which is not connected to any server, operations are not sent until they are used
+func newTestClient(t *testing.T) (*mongo.Client, *options.ClientOptions) {
+	t.Helper()
+	clientOptions := options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(100 * time.Millisecond)
+	client, err := mongo.Connect(clientOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, clientOptions
+}
+
+func TestClientRegistryRegister(t *testing.T) {
+	registry := NewClientRegistry()
+	a, aOptions := newTestClient(t)
+	b, _ := newTestClient(t)
+
+	if err := registry.Register("a", nil, nil); err == nil {
+		t.Error("err = nil, want error of nil client")
+	}
+	if err := registry.Register("a", a, aOptions); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("b", b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.Keys(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Keys() = %v, want [a b]", got)
+	}
+	if client, err := registry.Get("a"); err != nil || client != a {
+		t.Errorf("Get(a) = %v, %v", client, err)
+	}
+	if clientOptions, err := registry.GetOptions("a"); err != nil || clientOptions != aOptions {
+		t.Errorf("GetOptions(a) = %v, %v", clientOptions, err)
+	}
+	if registry.optionsOf(a) != aOptions {
+		t.Error("optionsOf(a) is not the registered options")
+	}
+	if registry.optionsOf(b) == nil {
+		t.Error("optionsOf(b) = nil, want default options")
+	}
+	if _, err := registry.Get("c"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Get(c) err = %v, want ErrClientNotFound", err)
+	}
+	if _, err := registry.GetOptions("c"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("GetOptions(c) err = %v, want ErrClientNotFound", err)
+	}
+
+	// registering the same client again does not disconnect it
+	if err := registry.Register("a", a, aOptions); err != nil {
+		t.Fatal(err)
+	}
+	registry.WaitDrained()
+	if err := registry.Unregister(context.Background(), "a"); err != nil {
+		t.Errorf("Unregister(a) err = %v", err)
+	}
+	if registry.Has("a") {
+		t.Error("a is registered after Unregister")
+	}
+	if err := registry.Unregister(context.Background(), "a"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Unregister(a) again err = %v, want ErrClientNotFound", err)
+	}
+	if err := registry.DisconnectAll(context.Background()); err != nil {
+		t.Errorf("DisconnectAll() err = %v", err)
+	}
+	if len(registry.Keys()) != 0 {
+		t.Errorf("Keys() = %v after DisconnectAll", registry.Keys())
+	}
+}
+
+func TestClientRegistryReplace(t *testing.T) {
+	var lock sync.Mutex
+	drainErrors := make(map[string]error)
+	registry := NewClientRegistry()
+	registry.DrainTimeout = time.Second
+	registry.OnDrainError = func(key string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		drainErrors[key] = err
+	}
+
+	old, _ := newTestClient(t)
+	replacement, _ := newTestClient(t)
+	if err := registry.Register("a", old, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("a", replacement, nil); err != nil {
+		t.Fatal(err)
+	}
+	if client, _ := registry.Get("a"); client != replacement {
+		t.Error("Get(a) is not the new client")
+	}
+	registry.WaitDrained()
+	if err := old.Disconnect(context.Background()); !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Errorf("old client is not disconnected after drain, err = %v", err)
+	}
+	if len(drainErrors) != 0 {
+		t.Errorf("drain errors = %v", drainErrors)
+	}
+
+	// the disconnect error of the replaced client is reported by OnDrainError
+	another, _ := newTestClient(t)
+	if err := replacement.Disconnect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("a", another, nil); err != nil {
+		t.Fatal(err)
+	}
+	registry.WaitDrained()
+	if err := drainErrors["a"]; !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Errorf("drain error = %v, want ErrClientDisconnected", err)
+	}
+
+	// DisconnectAll reports the error of every client
+	if err := registry.Register("b", replacement, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := registry.DisconnectAll(context.Background())
+	if !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Errorf("DisconnectAll() err = %v, want ErrClientDisconnected of b", err)
+	}
+	if err := another.Disconnect(context.Background()); !errors.Is(err, mongo.ErrClientDisconnected) {
+		t.Errorf("a is not disconnected by DisconnectAll, err = %v", err)
+	}
+}
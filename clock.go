@@ -0,0 +1,98 @@
+package mongodbr
+
+import (
+	"sync"
+	"time"
+)
+
+// source of current time, replace it to freeze time in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// adapt a func to Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// clock which returns time.Now()
+var SystemClock Clock = ClockFunc(time.Now)
+
+// clock which returns a fixed time until it's changed, used in tests
+type FixedClock struct {
+	lock sync.RWMutex
+	now  time.Time
+}
+
+var _ Clock = (*FixedClock)(nil)
+
+// new FixedClock with now
+func NewFixedClock(now time.Time) *FixedClock {
+	return &FixedClock{
+		now: now,
+	}
+}
+
+func (c *FixedClock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.now
+}
+
+// set current time
+func (c *FixedClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+}
+
+// move current time forward by d
+func (c *FixedClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+var (
+	_defaultClockLock sync.RWMutex
+	_defaultClock     = SystemClock
+)
+
+// set the global Clock, which is used by entities and by repositories without Clock
+func SetDefaultClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	_defaultClockLock.Lock()
+	defer _defaultClockLock.Unlock()
+
+	_defaultClock = clock
+}
+
+// get the global Clock
+func DefaultClock() Clock {
+	_defaultClockLock.RLock()
+	defer _defaultClockLock.RUnlock()
+
+	return _defaultClock
+}
+
+// set Clock of repository
+func WithClock(clock Clock) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.clock = clock
+	}
+}
+
+// get current time with the Clock of repository, or the global Clock
+func (c *Configuration) Now() time.Time {
+	if c != nil && c.clock != nil {
+		return c.clock.Now()
+	}
+	return DefaultClock().Now()
+}
@@ -0,0 +1,350 @@
+package mongodbr
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// value which replaces the redacted fields
+const RedactedValue = "[REDACTED]"
+
+type CommandLoggerOptions struct {
+	// logger, slog.Default() if nil
+	Logger *slog.Logger
+	// level of succeeded commands, slow commands are logged with slog.LevelWarn and failed commands with slog.LevelError
+	Level slog.Level
+	// commands which take longer than SlowThreshold are slow commands, 0 means no slow command
+	SlowThreshold time.Duration
+	// ratio of succeeded and not slow commands to log, between 0 and 1, slow and failed commands are always logged
+	SampleRate float64
+	// only log slow and failed commands
+	SlowOnly bool
+	// log the command document
+	LogCommand bool
+	// log the reply document
+	LogReply bool
+	// the values of these fields are replaced with RedactedValue in logged commands and replies, case insensitive
+	RedactFields []string
+}
+
+type CommandLoggerOption func(*CommandLoggerOptions)
+
+// merge CommandLoggerOption list and return one *CommandLoggerOptions
+func MergeCommandLoggerOption(opts ...CommandLoggerOption) *CommandLoggerOptions {
+	o := &CommandLoggerOptions{
+		Level:      slog.LevelDebug,
+		SampleRate: 1,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	return o
+}
+
+// CommandLoggerOption with logger
+func CommandLoggerOptionWithLogger(logger *slog.Logger) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.Logger = logger
+	}
+}
+
+// CommandLoggerOption with level of succeeded commands
+func CommandLoggerOptionWithLevel(level slog.Level) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.Level = level
+	}
+}
+
+// CommandLoggerOption with slow threshold
+func CommandLoggerOptionWithSlowThreshold(threshold time.Duration) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.SlowThreshold = threshold
+	}
+}
+
+// CommandLoggerOption with sample rate
+func CommandLoggerOptionWithSampleRate(rate float64) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.SampleRate = rate
+	}
+}
+
+// CommandLoggerOption which only logs slow and failed commands
+func CommandLoggerOptionWithSlowOnly(slowOnly bool) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.SlowOnly = slowOnly
+	}
+}
+
+// CommandLoggerOption which logs command documents
+func CommandLoggerOptionWithCommand(logCommand bool) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.LogCommand = logCommand
+	}
+}
+
+// CommandLoggerOption which logs reply documents
+func CommandLoggerOptionWithReply(logReply bool) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.LogReply = logReply
+	}
+}
+
+// CommandLoggerOption with redacted field names, matched case insensitively with the whole key or the last segment of dotted path
+func CommandLoggerOptionWithRedactFields(fields ...string) CommandLoggerOption {
+	return func(o *CommandLoggerOptions) {
+		o.RedactFields = append(o.RedactFields, fields...)
+	}
+}
+
+// enable structured command logging with log/slog,
+// the monitor is combined with the existing monitor of client options
+func EnableCommandLogger(opts ...CommandLoggerOption) func(*options.ClientOptions) {
+	return func(co *options.ClientOptions) {
+		AppendCommandMonitor(co, NewCommandLoggerMonitor(opts...))
+	}
+}
+
+// new a CommandMonitor which logs every finished command with log/slog
+func NewCommandLoggerMonitor(opts ...CommandLoggerOption) *event.CommandMonitor {
+	l := newCommandLogger(MergeCommandLoggerOption(opts...))
+	return &event.CommandMonitor{
+		Started:   l.started,
+		Succeeded: l.succeeded,
+		Failed:    l.failed,
+	}
+}
+
+// #region command monitor helpers
+
+// combine CommandMonitor list into one, every monitor is invoked in order, nil monitors are ignored
+func CombineCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	list := make([]*event.CommandMonitor, 0, len(monitors))
+	for _, eachMonitor := range monitors {
+		if eachMonitor != nil {
+			list = append(list, eachMonitor)
+		}
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Started != nil {
+					eachMonitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Succeeded != nil {
+					eachMonitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Failed != nil {
+					eachMonitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// combine monitor with the existing monitor of client options
+func AppendCommandMonitor(co *options.ClientOptions, monitor *event.CommandMonitor) {
+	co.SetMonitor(CombineCommandMonitors(co.Monitor, monitor))
+}
+
+// get collection name from command document, the first element of most commands is the collection name
+func commandCollection(command bson.Raw) string {
+	elements, err := command.Elements()
+	if err != nil || len(elements) <= 0 {
+		return ""
+	}
+	if collection, ok := elements[0].Value().StringValueOK(); ok {
+		return collection
+	}
+	return ""
+}
+
+// key of command in flight, request id is unique per connection
+type commandKey struct {
+	connectionId string
+	requestId    int64
+}
+
+// #endregion
+
+type startedCommand struct {
+	collection string
+	command    bson.Raw
+}
+
+type commandLogger struct {
+	options      *CommandLoggerOptions
+	redactFields map[string]struct{}
+	inflight     sync.Map
+}
+
+func newCommandLogger(o *CommandLoggerOptions) *commandLogger {
+	l := &commandLogger{
+		options:      o,
+		redactFields: make(map[string]struct{}),
+	}
+	for _, eachField := range o.RedactFields {
+		l.redactFields[strings.ToLower(eachField)] = struct{}{}
+	}
+	return l
+}
+
+func (l *commandLogger) logger() *slog.Logger {
+	if l.options.Logger != nil {
+		return l.options.Logger
+	}
+	return slog.Default()
+}
+
+func (l *commandLogger) started(_ context.Context, e *event.CommandStartedEvent) {
+	started := &startedCommand{
+		collection: commandCollection(e.Command),
+	}
+	if l.options.LogCommand {
+		// the command buffer may be reused by driver
+		started.command = append(bson.Raw(nil), e.Command...)
+	}
+	l.inflight.Store(commandKey{connectionId: e.ConnectionID, requestId: e.RequestID}, started)
+}
+
+func (l *commandLogger) finished(e *event.CommandFinishedEvent) *startedCommand {
+	value, ok := l.inflight.LoadAndDelete(commandKey{connectionId: e.ConnectionID, requestId: e.RequestID})
+	if !ok {
+		return &startedCommand{}
+	}
+	return value.(*startedCommand)
+}
+
+func (l *commandLogger) succeeded(ctx context.Context, e *event.CommandSucceededEvent) {
+	started := l.finished(&e.CommandFinishedEvent)
+	slow := l.options.SlowThreshold > 0 && e.Duration >= l.options.SlowThreshold
+	level := l.options.Level
+	if slow {
+		level = slog.LevelWarn
+	} else if l.options.SlowOnly || !l.sampled() {
+		return
+	}
+	logger := l.logger()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := l.attrs(&e.CommandFinishedEvent, started, slow)
+	if l.options.LogReply {
+		attrs = append(attrs, slog.String("reply", l.redact(e.Reply)))
+	}
+	logger.LogAttrs(ctx, level, "mongodb command succeeded", attrs...)
+}
+
+func (l *commandLogger) failed(ctx context.Context, e *event.CommandFailedEvent) {
+	started := l.finished(&e.CommandFinishedEvent)
+	slow := l.options.SlowThreshold > 0 && e.Duration >= l.options.SlowThreshold
+	logger := l.logger()
+	if !logger.Enabled(ctx, slog.LevelError) {
+		return
+	}
+	attrs := l.attrs(&e.CommandFinishedEvent, started, slow)
+	attrs = append(attrs, slog.Any("error", e.Failure))
+	logger.LogAttrs(ctx, slog.LevelError, "mongodb command failed", attrs...)
+}
+
+func (l *commandLogger) sampled() bool {
+	if l.options.SampleRate >= 1 {
+		return true
+	}
+	if l.options.SampleRate <= 0 {
+		return false
+	}
+	return rand.Float64() < l.options.SampleRate
+}
+
+func (l *commandLogger) attrs(e *event.CommandFinishedEvent, started *startedCommand, slow bool) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("command", e.CommandName),
+		slog.String("database", e.DatabaseName),
+		slog.String("collection", started.collection),
+		slog.Duration("duration", e.Duration),
+		slog.Int64("requestId", e.RequestID),
+		slog.String("connectionId", e.ConnectionID),
+	}
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+	if started.command != nil {
+		attrs = append(attrs, slog.String("cmd", l.redact(started.command)))
+	}
+	return attrs
+}
+
+// render document as relaxed extended json, the values of redacted fields are replaced
+func (l *commandLogger) redact(raw bson.Raw) string {
+	if len(raw) <= 0 {
+		return ""
+	}
+	if len(l.redactFields) <= 0 {
+		return raw.String()
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return RedactedValue
+	}
+	data, err := bson.MarshalExtJSON(l.redactValue(doc), false, false)
+	if err != nil {
+		return RedactedValue
+	}
+	return string(data)
+}
+
+// is key redacted, the whole key and its last segment of dotted path such as profile.password are matched
+func (l *commandLogger) isRedacted(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := l.redactFields[key]; ok {
+		return true
+	}
+	if index := strings.LastIndexByte(key, '.'); index >= 0 {
+		_, ok := l.redactFields[key[index+1:]]
+		return ok
+	}
+	return false
+}
+
+func (l *commandLogger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		result := make(bson.D, 0, len(v))
+		for _, eachElement := range v {
+			if l.isRedacted(eachElement.Key) {
+				result = append(result, bson.E{Key: eachElement.Key, Value: RedactedValue})
+				continue
+			}
+			result = append(result, bson.E{Key: eachElement.Key, Value: l.redactValue(eachElement.Value)})
+		}
+		return result
+	case bson.A:
+		result := make(bson.A, 0, len(v))
+		for _, eachValue := range v {
+			result = append(result, l.redactValue(eachValue))
+		}
+		return result
+	default:
+		return value
+	}
+}
@@ -1,6 +1,8 @@
 package mongodbr
 
 import (
+	"fmt"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -23,6 +25,8 @@ type IEntityBeforeUpdate interface {
 	BeforeUpdate()
 }
 
+var _ IEntityIdSetter = (*Entity)(nil)
+
 // 创建时设置对象的基本信息
 func (entity *Entity) BeforeCreate() {
 	if entity.ObjectId == bson.NilObjectID {
@@ -33,3 +37,22 @@ func (entity *Entity) BeforeCreate() {
 func (entity Entity) GetObjectId() bson.ObjectID {
 	return entity.ObjectId
 }
+
+// #region IEntityIdSetter Members
+
+func (entity *Entity) IsIdEmpty() bool {
+	return entity.ObjectId == bson.NilObjectID
+}
+
+// id must be bson.ObjectID, the IdGenerator of repository is not used for Entity if it does not generate bson.ObjectID,
+// use KeyedEntity[K] for the ids of other types
+func (entity *Entity) SetId(id interface{}) error {
+	objectId, ok := id.(bson.ObjectID)
+	if !ok {
+		return fmt.Errorf("%w: _id of Entity must be bson.ObjectID, but got %T", ErrInvalidType, id)
+	}
+	entity.ObjectId = objectId
+	return nil
+}
+
+// #endregion
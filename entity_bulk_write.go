@@ -1,6 +1,10 @@
 package mongodbr
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -19,18 +23,28 @@ type IEntityBulkWrite interface {
 
 var _ IEntityBulkWrite = (*MongoCol)(nil)
 
-func _buildWriteModelForUpdate(list []IEntity) []mongo.WriteModel {
+// build update model for each item by its id,
+// the version of IVersionedEntity is incremented and its expected version is returned by item index
+func _buildWriteModelForUpdate(idList []interface{}, itemList []interface{}) ([]mongo.WriteModel, map[int]int64) {
 	modelList := make([]mongo.WriteModel, 0)
-	if len(list) <= 0 {
-		return modelList
+	expectedVersionList := make(map[int]int64)
+	if len(itemList) <= 0 {
+		return modelList, expectedVersionList
 	}
-	for _, eachEntity := range list {
+	for index, eachItem := range itemList {
 		currentModel := mongo.NewUpdateOneModel()
-		currentModel.SetFilter(bson.M{"_id": eachEntity.GetObjectId()})
-		currentModel.SetUpdate(builder.NewBsonBuilder().NewOrUpdateSet(eachEntity))
+		if versionedEntity, ok := eachItem.(IVersionedEntity); ok {
+			expectedVersion := versionedEntity.GetVersion()
+			expectedVersionList[index] = expectedVersion
+			versionedEntity.SetVersion(expectedVersion + 1)
+			currentModel.SetFilter(bson.D{{Key: "_id", Value: idList[index]}, VersionFilter(expectedVersion)})
+		} else {
+			currentModel.SetFilter(bson.M{"_id": idList[index]})
+		}
+		currentModel.SetUpdate(builder.NewBsonBuilder().NewOrUpdateSet(eachItem))
 		modelList = append(modelList, currentModel)
 	}
-	return modelList
+	return modelList, expectedVersionList
 }
 
 // build mongo.WriteModel list with ObjectId list
@@ -65,22 +79,24 @@ func BuildWriteModelList(filterList []interface{}, getUpdateFn func(filter inter
 
 // #region IEntityBulkWrite members
 
+// execute write models, hooks are invoked for InsertOne,UpdateOne,UpdateMany,ReplaceOne,DeleteOne and DeleteMany models
 func (c *MongoCol) BulkWrite(models []mongo.WriteModel, opts ...MongodbrBulkWriteOption) (
-	*mongo.BulkWriteResult, error) {
+	_ *mongo.BulkWriteResult, err error) {
 	if len(models) <= 0 {
 		return nil, nil
 	}
 
-	bulkWriteOptions := &MongodbrBulkWriteOptions{
-		BulkWriteOptions: &options.BulkWriteOptions{},
-	}
-	for _, o := range opts {
-		o(bulkWriteOptions)
-	}
+	bulkWriteOptions := MergeMongodbrBulkWriteOption(opts...)
 	ctx, cancel := CreateContextAndCancelWith(c.configuration, bulkWriteOptions.WithCtx)
 	defer cancel()
 
-	res, err := c.collection.BulkWrite(
+	ctx, span := c.startSpan(ctx, "bulkWrite", nil)
+	defer endSpan(span, &err)
+
+	if err := c.configuration.hookBeforeWriteModels(ctx, models); err != nil {
+		return nil, err
+	}
+	res, err := c.collection().BulkWrite(
 		ctx,
 		models,
 		bulkWriteOptions,
@@ -88,14 +104,134 @@ func (c *MongoCol) BulkWrite(models []mongo.WriteModel, opts ...MongodbrBulkWrit
 	if err != nil {
 		return res, err
 	}
-
+	if err := c.configuration.hookAfterWriteModels(ctx, models); err != nil {
+		return res, err
+	}
 	return res, nil
 }
 
+// update entity list by _id with $set,
+// when any entity implements IVersionedEntity and it's not matched, ErrConcurrencyConflict is returned,
+// the versions of the conflicted entities are restored, the others have been written.
+// the list with any IVersionedEntity is written by one UpdateOne per entity in order instead of one BulkWrite,
+// so that every conflict is decided by the matched count of its own write
 func (c *MongoCol) BulkWriteEntityList(entityList []IEntity, opts ...MongodbrBulkWriteOption) (
 	*mongo.BulkWriteResult, error) {
-	modelList := _buildWriteModelForUpdate(entityList)
-	return c.BulkWrite(modelList, opts...)
+	idList := make([]interface{}, 0, len(entityList))
+	itemList := make([]interface{}, 0, len(entityList))
+	for _, eachEntity := range entityList {
+		idList = append(idList, eachEntity.GetObjectId())
+		itemList = append(itemList, eachEntity)
+	}
+	return c.BulkUpdateItemsById(idList, itemList, opts...)
+}
+
+// update item list by their id with $set, idList[i] is the _id of itemList[i] and can be of any type,
+// same as BulkWriteEntityList
+func (c *MongoCol) BulkUpdateItemsById(idList []interface{}, itemList []interface{}, opts ...MongodbrBulkWriteOption) (
+	_ *mongo.BulkWriteResult, err error) {
+	if len(idList) != len(itemList) {
+		return nil, fmt.Errorf("length of idList(%d) and itemList(%d) must be the same", len(idList), len(itemList))
+	}
+	if len(itemList) <= 0 {
+		return nil, nil
+	}
+
+	bulkWriteOptions := MergeMongodbrBulkWriteOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(c.configuration, bulkWriteOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := c.startSpan(ctx, "bulkWrite", nil)
+	defer endSpan(span, &err)
+
+	// all before hooks are invoked before writing, so nothing is written if any hook fails
+	for index, eachItem := range itemList {
+		if err := c.configuration.hookBeforeUpdate(ctx, bson.M{"_id": idList[index]}, eachItem); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.configuration.validateMany(itemList); err != nil {
+		return nil, err
+	}
+	modelList, expectedVersionList := _buildWriteModelForUpdate(idList, itemList)
+	var res *mongo.BulkWriteResult
+	if len(expectedVersionList) > 0 {
+		res, err = c.updateEachModel(ctx, modelList, itemList, expectedVersionList, bulkWriteOptions.BulkWriteOptions)
+	} else {
+		res, err = c.collection().BulkWrite(ctx, modelList, bulkWriteOptions)
+	}
+	if err != nil {
+		return res, err
+	}
+	for index, eachItem := range itemList {
+		if err := c.configuration.hookAfterUpdate(ctx, bson.M{"_id": idList[index]}, eachItem); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
 }
 
 // #endregion
+
+// write the update models one by one in order, a versioned item which is not matched by its own write is a conflict,
+// the versions of the conflicted and not written items are restored,
+// a mongo.BulkWriteException is returned for the write errors like BulkWrite,
+// ErrConcurrencyConflict is returned if there is no write error but any conflict
+func (c *MongoCol) updateEachModel(ctx context.Context,
+	modelList []mongo.WriteModel,
+	itemList []interface{},
+	expectedVersionList map[int]int64,
+	bulkWriteOptions *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+
+	ordered := bulkWriteOptions == nil || bulkWriteOptions.Ordered == nil || *bulkWriteOptions.Ordered
+	restoreVersion := func(index int) {
+		if expectedVersion, ok := expectedVersionList[index]; ok {
+			itemList[index].(IVersionedEntity).SetVersion(expectedVersion)
+		}
+	}
+	res := &mongo.BulkWriteResult{
+		UpsertedIDs:  make(map[int64]interface{}),
+		Acknowledged: true,
+	}
+	writeErrors := make([]mongo.BulkWriteError, 0)
+	conflicted := false
+	for index, eachModel := range modelList {
+		model := eachModel.(*mongo.UpdateOneModel)
+		updateResult, err := c.collection().UpdateOne(ctx, model.Filter, model.Update)
+		if err != nil {
+			restoreVersion(index)
+			var writeException mongo.WriteException
+			if !errors.As(err, &writeException) || len(writeException.WriteErrors) <= 0 {
+				// nothing is known to be written by the failed write, and the rest are not written
+				for rest := index + 1; rest < len(modelList); rest++ {
+					restoreVersion(rest)
+				}
+				return res, err
+			}
+			writeError := writeException.WriteErrors[0]
+			writeError.Index = index
+			writeErrors = append(writeErrors, mongo.BulkWriteError{WriteError: writeError, Request: eachModel})
+			if ordered {
+				for rest := index + 1; rest < len(modelList); rest++ {
+					restoreVersion(rest)
+				}
+				break
+			}
+			continue
+		}
+		res.MatchedCount += updateResult.MatchedCount
+		res.ModifiedCount += updateResult.ModifiedCount
+		if _, ok := expectedVersionList[index]; ok && updateResult.MatchedCount <= 0 {
+			// a missed item which is not versioned is not a conflict
+			restoreVersion(index)
+			conflicted = true
+		}
+	}
+	if len(writeErrors) > 0 {
+		return res, mongo.BulkWriteException{WriteErrors: writeErrors}
+	}
+	if conflicted {
+		return res, ErrConcurrencyConflict
+	}
+	return res, nil
+}
@@ -25,7 +25,7 @@ var _ IEntityFind = (*MongoCol)(nil)
 
 // #region IEntityFind Members
 
-func (r *MongoCol) CountByFilter(filter interface{}, opts ...MongodbrCountOption) (int64, error) {
+func (r *MongoCol) CountByFilter(filter interface{}, opts ...MongodbrCountOption) (_ int64, err error) {
 	// handle options
 	cOptions := &MongodbrCountOptions{
 		CountOptions: &options.CountOptions{},
@@ -37,7 +37,11 @@ func (r *MongoCol) CountByFilter(filter interface{}, opts ...MongodbrCountOption
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, cOptions.WithCtx)
 	defer cancel()
 
-	total, err := r.collection.CountDocuments(ctx, filter, cOptions)
+	ctx, span := r.startSpan(ctx, "countDocuments", filter)
+	defer endSpan(span, &err)
+
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	total, err := r.collection().CountDocuments(ctx, filter, cOptions)
 	if err != nil {
 		return 0, err
 	}
@@ -54,20 +58,39 @@ func (r *MongoCol) CountAll(opts ...WithContextOptions) (count int64, err error)
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, cOptions.WithCtx)
 	defer cancel()
 
-	total, err := r.collection.EstimatedDocumentCount(ctx)
+	ctx, span := r.startSpan(ctx, "count", nil)
+	defer endSpan(span, &err)
+
+	if r.configuration.softDelete && !IsSoftDeleteDisabled(ctx) {
+		// estimated count cannot filter deleted documents
+		return r.collection().CountDocuments(ctx, r.configuration.notDeletedFilter(ctx, bson.D{}))
+	}
+	total, err := r.collection().EstimatedDocumentCount(ctx)
 	if err != nil {
 		return 0, err
 	}
 	return total, nil
 }
 
+// sort set by WithDefaultSort, nil if not configured
+func (r *MongoCol) defaultSort() interface{} {
+	if r.configuration.setDefaultSort == nil {
+		return nil
+	}
+	findOptions := &options.FindOptions{}
+	if result := r.configuration.setDefaultSort(findOptions); result != nil {
+		return result.Sort
+	}
+	return findOptions.Sort
+}
+
 func (r *MongoCol) FindAll(list interface{}, opts ...MongodbrFindOption) error {
 	return r.FindListByFilter(bson.M{}, list, opts...)
 }
 
 // 根据条件来筛选
 // v,集合值,
-func (r *MongoCol) FindListByFilter(filter interface{}, list interface{}, opts ...MongodbrFindOption) error {
+func (r *MongoCol) FindListByFilter(filter interface{}, list interface{}, opts ...MongodbrFindOption) (err error) {
 	//设置默认搜索参数
 	findOptions := &MongodbrFindOptions{
 		FindOptions: &options.FindOptions{},
@@ -78,6 +101,9 @@ func (r *MongoCol) FindListByFilter(filter interface{}, list interface{}, opts .
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, findOptions.WithCtx)
 	defer cancel()
 
+	ctx, span := r.startSpan(ctx, "find", filter)
+	defer endSpan(span, &err)
+
 	if findOptions.Sort == nil {
 		// if sort is nil,then set default sort with configuration
 		if r.configuration.setDefaultSort != nil {
@@ -85,7 +111,8 @@ func (r *MongoCol) FindListByFilter(filter interface{}, list interface{}, opts .
 		}
 	}
 
-	cur, err := r.collection.Find(ctx, filter, findOptions)
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	cur, err := r.collection().Find(ctx, filter, findOptions)
 	if err != nil {
 		return err
 	}
@@ -121,7 +148,10 @@ func (r *MongoCol) FindListResultByFilter(filter interface{}, opts ...MongodbrFi
 		}
 	}
 
-	cur, err := r.collection.Find(ctx, filter, findOptions)
+	ctx, span := r.startSpan(ctx, "find", filter)
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	cur, err := r.collection().Find(ctx, filter, findOptions)
+	span.End(err)
 	if err != nil {
 		return &findResult{
 			context:       ctx,
@@ -136,6 +166,51 @@ func (r *MongoCol) FindListResultByFilter(filter interface{}, opts ...MongodbrFi
 	}
 }
 
+// 根据条件逐条读取记录,每条记录由newItem创建后解码,再交给fn处理
+// fn返回error时停止读取并返回该error
+func (r *MongoCol) StreamByFilter(filter interface{}, newItem func() interface{}, fn func(item interface{}) error, opts ...MongodbrFindOption) (err error) {
+	//设置默认搜索参数
+	findOptions := &MongodbrFindOptions{
+		FindOptions: &options.FindOptions{},
+	}
+	for _, o := range opts {
+		o(findOptions)
+	}
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, findOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "find", filter)
+	defer endSpan(span, &err)
+
+	if findOptions.Sort == nil {
+		// if sort is nil,then set default sort with configuration
+		if r.configuration.setDefaultSort != nil {
+			r.configuration.setDefaultSort(findOptions.FindOptions)
+		}
+	}
+
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	cur, err := r.collection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		item := newItem()
+		if err := cur.Decode(item); err != nil {
+			return err
+		}
+		if err := r.configuration.hookAfterFind(ctx, item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
 // 根据_id列表来查找，返回的是对象的指针
 func (r *MongoCol) FindListByObjectIdList(idList []bson.ObjectID, list interface{}, opts ...MongodbrFindOption) error {
 	return r.FindListByFilter(bson.M{"_id": bson.M{
@@ -150,7 +225,7 @@ func (r *MongoCol) FindOneByObjectId(id bson.ObjectID, v interface{}, opts ...Mo
 }
 
 // 查找一条记录
-func (r *MongoCol) FindOne(filter interface{}, v interface{}, opts ...MongodbrFindOneOption) error {
+func (r *MongoCol) FindOne(filter interface{}, v interface{}, opts ...MongodbrFindOneOption) (err error) {
 	//设置默认搜索参数
 	mOptions := &MongodbrFindOneOptions{
 		FindOneOptions: &options.FindOneOptions{},
@@ -161,9 +236,13 @@ func (r *MongoCol) FindOne(filter interface{}, v interface{}, opts ...MongodbrFi
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, mOptions.WithCtx)
 	defer cancel()
 
+	ctx, span := r.startSpan(ctx, "findOne", filter)
+	defer endSpan(span, &err)
+
 	// find one
-	res := r.collection.FindOne(ctx, filter, mOptions)
-	err := res.Err()
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	res := r.collection().FindOne(ctx, filter, mOptions)
+	err = res.Err()
 	if err != nil {
 		return err
 	}
@@ -179,14 +258,18 @@ func (r *MongoCol) FindOne(filter interface{}, v interface{}, opts ...MongodbrFi
 	return nil
 }
 
-func (r *MongoCol) Distinct(fieldName string, filter interface{}, opts ...*WithContextOptions) ([]interface{}, error) {
+func (r *MongoCol) Distinct(fieldName string, filter interface{}, opts ...*WithContextOptions) (_ []interface{}, err error) {
 	// handle options
 	cOptions := NewWithContextOptions().MergeWithContextOptions(opts...)
 	// handle context
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, cOptions.WithCtx)
 	defer cancel()
 
-	result := r.collection.Distinct(ctx, fieldName, filter)
+	ctx, span := r.startSpan(ctx, "distinct", filter)
+	defer endSpan(span, &err)
+
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+	result := r.collection().Distinct(ctx, fieldName, filter)
 	values := make([]interface{}, 0)
 	if err := result.Decode(&values); err != nil {
 		return nil, err
@@ -24,7 +24,7 @@ var _ IEntityIndex = (*MongoCol)(nil)
 func (r *MongoCol) CreateIndex(indexModel mongo.IndexModel, opts ...*options.CreateIndexesOptions) (string, error) {
 	ctx, cancel := CreateContextAndCancel(r.configuration)
 	defer cancel()
-	name, err := r.collection.Indexes().CreateOne(ctx, indexModel, asOptionListers(opts)...)
+	name, err := r.collection().Indexes().CreateOne(ctx, indexModel, asOptionListers(opts)...)
 	if err != nil {
 		return "", err
 	}
@@ -68,7 +68,7 @@ func (r *MongoCol) CreateIndexes(indexModelList []mongo.IndexModel, opts ...*opt
 		notExistList = append(notExistList, eachIndexModel)
 	}
 	if len(notExistList) > 0 {
-		return r.collection.Indexes().CreateMany(ctx, indexModelList, asOptionListers(opts)...)
+		return r.collection().Indexes().CreateMany(ctx, indexModelList, asOptionListers(opts)...)
 	}
 	return []string{}, nil
 }
@@ -85,7 +85,7 @@ func (r *MongoCol) DeleteIndex(name string) (err error) {
 	ctx, cancel := CreateContextAndCancel(r.configuration)
 	defer cancel()
 
-	err = r.collection.Indexes().DropOne(ctx, name)
+	err = r.collection().Indexes().DropOne(ctx, name)
 	if err != nil {
 		return err
 	}
@@ -96,7 +96,7 @@ func (r *MongoCol) DeleteAllIndexes() (err error) {
 	ctx, cancel := CreateContextAndCancel(r.configuration)
 	defer cancel()
 
-	err = r.collection.Indexes().DropAll(ctx)
+	err = r.collection().Indexes().DropAll(ctx)
 	if err != nil {
 		return err
 	}
@@ -107,7 +107,7 @@ func (r *MongoCol) ListIndexes() (indexes []map[string]interface{}, err error) {
 	ctx, cancel := CreateContextAndCancel(r.configuration)
 	defer cancel()
 
-	cur, err := r.collection.Indexes().List(ctx)
+	cur, err := r.collection().Indexes().List(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -1,8 +1,12 @@
 package mongodbr
 
 import (
+	"context"
+	"errors"
+
 	"github.com/abmpio/mongodbr/builder"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
@@ -18,29 +22,97 @@ var _ IEntityUpdate = (*MongoCol)(nil)
 
 // #region update members
 
+// update entity by _id with $set,
+// if entity implements IVersionedEntity, only the expected version is matched and the version is incremented,
+// ErrConcurrencyConflict is returned when no document matched, and upsert of options is ignored
 func (r *MongoCol) FindOneAndUpdate(entity IEntity, opts ...MongodbrFindOneAndUpdateOption) error {
-	objectId := entity.GetObjectId()
-	update := builder.NewBsonBuilder().NewOrUpdateSet(entity).ToValue()
-	return r.FindOneAndUpdateWithId(objectId, update, opts...)
+	return r.UpdateItemById(entity.GetObjectId(), entity, opts...)
 }
 
 func (r *MongoCol) FindOneAndUpdateWithId(objectId bson.ObjectID, update interface{}, opts ...MongodbrFindOneAndUpdateOption) error {
-	uOptions := &options.FindOneAndUpdateOptions{Upsert: ptr(false)}
+	return r.UpdateOneById(objectId, update, opts...)
+}
 
-	// handle options
+// update item by id with $set, id can be of any type, same as FindOneAndUpdate
+func (r *MongoCol) UpdateItemById(id interface{}, item interface{}, opts ...MongodbrFindOneAndUpdateOption) (err error) {
+	mongodbrUOptions := newFindOneAndUpdateOptions(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, mongodbrUOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "findOneAndUpdate", bson.M{"_id": id})
+	defer endSpan(span, &err)
+
+	filter := bson.M{"_id": id}
+	if err := r.configuration.hookBeforeUpdate(ctx, filter, item); err != nil {
+		return err
+	}
+	if err := r.configuration.validate(item); err != nil {
+		return err
+	}
+	versionedEntity, ok := item.(IVersionedEntity)
+	if !ok {
+		update := builder.NewBsonBuilder().NewOrUpdateSet(item).ToValue()
+		if err := r.findOneAndUpdate(ctx, filter, update, mongodbrUOptions); err != nil {
+			return err
+		}
+		return r.configuration.hookAfterUpdate(ctx, filter, item)
+	}
+
+	expectedVersion := versionedEntity.GetVersion()
+	versionedEntity.SetVersion(expectedVersion + 1)
+	update := builder.NewBsonBuilder().NewOrUpdateSet(item).ToValue()
+	versionFilter := bson.D{{Key: "_id", Value: id}, VersionFilter(expectedVersion)}
+	err = r.findOneAndUpdate(ctx, versionFilter, update, mongodbrUOptions.withoutUpsert())
+	if err != nil {
+		versionedEntity.SetVersion(expectedVersion)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrConcurrencyConflict
+		}
+		return err
+	}
+	return r.configuration.hookAfterUpdate(ctx, filter, item)
+}
+
+// update the document by id with update document, id can be of any type,
+// mongo.ErrNoDocuments is returned when no document matched,
+// the update document is audited only if the repository is created WithUpdateAudit
+func (r *MongoCol) UpdateOneById(id interface{}, update interface{}, opts ...MongodbrFindOneAndUpdateOption) (err error) {
+	mongodbrUOptions := newFindOneAndUpdateOptions(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, mongodbrUOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "findOneAndUpdate", bson.M{"_id": id})
+	defer endSpan(span, &err)
+
+	filter := bson.M{"_id": id}
+	if err := r.configuration.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return err
+	}
+	auditedUpdate, err := r.auditUpdate(ctx, update)
+	if err != nil {
+		return err
+	}
+	if err := r.findOneAndUpdate(ctx, filter, auditedUpdate, mongodbrUOptions); err != nil {
+		return err
+	}
+	return r.configuration.hookAfterUpdate(ctx, filter, update)
+}
+
+// merge options, upsert is false by default
+func newFindOneAndUpdateOptions(opts ...MongodbrFindOneAndUpdateOption) *MongodbrFindOneAndUpdateOptions {
 	mongodbrUOptions := &MongodbrFindOneAndUpdateOptions{
-		FindOneAndUpdateOptions: uOptions,
+		FindOneAndUpdateOptions: &options.FindOneAndUpdateOptions{Upsert: ptr(false)},
 	}
 	for _, eachOpt := range opts {
 		eachOpt(mongodbrUOptions)
 	}
-	// handle context
-	ctx, cancel := CreateContextAndCancelWith(r.configuration, mongodbrUOptions.WithCtx)
-	defer cancel()
+	return mongodbrUOptions
+}
 
-	if err := r.collection.FindOneAndUpdate(
+func (r *MongoCol) findOneAndUpdate(ctx context.Context, filter interface{}, update interface{}, mongodbrUOptions *MongodbrFindOneAndUpdateOptions) error {
+	if err := r.collection().FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": objectId},
+		filter,
 		update,
 		mongodbrUOptions,
 	).Err(); err != nil {
@@ -50,7 +122,8 @@ func (r *MongoCol) FindOneAndUpdateWithId(objectId bson.ObjectID, update interfa
 	return nil
 }
 
-func (r *MongoCol) UpdateOne(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) error {
+// update one document matched filter, the update document is audited only if the repository is created WithUpdateAudit
+func (r *MongoCol) UpdateOne(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) (err error) {
 	// handle options
 	uOptions := &MongodbrUpdateOptions{
 		UpdateOneOptions:  &options.UpdateOneOptions{},
@@ -63,15 +136,26 @@ func (r *MongoCol) UpdateOne(filter interface{}, update interface{}, opts ...Mon
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, uOptions.WithCtx)
 	defer cancel()
 
-	_, err := r.collection.UpdateOne(ctx, filter, update, asOptionLister(uOptions.UpdateOneOptions))
+	ctx, span := r.startSpan(ctx, "updateOne", filter)
+	defer endSpan(span, &err)
+
+	if err := r.configuration.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return err
+	}
+	auditedUpdate, err := r.auditUpdate(ctx, update)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection().UpdateOne(ctx, filter, auditedUpdate, asOptionLister(uOptions.UpdateOneOptions))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return r.configuration.hookAfterUpdate(ctx, filter, update)
 }
 
-func (r *MongoCol) UpdateMany(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) (interface{}, error) {
+// update all documents matched filter, the update document is audited only if the repository is created WithUpdateAudit
+func (r *MongoCol) UpdateMany(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) (_ interface{}, err error) {
 	// handle options
 	uOptions := &MongodbrUpdateOptions{
 		UpdateOneOptions:  &options.UpdateOneOptions{},
@@ -84,7 +168,17 @@ func (r *MongoCol) UpdateMany(filter interface{}, update interface{}, opts ...Mo
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, uOptions.WithCtx)
 	defer cancel()
 
-	result, err := r.collection.UpdateMany(ctx, filter, update, asOptionLister(uOptions.UpdateManyOptions))
+	ctx, span := r.startSpan(ctx, "updateMany", filter)
+	defer endSpan(span, &err)
+
+	if err := r.configuration.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	auditedUpdate, err := r.auditUpdate(ctx, update)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.collection().UpdateMany(ctx, filter, auditedUpdate, asOptionLister(uOptions.UpdateManyOptions))
 	if err != nil {
 		if result != nil {
 			return result.UpsertedID, err
@@ -93,10 +187,14 @@ func (r *MongoCol) UpdateMany(filter interface{}, update interface{}, opts ...Mo
 		}
 	}
 
+	var upsertedID interface{}
 	if result != nil {
-		return result.UpsertedID, nil
+		upsertedID = result.UpsertedID
+	}
+	if err := r.configuration.hookAfterUpdate(ctx, filter, update); err != nil {
+		return upsertedID, err
 	}
-	return nil, nil
+	return upsertedID, nil
 }
 
 // #endregion
@@ -5,4 +5,13 @@ import "errors"
 var (
 	ErrInvalidType = errors.New("invalid type")
 	ErrNoCursor    = errors.New("no cursor")
+	ErrNilItem     = errors.New("item is nil")
+	// filter is required, such as by HardDelete
+	ErrNilFilter = errors.New("filter is nil")
+	// versioned entity has been modified by others
+	ErrConcurrencyConflict = errors.New("concurrency conflict")
+	// UnitOfWork.Commit is called while another Commit is running
+	ErrUnitOfWorkCommitting = errors.New("unit of work is committing")
+	// client is not registered
+	ErrClientNotFound = errors.New("mongodb client not found")
 )
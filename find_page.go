@@ -0,0 +1,178 @@
+package mongodbr
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// one page of T
+type PageResult[T any] struct {
+	Items []*T
+	// total count matched filter, -1 if total is skipped
+	Total     int64
+	PageIndex int64
+	PageSize  int64
+	// page count, -1 if total is skipped
+	PageCount int64
+	HasNext   bool
+}
+
+// facet result of FindPage
+type pageFacetResult[T any] struct {
+	Items []*T `bson:"items"`
+	Total []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+}
+
+// find one page of T by filter, pageIndex starts from 1
+func FindPage[T any](repository IRepository, filter interface{}, pageIndex int64, pageSize int64, opts ...MongodbrFindPageOption) (*PageResult[T], error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be greater than 0")
+	}
+	if pageIndex < 1 {
+		pageIndex = 1
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	pOptions := MergeMongodbrFindPageOption(opts...)
+	result := &PageResult[T]{
+		PageIndex: pageIndex,
+		PageSize:  pageSize,
+	}
+
+	var err error
+	switch {
+	case pOptions.SkipTotal:
+		err = findPageWithoutTotal(repository, filter, result, pOptions)
+	case pOptions.UseFacet:
+		err = findPageWithFacet(repository, filter, result, pOptions)
+	default:
+		err = findPageWithCount(repository, filter, result, pOptions)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Items == nil {
+		result.Items = make([]*T, 0)
+	}
+	return result, nil
+}
+
+// count total and find items with two queries
+func findPageWithCount[T any](repository IRepository, filter interface{}, result *PageResult[T], pOptions *MongodbrFindPageOptions) error {
+	total, err := repository.CountByFilter(filter, MongodbrCountOptionWithContext(pOptions.WithCtx))
+	if err != nil {
+		return err
+	}
+	result.setTotal(total)
+	if result.skip() >= total {
+		// page is out of range, no need to query
+		return nil
+	}
+	result.Items = make([]*T, 0)
+	return repository.FindListByFilter(filter, &result.Items, pOptions.findOptionList(result.skip(), result.PageSize)...)
+}
+
+// find pageSize+1 items to check if there is a next page
+func findPageWithoutTotal[T any](repository IRepository, filter interface{}, result *PageResult[T], pOptions *MongodbrFindPageOptions) error {
+	result.Total = -1
+	result.PageCount = -1
+	result.Items = make([]*T, 0)
+	err := repository.FindListByFilter(filter, &result.Items, pOptions.findOptionList(result.skip(), result.PageSize+1)...)
+	if err != nil {
+		return err
+	}
+	if int64(len(result.Items)) > result.PageSize {
+		result.Items = result.Items[:result.PageSize]
+		result.HasNext = true
+	}
+	return nil
+}
+
+// compute items and total with one $facet aggregation
+func findPageWithFacet[T any](repository IRepository, filter interface{}, result *PageResult[T], pOptions *MongodbrFindPageOptions) error {
+	itemsPipeline := bson.A{}
+	sort := pOptions.Sort
+	if sort == nil {
+		// same as the default sort applied by FindListByFilter
+		if sorter, ok := repository.(interface{ defaultSort() interface{} }); ok {
+			sort = sorter.defaultSort()
+		}
+	}
+	if sort != nil {
+		itemsPipeline = append(itemsPipeline, bson.D{{Key: "$sort", Value: sort}})
+	}
+	itemsPipeline = append(itemsPipeline,
+		bson.D{{Key: "$skip", Value: result.skip()}},
+		bson.D{{Key: "$limit", Value: result.PageSize}},
+	)
+	if pOptions.Projection != nil {
+		itemsPipeline = append(itemsPipeline, bson.D{{Key: "$project", Value: pOptions.Projection}})
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: itemsPipeline},
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+		}}},
+	}
+
+	facetList := make([]*pageFacetResult[T], 0)
+	err := repository.Aggregate(pipeline, &facetList,
+		MongodbrAggregateOptionWithContext(pOptions.WithCtx),
+		MongodbrAggregateOptionWithoutAfterFindHook())
+	if err != nil {
+		return err
+	}
+	var total int64
+	if len(facetList) > 0 {
+		result.Items = facetList[0].Items
+		if len(facetList[0].Total) > 0 {
+			total = facetList[0].Total[0].Count
+		}
+	}
+	// the hooks are skipped for the facet document, invoke them for the items
+	if hookable, ok := repository.(IAfterFindHookable); ok {
+		ctx := pOptions.WithCtx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := hookable.AfterFindList(ctx, result.Items); err != nil {
+			return err
+		}
+	}
+	result.setTotal(total)
+	return nil
+}
+
+func (r *PageResult[T]) skip() int64 {
+	return r.PageSize * (r.PageIndex - 1)
+}
+
+func (r *PageResult[T]) setTotal(total int64) {
+	r.Total = total
+	r.PageCount = (total + r.PageSize - 1) / r.PageSize
+	r.HasNext = r.PageIndex < r.PageCount
+}
+
+func (o *MongodbrFindPageOptions) findOptionList(skip int64, limit int64) []MongodbrFindOption {
+	return []MongodbrFindOption{
+		MongodbrFindOptionWithContext(o.WithCtx),
+		func(fo *MongodbrFindOptions) {
+			fo.ensureFindOptionsInit()
+			if o.Sort != nil {
+				fo.Sort = o.Sort
+			}
+			if o.Projection != nil {
+				fo.Projection = o.Projection
+			}
+			fo.Skip = ptr(skip)
+			fo.Limit = ptr(limit)
+		},
+	}
+}
@@ -35,7 +35,10 @@ func (r *findResult) One(val interface{}) (err error) {
 		return r.err
 	}
 	if r.cur == nil {
-		return r.res.Decode(val)
+		if err := r.res.Decode(val); err != nil {
+			return err
+		}
+		return r.configuration.hookAfterFind(r.hookContext(), val)
 	}
 
 	//没有设置参数，使用默认的
@@ -51,7 +54,10 @@ func (r *findResult) One(val interface{}) (err error) {
 	if !r.cur.TryNext(ctx) {
 		return mongo.ErrNoDocuments
 	}
-	return r.cur.Decode(val)
+	if err := r.cur.Decode(val); err != nil {
+		return err
+	}
+	return r.configuration.hookAfterFind(ctx, val)
 }
 
 func (r *findResult) ToOne() (interface{}, error) {
@@ -90,7 +96,10 @@ func (r *findResult) All(val interface{}) (err error) {
 		}
 		return nil // no data, no err
 	}
-	return r.cur.All(ctx, val)
+	if err := r.cur.All(ctx, val); err != nil {
+		return err
+	}
+	return r.configuration.hookAfterFindList(ctx, val)
 }
 
 func (r *findResult) ToAll() ([]interface{}, error) {
@@ -117,6 +126,9 @@ func (r *findResult) ToAll() ([]interface{}, error) {
 		if err := r.cur.Decode(o); err != nil {
 			return nil, err
 		}
+		if err := r.configuration.hookAfterFind(ctx, o); err != nil {
+			return nil, err
+		}
 		result = append(result, o)
 	}
 	return result, nil
@@ -127,6 +139,14 @@ func (r *findResult) GetContext() context.Context {
 	return r.context
 }
 
+// context passed to hooks
+func (r *findResult) hookContext() context.Context {
+	if r.context == nil {
+		return context.Background()
+	}
+	return r.context
+}
+
 func (r *findResult) GetSingleResult() (res *mongo.SingleResult) {
 	return r.res
 }
@@ -1,11 +1,12 @@
 module github.com/abmpio/mongodbr
 
-go 1.19
+go 1.21
 
 require (
 	github.com/abmpio/libx v0.0.0-20251025150424-a9353a6e248f
 	github.com/satori/go.uuid v1.2.0
 	go.mongodb.org/mongo-driver/v2 v2.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
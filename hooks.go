@@ -0,0 +1,312 @@
+package mongodbr
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// entity hooks with context, a returned error aborts the operation,
+// the error of After* hook is returned after the document has been written
+
+type IEntityAfterCreate interface {
+	AfterCreate(ctx context.Context) error
+}
+
+type IEntityAfterUpdate interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+type IEntityBeforeReplace interface {
+	BeforeReplace(ctx context.Context) error
+}
+
+type IEntityAfterFind interface {
+	AfterFind(ctx context.Context) error
+}
+
+// hooks of repository, nil func is ignored, a returned error aborts the operation.
+// update is the entity when updating by entity(FindOneAndUpdate,BulkWriteEntityList),
+// otherwise it's the update document
+type RepositoryHooks struct {
+	BeforeCreate  func(ctx context.Context, item interface{}) error
+	AfterCreate   func(ctx context.Context, item interface{}) error
+	BeforeUpdate  func(ctx context.Context, filter interface{}, update interface{}) error
+	AfterUpdate   func(ctx context.Context, filter interface{}, update interface{}) error
+	BeforeReplace func(ctx context.Context, filter interface{}, doc interface{}) error
+	BeforeDelete  func(ctx context.Context, filter interface{}) error
+	// result is nil when deleted by BulkWrite
+	AfterDelete func(ctx context.Context, filter interface{}, result *mongo.DeleteResult) error
+	AfterFind   func(ctx context.Context, item interface{}) error
+}
+
+// add repository hooks, hooks are invoked in the order they are added
+func WithHooks(hooks ...*RepositoryHooks) RepositoryOption {
+	return func(configuration *Configuration) {
+		for _, eachHooks := range hooks {
+			if eachHooks != nil {
+				configuration.hooks = append(configuration.hooks, eachHooks)
+			}
+		}
+	}
+}
+
+// repository which invokes AfterFind hooks for the items decoded outside of it,
+// such as the items of $facet result which is aggregated with MongodbrAggregateOptionWithoutAfterFindHook
+type IAfterFindHookable interface {
+	AfterFindList(ctx context.Context, list interface{}) error
+}
+
+var _ IAfterFindHookable = (*MongoCol)(nil)
+
+var _entityAfterFindType = reflect.TypeOf((*IEntityAfterFind)(nil)).Elem()
+
+// #region hook invokers
+
+func (c *Configuration) hookBeforeCreate(ctx context.Context, item interface{}) error {
+	// assign _id and creation time before BeforeCreate of entity, which only fills the empty ones
+	if err := c.fillCreationDefaults(item); err != nil {
+		return err
+	}
+	if entityHookable, ok := item.(IEntityBeforeCreate); ok {
+		entityHookable.BeforeCreate()
+	}
+	c.fillCreationAudit(ctx, item)
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.BeforeCreate == nil {
+			continue
+		}
+		if err := eachHooks.BeforeCreate(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookAfterCreate(ctx context.Context, item interface{}) error {
+	if entityHookable, ok := item.(IEntityAfterCreate); ok {
+		if err := entityHookable.AfterCreate(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.AfterCreate == nil {
+			continue
+		}
+		if err := eachHooks.AfterCreate(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookBeforeUpdate(ctx context.Context, filter interface{}, update interface{}) error {
+	if entityHookable, ok := update.(IEntityBeforeUpdate); ok {
+		entityHookable.BeforeUpdate()
+	}
+	c.fillModificationTime(update)
+	c.fillModificationAudit(ctx, update)
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.BeforeUpdate == nil {
+			continue
+		}
+		if err := eachHooks.BeforeUpdate(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookAfterUpdate(ctx context.Context, filter interface{}, update interface{}) error {
+	if entityHookable, ok := update.(IEntityAfterUpdate); ok {
+		if err := entityHookable.AfterUpdate(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.AfterUpdate == nil {
+			continue
+		}
+		if err := eachHooks.AfterUpdate(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replace is one kind of update, so BeforeUpdate of entity is invoked too
+func (c *Configuration) hookBeforeReplace(ctx context.Context, filter interface{}, doc interface{}) error {
+	if entityHookable, ok := doc.(IEntityBeforeUpdate); ok {
+		entityHookable.BeforeUpdate()
+	}
+	c.fillModificationTime(doc)
+	c.fillModificationAudit(ctx, doc)
+	if entityHookable, ok := doc.(IEntityBeforeReplace); ok {
+		if err := entityHookable.BeforeReplace(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.BeforeReplace == nil {
+			continue
+		}
+		if err := eachHooks.BeforeReplace(ctx, filter, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookBeforeDelete(ctx context.Context, filter interface{}) error {
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.BeforeDelete == nil {
+			continue
+		}
+		if err := eachHooks.BeforeDelete(ctx, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookAfterDelete(ctx context.Context, filter interface{}, result *mongo.DeleteResult) error {
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.AfterDelete == nil {
+			continue
+		}
+		if err := eachHooks.AfterDelete(ctx, filter, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hookAfterFind(ctx context.Context, item interface{}) error {
+	if entityHookable, ok := item.(IEntityAfterFind); ok {
+		if err := entityHookable.AfterFind(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.AfterFind == nil {
+			continue
+		}
+		if err := eachHooks.AfterFind(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invoke AfterFind hooks for every item of list, list is a slice or a pointer to slice
+func (c *Configuration) hookAfterFindList(ctx context.Context, list interface{}) error {
+	listValue := reflect.ValueOf(list)
+	for listValue.Kind() == reflect.Ptr && !listValue.IsNil() {
+		listValue = listValue.Elem()
+	}
+	if listValue.Kind() != reflect.Slice && listValue.Kind() != reflect.Array {
+		return nil
+	}
+	if !c.hasAfterFindHook() {
+		elemType := listValue.Type().Elem()
+		if elemType.Kind() != reflect.Interface &&
+			!elemType.Implements(_entityAfterFindType) &&
+			!reflect.PointerTo(elemType).Implements(_entityAfterFindType) {
+			// nothing to invoke
+			return nil
+		}
+	}
+	for i := 0; i < listValue.Len(); i++ {
+		elem := listValue.Index(i)
+		var item interface{}
+		switch {
+		case elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface:
+			if elem.IsNil() {
+				continue
+			}
+			item = elem.Interface()
+		case elem.CanAddr():
+			item = elem.Addr().Interface()
+		default:
+			item = elem.Interface()
+		}
+		if err := c.hookAfterFind(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) hasAfterFindHook() bool {
+	for _, eachHooks := range c.getHooks() {
+		if eachHooks.AfterFind != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Configuration) getHooks() []*RepositoryHooks {
+	if c == nil {
+		return nil
+	}
+	return c.hooks
+}
+
+// invoke AfterFind hooks for every item of list, list is a slice or a pointer to slice
+func (r *MongoCol) AfterFindList(ctx context.Context, list interface{}) error {
+	return r.configuration.hookAfterFindList(ctx, list)
+}
+
+// invoke before hooks of write models, InsertOne,UpdateOne,UpdateMany,ReplaceOne,DeleteOne and DeleteMany models are supported
+func (c *Configuration) hookBeforeWriteModels(ctx context.Context, models []mongo.WriteModel) error {
+	for _, eachModel := range models {
+		var err error
+		switch m := eachModel.(type) {
+		case *mongo.InsertOneModel:
+			err = c.hookBeforeCreate(ctx, m.Document)
+		case *mongo.UpdateOneModel:
+			err = c.hookBeforeUpdate(ctx, m.Filter, m.Update)
+		case *mongo.UpdateManyModel:
+			err = c.hookBeforeUpdate(ctx, m.Filter, m.Update)
+		case *mongo.ReplaceOneModel:
+			err = c.hookBeforeReplace(ctx, m.Filter, m.Replacement)
+		case *mongo.DeleteOneModel:
+			err = c.hookBeforeDelete(ctx, m.Filter)
+		case *mongo.DeleteManyModel:
+			err = c.hookBeforeDelete(ctx, m.Filter)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invoke after hooks of write models
+func (c *Configuration) hookAfterWriteModels(ctx context.Context, models []mongo.WriteModel) error {
+	for _, eachModel := range models {
+		var err error
+		switch m := eachModel.(type) {
+		case *mongo.InsertOneModel:
+			err = c.hookAfterCreate(ctx, m.Document)
+		case *mongo.UpdateOneModel:
+			err = c.hookAfterUpdate(ctx, m.Filter, m.Update)
+		case *mongo.UpdateManyModel:
+			err = c.hookAfterUpdate(ctx, m.Filter, m.Update)
+		case *mongo.ReplaceOneModel:
+			err = c.hookAfterUpdate(ctx, m.Filter, m.Replacement)
+		case *mongo.DeleteOneModel:
+			err = c.hookAfterDelete(ctx, m.Filter, nil)
+		case *mongo.DeleteManyModel:
+			err = c.hookAfterDelete(ctx, m.Filter, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// #endregion
@@ -0,0 +1,310 @@
+package mongodbr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// generate _id for new entities
+type IdGenerator interface {
+	NewId() interface{}
+}
+
+// adapt a func to IdGenerator
+type IdGeneratorFunc func() interface{}
+
+func (f IdGeneratorFunc) NewId() interface{} {
+	return f()
+}
+
+// entity whose _id can be assigned by IdGenerator
+type IEntityIdSetter interface {
+	// is _id empty
+	IsIdEmpty() bool
+	// set _id, return error if the type of id is not supported
+	SetId(id interface{}) error
+}
+
+// IdGenerator whose ids contain the current time,
+// the Clock of repository is passed to NewIdAt if the repository is created with WithClock
+type ITimedIdGenerator interface {
+	IdGenerator
+	// generate id at now
+	NewIdAt(now time.Time) interface{}
+}
+
+var (
+	// generate bson.ObjectID
+	ObjectIdGenerator IdGenerator = IdGeneratorFunc(func() interface{} {
+		return bson.NewObjectID()
+	})
+	// generate random uuid.UUID, which is stored as binary subtype 4
+	UUIDv4Generator IdGenerator = IdGeneratorFunc(func() interface{} {
+		return uuid.NewV4()
+	})
+	// generate time ordered uuid.UUID with DefaultClock, which is stored as binary subtype 4
+	UUIDv7Generator IdGenerator = &UUIDv7IdGenerator{}
+	// generate time ordered ULID string with DefaultClock
+	ULIDGenerator IdGenerator = &ULIDIdGenerator{}
+)
+
+var (
+	_defaultIdGeneratorLock sync.RWMutex
+	_defaultIdGenerator     = ObjectIdGenerator
+)
+
+// set the global IdGenerator, which is used by repositories without IdGenerator
+func SetDefaultIdGenerator(generator IdGenerator) {
+	if generator == nil {
+		generator = ObjectIdGenerator
+	}
+	_defaultIdGeneratorLock.Lock()
+	defer _defaultIdGeneratorLock.Unlock()
+
+	_defaultIdGenerator = generator
+}
+
+// get the global IdGenerator
+func DefaultIdGenerator() IdGenerator {
+	_defaultIdGeneratorLock.RLock()
+	defer _defaultIdGeneratorLock.RUnlock()
+
+	return _defaultIdGenerator
+}
+
+// set IdGenerator of repository,
+// the _id of IEntity such as Entity is always bson.ObjectID, creating it fails with ErrInvalidType
+// if generator does not generate bson.ObjectID, use KeyedEntity[K] for the ids of other types
+func WithIdGenerator(generator IdGenerator) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.idGenerator = generator
+	}
+}
+
+// generate a new id with the IdGenerator of repository, or the global IdGenerator,
+// the Clock of repository is used by ITimedIdGenerator
+func (c *Configuration) NewId() interface{} {
+	generator := DefaultIdGenerator()
+	if c != nil && c.idGenerator != nil {
+		generator = c.idGenerator
+	}
+	if timedGenerator, ok := generator.(ITimedIdGenerator); ok && c != nil && c.clock != nil {
+		return timedGenerator.NewIdAt(c.clock.Now())
+	}
+	return generator.NewId()
+}
+
+// now of clock, or DefaultClock if clock is nil
+func clockNow(clock Clock) time.Time {
+	if clock != nil {
+		return clock.Now()
+	}
+	return DefaultClock().Now()
+}
+
+// #region uuid v7
+
+// generate time ordered uuid.UUID, which is stored as binary subtype 4
+type UUIDv7IdGenerator struct {
+	// DefaultClock is used if nil
+	Clock Clock
+}
+
+var _ ITimedIdGenerator = (*UUIDv7IdGenerator)(nil)
+
+func (g *UUIDv7IdGenerator) NewId() interface{} {
+	return newUUIDv7(clockNow(g.Clock))
+}
+
+func (g *UUIDv7IdGenerator) NewIdAt(now time.Time) interface{} {
+	return newUUIDv7(now)
+}
+
+func newUUIDv7(now time.Time) uuid.UUID {
+	var u uuid.UUID
+	_, _ = rand.Read(u[6:])
+	ms := uint64(now.UnixMilli())
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	// version 7 and RFC 4122 variant
+	u[6] = (u[6] & 0x0f) | 0x70
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// #endregion
+
+// #region ulid
+
+// generate time ordered ULID string
+type ULIDIdGenerator struct {
+	// DefaultClock is used if nil
+	Clock Clock
+}
+
+var _ ITimedIdGenerator = (*ULIDIdGenerator)(nil)
+
+func (g *ULIDIdGenerator) NewId() interface{} {
+	return newULID(clockNow(g.Clock))
+}
+
+func (g *ULIDIdGenerator) NewIdAt(now time.Time) interface{} {
+	return newULID(now)
+}
+
+const _crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// 26 chars ULID, 48 bits milliseconds followed by 80 random bits
+func newULID(now time.Time) string {
+	var data [16]byte
+	ms := uint64(now.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		data[i] = byte(ms)
+		ms >>= 8
+	}
+	_, _ = rand.Read(data[6:])
+
+	hi := binary.BigEndian.Uint64(data[:8])
+	lo := binary.BigEndian.Uint64(data[8:])
+	var sb strings.Builder
+	sb.Grow(26)
+	// 128 bits are encoded as 26 chars of 5 bits, the first char has 3 bits
+	for i := 25; i >= 0; i-- {
+		shift := uint(i * 5)
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift > 59:
+			v = (lo >> shift) | (hi << (64 - shift))
+		default:
+			v = lo >> shift
+		}
+		sb.WriteByte(_crockfordAlphabet[v&0x1f])
+	}
+	return sb.String()
+}
+
+// #endregion
+
+// #region snowflake
+
+const (
+	_snowflakeNodeBits     = 10
+	_snowflakeSequenceBits = 12
+	_snowflakeMaxNode      = -1 ^ (-1 << _snowflakeNodeBits)
+	_snowflakeMaxSequence  = -1 ^ (-1 << _snowflakeSequenceBits)
+)
+
+// default epoch of snowflake, 2020-01-01 UTC
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// generate snowflake style int64 ids,
+// 41 bits milliseconds since epoch, 10 bits node id and 12 bits sequence
+type SnowflakeGenerator struct {
+	lock     sync.Mutex
+	epoch    int64
+	node     int64
+	clock    Clock
+	lastMs   int64
+	sequence int64
+}
+
+var _ ITimedIdGenerator = (*SnowflakeGenerator)(nil)
+
+// new SnowflakeGenerator with node id between 0 and 1023
+func NewSnowflakeGenerator(node int64) (*SnowflakeGenerator, error) {
+	return NewSnowflakeGeneratorWithClock(node, nil)
+}
+
+// new SnowflakeGenerator with node id between 0 and 1023 and clock, DefaultClock is used if clock is nil
+func NewSnowflakeGeneratorWithClock(node int64, clock Clock) (*SnowflakeGenerator, error) {
+	if node < 0 || node > _snowflakeMaxNode {
+		return nil, fmt.Errorf("snowflake node must be between 0 and %d, but got %d", _snowflakeMaxNode, node)
+	}
+	return &SnowflakeGenerator{
+		epoch: SnowflakeEpoch.UnixMilli(),
+		node:  node,
+		clock: clock,
+	}, nil
+}
+
+func (g *SnowflakeGenerator) NewId() interface{} {
+	return g.Next()
+}
+
+func (g *SnowflakeGenerator) NewIdAt(now time.Time) interface{} {
+	return g.NextAt(now)
+}
+
+// generate next id with the clock of generator
+func (g *SnowflakeGenerator) Next() int64 {
+	return g.NextAt(clockNow(g.clock))
+}
+
+// generate next id at now, ids keep increasing even if now does not,
+// the next millisecond is used if the sequence of current millisecond is exhausted
+func (g *SnowflakeGenerator) NextAt(now time.Time) int64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	ms := now.UnixMilli()
+	if ms < g.lastMs {
+		// clock moved backwards, keep increasing from the last millisecond
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & _snowflakeMaxSequence
+		if g.sequence == 0 {
+			ms = g.lastMs + 1
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+	return (ms-g.epoch)<<(_snowflakeNodeBits+_snowflakeSequenceBits) |
+		g.node<<_snowflakeSequenceBits |
+		g.sequence
+}
+
+// #endregion
+
+// assign _id with IdGenerator and creation time with Clock if they are empty
+func (c *Configuration) fillCreationDefaults(item interface{}) error {
+	if setter, ok := item.(IEntityIdSetter); ok && setter.IsIdEmpty() {
+		id := c.NewId()
+		if _, ok := item.(IEntity); ok {
+			// _id of IEntity is always bson.ObjectID
+			if _, ok := id.(bson.ObjectID); !ok {
+				return fmt.Errorf("%w: IdGenerator generates %T, but _id of %T must be bson.ObjectID, use KeyedEntity[K] for the ids of other types", ErrInvalidType, id, item)
+			}
+		}
+		if err := setter.SetId(id); err != nil {
+			return err
+		}
+	}
+	if setter, ok := item.(ICreationTimeSetter); ok {
+		if entity, ok := item.(ICreationAuditedEntity); !ok || entity.GetCreationTime().IsZero() {
+			setter.SetCreationTime(c.Now())
+		}
+	}
+	return nil
+}
+
+// set last modification time with Clock
+func (c *Configuration) fillModificationTime(item interface{}) {
+	if setter, ok := item.(IModificationTimeSetter); ok {
+		setter.SetLastModificationTime(c.Now())
+	}
+}
@@ -0,0 +1,88 @@
+package mongodbr
+
+import (
+	"errors"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestFillCreationDefaultsId(t *testing.T) {
+	snowflake, err := NewSnowflakeGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		generator IdGenerator
+		item      IEntityIdSetter
+		wantErr   error
+		check     func(item IEntityIdSetter) bool
+	}{
+		{
+			name:      "object id for Entity",
+			generator: ObjectIdGenerator,
+			item:      &Entity{},
+			check:     func(item IEntityIdSetter) bool { return !item.(*Entity).ObjectId.IsZero() },
+		},
+		{
+			name:      "uuid for Entity",
+			generator: UUIDv7Generator,
+			item:      &Entity{},
+			wantErr:   ErrInvalidType,
+		},
+		{
+			name:      "ulid for Entity",
+			generator: ULIDGenerator,
+			item:      &Entity{},
+			wantErr:   ErrInvalidType,
+		},
+		{
+			name:      "snowflake for Entity",
+			generator: snowflake,
+			item:      &Entity{},
+			wantErr:   ErrInvalidType,
+		},
+		{
+			name:      "uuid for KeyedEntity",
+			generator: UUIDv4Generator,
+			item:      &KeyedEntity[uuid.UUID]{},
+			check:     func(item IEntityIdSetter) bool { return item.(*KeyedEntity[uuid.UUID]).Id != uuid.Nil },
+		},
+		{
+			name:      "ulid for KeyedEntity",
+			generator: ULIDGenerator,
+			item:      &KeyedEntity[string]{},
+			check:     func(item IEntityIdSetter) bool { return len(item.(*KeyedEntity[string]).Id) == 26 },
+		},
+		{
+			name:      "id of Entity is kept",
+			generator: UUIDv7Generator,
+			item:      &Entity{ObjectId: bson.NewObjectID()},
+			check:     func(item IEntityIdSetter) bool { return !item.(*Entity).ObjectId.IsZero() },
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configuration := NewConfiguration()
+			WithIdGenerator(c.generator)(configuration)
+			err := configuration.fillCreationDefaults(c.item)
+			if c.wantErr != nil {
+				if !errors.Is(err, c.wantErr) {
+					t.Fatalf("err = %v, want %v", err, c.wantErr)
+				}
+				if !c.item.IsIdEmpty() {
+					t.Error("_id is assigned on error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !c.check(c.item) {
+				t.Errorf("unexpected _id of %+v", c.item)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package mongodbr
+
+import (
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type SyncIndexesOptions struct {
+	// drop the indexes which exist in collection but are not declared
+	DropExtraneous bool
+	// drop and recreate the indexes whose keys or options are changed
+	RebuildChanged bool
+	// only report, do not change anything
+	DryRun bool
+}
+
+type SyncIndexesOption func(*SyncIndexesOptions)
+
+// merge SyncIndexesOption list and return one *SyncIndexesOptions
+func MergeSyncIndexesOption(opts ...SyncIndexesOption) *SyncIndexesOptions {
+	o := &SyncIndexesOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	return o
+}
+
+// drop the indexes which exist in collection but are not declared
+func SyncIndexesOptionWithDropExtraneous() SyncIndexesOption {
+	return func(o *SyncIndexesOptions) {
+		o.DropExtraneous = true
+	}
+}
+
+// drop and recreate the indexes whose keys or options are changed
+func SyncIndexesOptionWithRebuildChanged() SyncIndexesOption {
+	return func(o *SyncIndexesOptions) {
+		o.RebuildChanged = true
+	}
+}
+
+// only report the differences
+func SyncIndexesOptionWithDryRun() SyncIndexesOption {
+	return func(o *SyncIndexesOptions) {
+		o.DryRun = true
+	}
+}
+
+// result of SyncIndexes, all items are index names
+type SyncIndexesResult struct {
+	// declared indexes which do not exist, created unless DryRun
+	Created []string
+	// declared indexes whose keys or options are different from the existing ones
+	Changed []string
+	// changed indexes which have been dropped and recreated
+	Rebuilt []string
+	// existing indexes which are not declared
+	Extraneous []string
+	// extraneous indexes which have been dropped
+	Dropped []string
+}
+
+// synchronize the indexes declared by struct tags of entity with the indexes of collection,
+// see TaggedIndexDefine for the tag syntax
+func SyncIndexes(repository IEntityIndex, entity interface{}, opts ...SyncIndexesOption) (*SyncIndexesResult, error) {
+	defineList, err := ParseIndexDefines(entity)
+	if err != nil {
+		return nil, err
+	}
+	return SyncIndexDefines(repository, defineList, opts...)
+}
+
+// synchronize defineList with the indexes of collection
+func SyncIndexDefines(repository IEntityIndex, defineList []*TaggedIndexDefine, opts ...SyncIndexesOption) (*SyncIndexesResult, error) {
+	syncOptions := MergeSyncIndexesOption(opts...)
+	existingList, err := repository.ListIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncIndexesResult{
+		Created:    make([]string, 0),
+		Changed:    make([]string, 0),
+		Rebuilt:    make([]string, 0),
+		Extraneous: make([]string, 0),
+		Dropped:    make([]string, 0),
+	}
+	matched := make(map[string]bool)
+	for _, eachDefine := range defineList {
+		existing := findExistingIndex(existingList, eachDefine)
+		if existing == nil {
+			result.Created = append(result.Created, eachDefine.Name)
+			if !syncOptions.DryRun {
+				if _, err := repository.CreateIndex(eachDefine.ToIndexModel()); err != nil {
+					return result, fmt.Errorf("create index %s failed: %w", eachDefine.Name, err)
+				}
+			}
+			continue
+		}
+		existingName := fmt.Sprint(existing["name"])
+		matched[existingName] = true
+		if indexDefineEqual(existing, eachDefine) {
+			continue
+		}
+		result.Changed = append(result.Changed, eachDefine.Name)
+		if !syncOptions.RebuildChanged || syncOptions.DryRun {
+			continue
+		}
+		if err := repository.DeleteIndex(existingName); err != nil {
+			return result, fmt.Errorf("drop index %s failed: %w", existingName, err)
+		}
+		if _, err := repository.CreateIndex(eachDefine.ToIndexModel()); err != nil {
+			return result, fmt.Errorf("create index %s failed: %w", eachDefine.Name, err)
+		}
+		result.Rebuilt = append(result.Rebuilt, eachDefine.Name)
+	}
+
+	for _, eachExisting := range existingList {
+		name := fmt.Sprint(eachExisting["name"])
+		if name == _idIndexName || matched[name] {
+			continue
+		}
+		result.Extraneous = append(result.Extraneous, name)
+		if !syncOptions.DropExtraneous || syncOptions.DryRun {
+			continue
+		}
+		if err := repository.DeleteIndex(name); err != nil {
+			return result, fmt.Errorf("drop index %s failed: %w", name, err)
+		}
+		result.Dropped = append(result.Dropped, name)
+	}
+	return result, nil
+}
+
+// find existing index by name, then by keys
+func findExistingIndex(existingList []map[string]interface{}, define *TaggedIndexDefine) map[string]interface{} {
+	for _, eachExisting := range existingList {
+		if eachExisting["name"] == define.Name {
+			return eachExisting
+		}
+	}
+	for _, eachExisting := range existingList {
+		if eachExisting["name"] != _idIndexName && indexKeysEqual(eachExisting, define) {
+			return eachExisting
+		}
+	}
+	return nil
+}
+
+func indexDefineEqual(existing map[string]interface{}, define *TaggedIndexDefine) bool {
+	if !indexKeysEqual(existing, define) {
+		return false
+	}
+	if indexOptionBool(existing["unique"]) != define.Unique || indexOptionBool(existing["sparse"]) != define.Sparse {
+		return false
+	}
+	expire, hasExpire := indexOptionNumber(existing["expireAfterSeconds"])
+	if hasExpire != (define.ExpireAfterSeconds != nil) {
+		return false
+	}
+	if hasExpire && expire != float64(*define.ExpireAfterSeconds) {
+		return false
+	}
+	return true
+}
+
+func indexKeysEqual(existing map[string]interface{}, define *TaggedIndexDefine) bool {
+	keys := indexKeysOf(existing["key"])
+	if define.isText() {
+		// text index is stored as {_fts: "text", _ftsx: 1} with weights
+		if v, ok := lookupIndexKey(keys, "_fts"); ok && v == "text" {
+			return textFieldsEqual(indexKeysOf(existing["weights"]), define)
+		}
+	}
+	if len(keys) != len(define.Keys) {
+		return false
+	}
+	for i, e := range define.Keys {
+		if keys[i].Key != e.Key || !indexKeyValueEqual(keys[i].Value, e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func textFieldsEqual(weights bson.D, define *TaggedIndexDefine) bool {
+	fieldList := make([]string, 0)
+	for _, e := range define.Keys {
+		if e.Value == "text" {
+			fieldList = append(fieldList, e.Key)
+		}
+	}
+	if len(weights) != len(fieldList) {
+		return false
+	}
+	for _, eachField := range fieldList {
+		if _, ok := lookupIndexKey(weights, eachField); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// convert key document of listIndexes to bson.D,
+// keys of map are sorted since the order is lost
+func indexKeysOf(v interface{}) bson.D {
+	switch keys := v.(type) {
+	case bson.D:
+		return keys
+	case bson.M:
+		return mapToSortedD(keys)
+	case map[string]interface{}:
+		return mapToSortedD(keys)
+	}
+	return bson.D{}
+}
+
+func mapToSortedD(m map[string]interface{}) bson.D {
+	keyList := make([]string, 0, len(m))
+	for eachKey := range m {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Strings(keyList)
+	d := make(bson.D, 0, len(m))
+	for _, eachKey := range keyList {
+		d = append(d, bson.E{Key: eachKey, Value: m[eachKey]})
+	}
+	return d
+}
+
+func lookupIndexKey(keys bson.D, key string) (interface{}, bool) {
+	for _, e := range keys {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+func indexKeyValueEqual(a interface{}, b interface{}) bool {
+	af, aIsNumber := indexOptionNumber(a)
+	bf, bIsNumber := indexOptionNumber(b)
+	if aIsNumber || bIsNumber {
+		return aIsNumber && bIsNumber && af == bf
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func indexOptionBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+func indexOptionNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// synchronize the indexes declared by struct tags of entity with the indexes of this collection
+func (r *MongoCol) SyncIndexes(entity interface{}, opts ...SyncIndexesOption) (*SyncIndexesResult, error) {
+	return SyncIndexes(r, entity, opts...)
+}
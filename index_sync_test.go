@@ -0,0 +1,163 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestIndexDefineEqual(t *testing.T) {
+	textDefine := &TaggedIndexDefine{
+		Name:    "title_text",
+		Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "body", Value: "text"}},
+		Weights: bson.D{{Key: "title", Value: int32(10)}},
+	}
+	ttlDefine := &TaggedIndexDefine{
+		Name:               "expireAt_1",
+		Keys:               bson.D{{Key: "expireAt", Value: int32(1)}},
+		ExpireAfterSeconds: ptr(int32(3600)),
+	}
+	compoundDefine := &TaggedIndexDefine{
+		Name:   "org_user",
+		Keys:   bson.D{{Key: "org", Value: int32(1)}, {Key: "user", Value: int32(-1)}},
+		Unique: true,
+	}
+	cases := []struct {
+		name     string
+		existing map[string]interface{}
+		define   *TaggedIndexDefine
+		want     bool
+	}{
+		{
+			name: "text index stored as _fts",
+			existing: map[string]interface{}{
+				"key":     bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+				"weights": bson.D{{Key: "body", Value: int32(1)}, {Key: "title", Value: int32(10)}},
+			},
+			define: textDefine,
+			want:   true,
+		},
+		{
+			name: "text index of map keys",
+			existing: map[string]interface{}{
+				"key":     bson.M{"_fts": "text", "_ftsx": int32(1)},
+				"weights": bson.M{"title": int32(10), "body": int32(1)},
+			},
+			define: textDefine,
+			want:   true,
+		},
+		{
+			name: "text index with other fields",
+			existing: map[string]interface{}{
+				"key":     bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+				"weights": bson.D{{Key: "title", Value: int32(10)}},
+			},
+			define: textDefine,
+			want:   false,
+		},
+		{
+			name: "ttl of int32",
+			existing: map[string]interface{}{
+				"key":                bson.D{{Key: "expireAt", Value: int32(1)}},
+				"expireAfterSeconds": int32(3600),
+			},
+			define: ttlDefine,
+			want:   true,
+		},
+		{
+			name: "ttl of int64",
+			existing: map[string]interface{}{
+				"key":                bson.D{{Key: "expireAt", Value: int64(1)}},
+				"expireAfterSeconds": int64(3600),
+			},
+			define: ttlDefine,
+			want:   true,
+		},
+		{
+			name: "ttl of double",
+			existing: map[string]interface{}{
+				"key":                bson.D{{Key: "expireAt", Value: 1.0}},
+				"expireAfterSeconds": 3600.0,
+			},
+			define: ttlDefine,
+			want:   true,
+		},
+		{
+			name: "ttl is changed",
+			existing: map[string]interface{}{
+				"key":                bson.D{{Key: "expireAt", Value: int32(1)}},
+				"expireAfterSeconds": int64(60),
+			},
+			define: ttlDefine,
+			want:   false,
+		},
+		{
+			name: "ttl is missing",
+			existing: map[string]interface{}{
+				"key": bson.D{{Key: "expireAt", Value: int32(1)}},
+			},
+			define: ttlDefine,
+			want:   false,
+		},
+		{
+			name: "compound index",
+			existing: map[string]interface{}{
+				"key":    bson.D{{Key: "org", Value: int32(1)}, {Key: "user", Value: int64(-1)}},
+				"unique": true,
+			},
+			define: compoundDefine,
+			want:   true,
+		},
+		{
+			name: "compound index of other key order",
+			existing: map[string]interface{}{
+				"key":    bson.D{{Key: "user", Value: int32(-1)}, {Key: "org", Value: int32(1)}},
+				"unique": true,
+			},
+			define: compoundDefine,
+			want:   false,
+		},
+		{
+			name: "direction is changed",
+			existing: map[string]interface{}{
+				"key":    bson.D{{Key: "org", Value: int32(1)}, {Key: "user", Value: int32(1)}},
+				"unique": true,
+			},
+			define: compoundDefine,
+			want:   false,
+		},
+		{
+			name: "unique is changed",
+			existing: map[string]interface{}{
+				"key": bson.D{{Key: "org", Value: int32(1)}, {Key: "user", Value: int32(-1)}},
+			},
+			define: compoundDefine,
+			want:   false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := indexDefineEqual(c.existing, c.define); got != c.want {
+				t.Errorf("indexDefineEqual() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFindExistingIndex(t *testing.T) {
+	define := &TaggedIndexDefine{Name: "a_idx", Keys: bson.D{{Key: "a", Value: int32(1)}}}
+	byName := map[string]interface{}{"name": "a_idx", "key": bson.D{{Key: "b", Value: int32(1)}}}
+	byKeys := map[string]interface{}{"name": "a_1", "key": bson.D{{Key: "a", Value: int32(1)}}}
+	idIndex := map[string]interface{}{"name": _idIndexName, "key": bson.D{{Key: "_id", Value: int32(1)}}}
+
+	if got := findExistingIndex([]map[string]interface{}{byKeys, byName}, define); got["name"] != "a_idx" {
+		t.Errorf("index found by name = %v, want a_idx", got["name"])
+	}
+	if got := findExistingIndex([]map[string]interface{}{idIndex, byKeys}, define); got["name"] != "a_1" {
+		t.Errorf("index found by keys = %v, want a_1", got["name"])
+	}
+	idDefine := &TaggedIndexDefine{Name: "id_idx", Keys: bson.D{{Key: "_id", Value: int32(1)}}}
+	if got := findExistingIndex([]map[string]interface{}{idIndex}, idDefine); got != nil {
+		t.Errorf("_id index is found = %v", got)
+	}
+}
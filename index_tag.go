@@ -0,0 +1,292 @@
+package mongodbr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	// struct tag name of index declaration
+	IndexTagName = "mongodbr"
+
+	indexTagPrefix = "index"
+	_idIndexName   = "_id_"
+)
+
+// index declared by struct tags, e.g.
+//
+//	Name     string `bson:"name" mongodbr:"index:name_idx,unique,sparse"`
+//	ExpireAt time.Time `bson:"expireAt" mongodbr:"index:,ttl=3600"`
+//	// fields with the same index name are grouped as one compound index, order=n sets the key order
+//	Org  string `bson:"org" mongodbr:"index:org_user,order=1"`
+//	User string `bson:"user" mongodbr:"index:org_user,order=2,desc"`
+//	// one field can have multiple indexes separated by ;
+//	Title    string `bson:"title" mongodbr:"index:title_text,text,weight=10;index"`
+//	Location bson.M `bson:"location" mongodbr:"index:,2dsphere"`
+//
+// supported options: unique,sparse,desc,text,2dsphere,hashed,ttl=<seconds>,order=<n>,weight=<n>
+type TaggedIndexDefine struct {
+	Name               string
+	Keys               bson.D
+	Unique             bool
+	Sparse             bool
+	ExpireAfterSeconds *int32
+	// weights of text index
+	Weights bson.D
+
+	orders []int
+}
+
+// parse index declarations from struct tags of entity,
+// entity can be a struct value, a pointer to struct or a reflect.Type
+func ParseIndexDefines(entity interface{}) ([]*TaggedIndexDefine, error) {
+	var t reflect.Type
+	if v, ok := entity.(reflect.Type); ok {
+		t = v
+	} else {
+		t = reflect.TypeOf(entity)
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("entity must be a struct, but got %v", t)
+	}
+	defineList := make([]*TaggedIndexDefine, 0)
+	if err := parseIndexTags(t, "", map[reflect.Type]bool{}, &defineList); err != nil {
+		return nil, err
+	}
+	for _, eachDefine := range defineList {
+		eachDefine.sortKeys()
+		if len(eachDefine.Name) <= 0 {
+			eachDefine.Name = eachDefine.defaultName()
+		}
+	}
+	return defineList, nil
+}
+
+// convert to mongo.IndexModel
+func (d *TaggedIndexDefine) ToIndexModel() mongo.IndexModel {
+	indexOptions := options.Index().SetName(d.Name)
+	if d.Unique {
+		indexOptions.SetUnique(true)
+	}
+	if d.Sparse {
+		indexOptions.SetSparse(true)
+	}
+	if d.ExpireAfterSeconds != nil {
+		indexOptions.SetExpireAfterSeconds(*d.ExpireAfterSeconds)
+	}
+	if len(d.Weights) > 0 {
+		indexOptions.SetWeights(d.Weights)
+	}
+	return mongo.IndexModel{
+		Keys:    d.Keys,
+		Options: indexOptions,
+	}
+}
+
+func parseIndexTags(t reflect.Type, prefix string, visiting map[reflect.Type]bool, defineList *[]*TaggedIndexDefine) error {
+	if visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isBsonField(field) {
+			continue
+		}
+		fieldName, inline, skip := bsonFieldName(field)
+		if skip {
+			continue
+		}
+		path := fieldName
+		if inline {
+			path = strings.TrimSuffix(prefix, ".")
+		} else if len(prefix) > 0 {
+			path = prefix + fieldName
+		}
+		if tag, ok := field.Tag.Lookup(IndexTagName); ok && len(tag) > 0 {
+			if err := parseIndexTag(path, tag, defineList); err != nil {
+				return fmt.Errorf("invalid index tag of field %s.%s: %w", t.Name(), field.Name, err)
+			}
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			continue
+		}
+		subPrefix := path + "."
+		if len(path) <= 0 {
+			subPrefix = ""
+		}
+		if err := parseIndexTags(fieldType, subPrefix, visiting, defineList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exported fields and embedded structs are encoded by bson codec, even if the embedded struct is unexported
+func isBsonField(field reflect.StructField) bool {
+	return field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct)
+}
+
+// get field name in bson document, same rules as bson codec
+func bsonFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	tag := field.Tag.Get("bson")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, eachPart := range parts[1:] {
+		if eachPart == "inline" {
+			inline = true
+		}
+	}
+	if field.Anonymous && len(parts[0]) <= 0 {
+		inline = true
+	}
+	if len(parts[0]) > 0 {
+		return parts[0], inline, false
+	}
+	return strings.ToLower(field.Name), inline, false
+}
+
+// parse tag such as index:name,unique;index:other
+func parseIndexTag(path string, tag string, defineList *[]*TaggedIndexDefine) error {
+	for _, eachDeclaration := range strings.Split(tag, ";") {
+		eachDeclaration = strings.TrimSpace(eachDeclaration)
+		if len(eachDeclaration) <= 0 {
+			continue
+		}
+		if !strings.HasPrefix(eachDeclaration, indexTagPrefix) {
+			return fmt.Errorf("unknown declaration %s", eachDeclaration)
+		}
+		if len(path) <= 0 {
+			return fmt.Errorf("index cannot be declared on inline field")
+		}
+		rest := strings.TrimPrefix(eachDeclaration, indexTagPrefix)
+		if len(rest) > 0 && rest[0] != ':' && rest[0] != ',' {
+			return fmt.Errorf("unknown declaration %s", eachDeclaration)
+		}
+		rest = strings.TrimPrefix(rest, ":")
+		parts := strings.Split(rest, ",")
+		name := strings.TrimSpace(parts[0])
+
+		var keyValue interface{} = int32(1)
+		order := 0
+		define := &TaggedIndexDefine{Name: name}
+		var weight *int32
+		for _, eachOption := range parts[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(eachOption), "=")
+			switch key {
+			case "":
+			case "unique":
+				define.Unique = true
+			case "sparse":
+				define.Sparse = true
+			case "desc":
+				keyValue = int32(-1)
+			case "text", "2dsphere", "hashed":
+				keyValue = key
+			case "ttl":
+				seconds, err := strconv.ParseInt(value, 10, 32)
+				if err != nil {
+					return fmt.Errorf("invalid ttl value %s", value)
+				}
+				define.ExpireAfterSeconds = ptr(int32(seconds))
+			case "order":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return fmt.Errorf("invalid order value %s", value)
+				}
+				order = n
+			case "weight":
+				n, err := strconv.ParseInt(value, 10, 32)
+				if err != nil {
+					return fmt.Errorf("invalid weight value %s", value)
+				}
+				weight = ptr(int32(n))
+			default:
+				return fmt.Errorf("unknown index option %s", key)
+			}
+		}
+		define.Keys = bson.D{{Key: path, Value: keyValue}}
+		define.orders = []int{order}
+		if weight != nil {
+			define.Weights = bson.D{{Key: path, Value: *weight}}
+		}
+		mergeIndexDefine(defineList, define)
+	}
+	return nil
+}
+
+// merge define into the define with the same name to build compound index
+func mergeIndexDefine(defineList *[]*TaggedIndexDefine, define *TaggedIndexDefine) {
+	if len(define.Name) > 0 {
+		for _, eachDefine := range *defineList {
+			if eachDefine.Name != define.Name {
+				continue
+			}
+			eachDefine.Keys = append(eachDefine.Keys, define.Keys...)
+			eachDefine.orders = append(eachDefine.orders, define.orders...)
+			eachDefine.Weights = append(eachDefine.Weights, define.Weights...)
+			eachDefine.Unique = eachDefine.Unique || define.Unique
+			eachDefine.Sparse = eachDefine.Sparse || define.Sparse
+			if define.ExpireAfterSeconds != nil {
+				eachDefine.ExpireAfterSeconds = define.ExpireAfterSeconds
+			}
+			return
+		}
+	}
+	*defineList = append(*defineList, define)
+}
+
+// sort keys of compound index by order, keep declaration order for the same order
+func (d *TaggedIndexDefine) sortKeys() {
+	indexList := make([]int, len(d.Keys))
+	for i := range indexList {
+		indexList[i] = i
+	}
+	sort.SliceStable(indexList, func(i, j int) bool {
+		return d.orders[indexList[i]] < d.orders[indexList[j]]
+	})
+	keys := make(bson.D, 0, len(d.Keys))
+	orders := make([]int, 0, len(d.Keys))
+	for _, eachIndex := range indexList {
+		keys = append(keys, d.Keys[eachIndex])
+		orders = append(orders, d.orders[eachIndex])
+	}
+	d.Keys = keys
+	d.orders = orders
+}
+
+// same as the default index name generated by mongodb
+func (d *TaggedIndexDefine) defaultName() string {
+	nameList := make([]string, 0, len(d.Keys)*2)
+	for _, e := range d.Keys {
+		nameList = append(nameList, e.Key, fmt.Sprint(e.Value))
+	}
+	return strings.Join(nameList, "_")
+}
+
+func (d *TaggedIndexDefine) isText() bool {
+	for _, e := range d.Keys {
+		if e.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package mongodbr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type indexTagAddress struct {
+	City string `bson:"city" mongodbr:"index"`
+}
+
+type indexTagBase struct {
+	TenantId string `bson:"tenantId" mongodbr:"index:tenant_code,order=1"`
+}
+
+type indexTagEntity struct {
+	indexTagBase `bson:",inline"`
+	Entity       `bson:",inline"`
+
+	Code     string          `bson:"code" mongodbr:"index:tenant_code,order=2,unique"`
+	Org      string          `bson:"org" mongodbr:"index:org_user,order=2,desc"`
+	User     string          `bson:"user" mongodbr:"index:org_user,order=1"`
+	Title    string          `bson:"title" mongodbr:"index:title_text,text,weight=10;index:title_idx,sparse"`
+	Body     string          `bson:"body" mongodbr:"index:title_text,text"`
+	ExpireAt time.Time       `bson:"expireAt" mongodbr:"index:,ttl=3600"`
+	Address  indexTagAddress `bson:"address"`
+	Ignored  string          `bson:"-" mongodbr:"index"`
+}
+
+func TestParseIndexDefines(t *testing.T) {
+	defineList, err := ParseIndexDefines(&indexTagEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*TaggedIndexDefine{
+		{
+			Name:   "tenant_code",
+			Keys:   bson.D{{Key: "tenantId", Value: int32(1)}, {Key: "code", Value: int32(1)}},
+			Unique: true,
+			orders: []int{1, 2},
+		},
+		{
+			Name:   "org_user",
+			Keys:   bson.D{{Key: "user", Value: int32(1)}, {Key: "org", Value: int32(-1)}},
+			orders: []int{1, 2},
+		},
+		{
+			Name:    "title_text",
+			Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "body", Value: "text"}},
+			Weights: bson.D{{Key: "title", Value: int32(10)}},
+			orders:  []int{0, 0},
+		},
+		{
+			Name:   "title_idx",
+			Keys:   bson.D{{Key: "title", Value: int32(1)}},
+			Sparse: true,
+			orders: []int{0},
+		},
+		{
+			Name:               "expireAt_1",
+			Keys:               bson.D{{Key: "expireAt", Value: int32(1)}},
+			ExpireAfterSeconds: ptr(int32(3600)),
+			orders:             []int{0},
+		},
+		{
+			Name:   "address.city_1",
+			Keys:   bson.D{{Key: "address.city", Value: int32(1)}},
+			orders: []int{0},
+		},
+	}
+	if !reflect.DeepEqual(defineList, want) {
+		for _, eachDefine := range defineList {
+			t.Logf("%+v", *eachDefine)
+		}
+		t.Errorf("defines are not the same as expected")
+	}
+}
+
+func TestParseIndexTag(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		tag     string
+		want    []*TaggedIndexDefine
+		wantErr string
+	}{
+		{
+			name: "default index",
+			path: "a",
+			tag:  "index",
+			want: []*TaggedIndexDefine{{Keys: bson.D{{Key: "a", Value: int32(1)}}, orders: []int{0}}},
+		},
+		{
+			name: "options with spaces",
+			path: "a",
+			tag:  "index:a_idx, unique , hashed,",
+			want: []*TaggedIndexDefine{{Name: "a_idx", Keys: bson.D{{Key: "a", Value: "hashed"}}, Unique: true, orders: []int{0}}},
+		},
+		{
+			name: "multiple indexes",
+			path: "a",
+			tag:  "index:x,2dsphere; index:y,order=3 ;",
+			want: []*TaggedIndexDefine{
+				{Name: "x", Keys: bson.D{{Key: "a", Value: "2dsphere"}}, orders: []int{0}},
+				{Name: "y", Keys: bson.D{{Key: "a", Value: int32(1)}}, orders: []int{3}},
+			},
+		},
+		{
+			name:    "invalid ttl",
+			path:    "a",
+			tag:     "index:,ttl=soon",
+			wantErr: "invalid ttl value soon",
+		},
+		{
+			name:    "ttl out of int32",
+			path:    "a",
+			tag:     "index:,ttl=3000000000",
+			wantErr: "invalid ttl value",
+		},
+		{
+			name:    "invalid weight",
+			path:    "a",
+			tag:     "index:,text,weight=",
+			wantErr: "invalid weight value",
+		},
+		{
+			name:    "invalid order",
+			path:    "a",
+			tag:     "index:x,order=first",
+			wantErr: "invalid order value first",
+		},
+		{
+			name:    "unknown option",
+			path:    "a",
+			tag:     "index:x,primary",
+			wantErr: "unknown index option primary",
+		},
+		{
+			name:    "unknown declaration",
+			path:    "a",
+			tag:     "indexes",
+			wantErr: "unknown declaration indexes",
+		},
+		{
+			name:    "inline field",
+			path:    "",
+			tag:     "index",
+			wantErr: "inline field",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defineList := make([]*TaggedIndexDefine, 0)
+			err := parseIndexTag(c.path, c.tag, &defineList)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %s", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(defineList, c.want) {
+				t.Errorf("defines = %+v, want %+v", defineList, c.want)
+			}
+		})
+	}
+}
+
+func TestParseIndexDefinesOfInlineField(t *testing.T) {
+	type invalid struct {
+		indexTagAddress `bson:",inline" mongodbr:"index"`
+	}
+	if _, err := ParseIndexDefines(invalid{}); err == nil || !strings.Contains(err.Error(), "inline field") {
+		t.Errorf("err = %v, want error of inline field", err)
+	}
+	if _, err := ParseIndexDefines(1); err == nil {
+		t.Error("err = nil, want error of non struct entity")
+	}
+}
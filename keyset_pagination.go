@@ -0,0 +1,411 @@
+package mongodbr
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrInvalidKeysetToken = errors.New("invalid keyset continuation token")
+)
+
+const (
+	keysetDirectionNext = "n"
+	keysetDirectionPrev = "p"
+)
+
+// keyset(cursor-based) pagination request
+type KeysetPageRequest struct {
+	// sort spec, _id is appended as tiebreaker if it's not present
+	Sort bson.D
+	// page size, must be greater than 0
+	PageSize int64
+	// continuation token returned by previous page(NextToken or PrevToken), empty for the first page
+	Token string
+}
+
+// keyset pagination result info
+type KeysetPageInfo struct {
+	// token to get next page, empty if HasNext is false
+	NextToken string
+	// token to get previous page, empty if HasPrev is false
+	PrevToken string
+	HasNext   bool
+	HasPrev   bool
+}
+
+type keysetSortField struct {
+	name string
+	asc  bool
+}
+
+// continuation token content
+type keysetToken struct {
+	Direction string `bson:"d"`
+	Signature string `bson:"s"`
+	Values    bson.A `bson:"v"`
+}
+
+// new keyset page request with sort spec and page size
+func NewKeysetPageRequest(sort bson.D, pageSize int64, token string) *KeysetPageRequest {
+	return &KeysetPageRequest{
+		Sort:     sort,
+		PageSize: pageSize,
+		Token:    token,
+	}
+}
+
+// find one page with keyset pagination, list must be a pointer to slice
+func (r *MongoCol) FindListByKeyset(filter interface{}, list interface{}, request *KeysetPageRequest, opts ...MongodbrFindOption) (_ *KeysetPageInfo, err error) {
+	sortFields, token, err := request.prepare()
+	if err != nil {
+		return nil, err
+	}
+	findOptions := &MongodbrFindOptions{
+		FindOptions: &options.FindOptions{},
+	}
+	for _, o := range opts {
+		o(findOptions)
+	}
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, findOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "find", filter)
+	defer endSpan(span, &err)
+
+	backward := token != nil && token.Direction == keysetDirectionPrev
+	findOptions.Sort = keysetQuerySort(sortFields, backward)
+	findOptions.Skip = nil
+	findOptions.Limit = ptr(request.PageSize + 1)
+
+	filter = r.configuration.notDeletedFilter(ctx, keysetFilter(filter, sortFields, token))
+	cur, err := r.collection().Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	pageInfo, err := readKeysetPage(ctx, cur, list, request, sortFields, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.configuration.hookAfterFindList(ctx, list); err != nil {
+		return nil, err
+	}
+	return pageInfo, nil
+}
+
+// aggregate one page with keyset pagination,
+// $match,$sort and $limit stages are appended to pipeline, so the sort fields must exist in the output of pipeline
+func (r *MongoCol) AggregateByKeyset(pipeline interface{}, list interface{}, request *KeysetPageRequest, opts ...MongodbrAggregateOption) (_ *KeysetPageInfo, err error) {
+	sortFields, token, err := request.prepare()
+	if err != nil {
+		return nil, err
+	}
+	aOptions := MergeMongodbrAggregateOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, aOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "aggregate", nil)
+	defer endSpan(span, &err)
+
+	stageList, err := toStageList(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	backward := token != nil && token.Direction == keysetDirectionPrev
+	if token != nil {
+		stageList = append(stageList, bson.D{{Key: "$match", Value: keysetFilter(nil, sortFields, token)}})
+	}
+	stageList = append(stageList,
+		bson.D{{Key: "$sort", Value: keysetQuerySort(sortFields, backward)}},
+		bson.D{{Key: "$limit", Value: request.PageSize + 1}},
+	)
+
+	finalPipeline, err := r.configuration.notDeletedPipeline(ctx, stageList)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := r.collection().Aggregate(ctx, finalPipeline, aOptions)
+	if err != nil {
+		return nil, err
+	}
+	pageInfo, err := readKeysetPage(ctx, cur, list, request, sortFields, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.configuration.hookAfterFindList(ctx, list); err != nil {
+		return nil, err
+	}
+	return pageInfo, nil
+}
+
+// validate request, normalize sort fields and decode token
+func (request *KeysetPageRequest) prepare() ([]keysetSortField, *keysetToken, error) {
+	if request == nil || request.PageSize <= 0 {
+		return nil, nil, fmt.Errorf("keyset page size must be greater than 0")
+	}
+	sortFields := make([]keysetSortField, 0, len(request.Sort)+1)
+	hasId := false
+	for _, e := range request.Sort {
+		asc := true
+		switch v := e.Value.(type) {
+		case int:
+			asc = v >= 0
+		case int32:
+			asc = v >= 0
+		case int64:
+			asc = v >= 0
+		case float64:
+			asc = v >= 0
+		default:
+			return nil, nil, fmt.Errorf("keyset sort value of field %s must be 1 or -1", e.Key)
+		}
+		if e.Key == "_id" {
+			hasId = true
+		}
+		sortFields = append(sortFields, keysetSortField{name: e.Key, asc: asc})
+	}
+	if !hasId {
+		sortFields = append(sortFields, keysetSortField{name: "_id", asc: true})
+	}
+	if len(request.Token) <= 0 {
+		return sortFields, nil, nil
+	}
+	token, err := decodeKeysetToken(request.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token.Signature != keysetSignature(sortFields) || len(token.Values) != len(sortFields) {
+		return nil, nil, fmt.Errorf("%w: sort spec is changed", ErrInvalidKeysetToken)
+	}
+	return sortFields, token, nil
+}
+
+func keysetSignature(sortFields []keysetSortField) string {
+	list := make([]string, 0, len(sortFields))
+	for _, eachField := range sortFields {
+		if eachField.asc {
+			list = append(list, eachField.name+":1")
+		} else {
+			list = append(list, eachField.name+":-1")
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// sort used by query, the directions are reversed when reading backward
+func keysetQuerySort(sortFields []keysetSortField, backward bool) bson.D {
+	sort := bson.D{}
+	for _, eachField := range sortFields {
+		value := 1
+		if eachField.asc == backward {
+			value = -1
+		}
+		sort = append(sort, bson.E{Key: eachField.name, Value: value})
+	}
+	return sort
+}
+
+// build the filter to seek after(or before) the row of token
+// (a > va) or (a = va and b > vb) or ...
+// null and missing values sort before any other value, so they are compared explicitly,
+// because {$gt: null} and {$lt: v} do not match them
+func keysetFilter(filter interface{}, sortFields []keysetSortField, token *keysetToken) interface{} {
+	if token == nil {
+		return filter
+	}
+	backward := token.Direction == keysetDirectionPrev
+	orList := bson.A{}
+	for i, eachField := range sortFields {
+		clause := bson.D{}
+		for j := 0; j < i; j++ {
+			// {a: null} matches both null and missing
+			clause = append(clause, bson.E{Key: sortFields[j].name, Value: token.Values[j]})
+		}
+		seek, ok := keysetSeek(eachField.name, eachField.asc == backward, token.Values[i])
+		if !ok {
+			// no value is less than null
+			continue
+		}
+		clause = append(clause, seek)
+		orList = append(orList, clause)
+	}
+	var seekFilter bson.D
+	if len(orList) <= 0 {
+		// nothing is after(or before) the row
+		seekFilter = bson.D{{Key: "$expr", Value: false}}
+	} else {
+		seekFilter = bson.D{{Key: "$or", Value: orList}}
+	}
+	if filter == nil {
+		return seekFilter
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, seekFilter}}}
+}
+
+// the predicate of field which is greater(or less) than value, return false if no value matches
+func keysetSeek(name string, less bool, value interface{}) (bson.E, bool) {
+	if value == nil {
+		if less {
+			return bson.E{}, false
+		}
+		return bson.E{Key: name, Value: bson.D{{Key: "$ne", Value: nil}}}, true
+	}
+	if !less {
+		return bson.E{Key: name, Value: bson.D{{Key: "$gt", Value: value}}}, true
+	}
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: name, Value: bson.D{{Key: "$lt", Value: value}}}},
+		bson.D{{Key: name, Value: nil}},
+	}}, true
+}
+
+// read at most PageSize+1 rows from cur and decode the page into list
+func readKeysetPage(ctx context.Context,
+	cur *mongo.Cursor,
+	list interface{},
+	request *KeysetPageRequest,
+	sortFields []keysetSortField,
+	token *keysetToken) (*KeysetPageInfo, error) {
+	defer cur.Close(ctx)
+
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() != reflect.Ptr || listValue.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("list must be a pointer to slice, but got %T", list)
+	}
+	sliceValue := listValue.Elem()
+	elemType := sliceValue.Type().Elem()
+
+	itemList := make([]reflect.Value, 0, request.PageSize+1)
+	rawList := make([]bson.Raw, 0, request.PageSize+1)
+	for cur.Next(ctx) {
+		var item reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			item = reflect.New(elemType.Elem())
+			if err := cur.Decode(item.Interface()); err != nil {
+				return nil, err
+			}
+		} else {
+			itemPtr := reflect.New(elemType)
+			if err := cur.Decode(itemPtr.Interface()); err != nil {
+				return nil, err
+			}
+			item = itemPtr.Elem()
+		}
+		itemList = append(itemList, item)
+		rawList = append(rawList, append(bson.Raw(nil), cur.Current...))
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := int64(len(itemList)) > request.PageSize
+	if hasMore {
+		itemList = itemList[:request.PageSize]
+		rawList = rawList[:request.PageSize]
+	}
+	backward := token != nil && token.Direction == keysetDirectionPrev
+	if backward {
+		for i, j := 0, len(itemList)-1; i < j; i, j = i+1, j-1 {
+			itemList[i], itemList[j] = itemList[j], itemList[i]
+			rawList[i], rawList[j] = rawList[j], rawList[i]
+		}
+	}
+
+	result := reflect.MakeSlice(sliceValue.Type(), 0, len(itemList))
+	result = reflect.Append(result, itemList...)
+	sliceValue.Set(result)
+
+	pageInfo := &KeysetPageInfo{}
+	if backward {
+		pageInfo.HasNext = true
+		pageInfo.HasPrev = hasMore
+	} else {
+		pageInfo.HasNext = hasMore
+		pageInfo.HasPrev = token != nil
+	}
+	if len(rawList) <= 0 {
+		// an empty page has no row to seek from
+		pageInfo.HasNext, pageInfo.HasPrev = false, false
+		return pageInfo, nil
+	}
+	var err error
+	if pageInfo.HasNext {
+		pageInfo.NextToken, err = encodeKeysetToken(keysetDirectionNext, sortFields, rawList[len(rawList)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pageInfo.HasPrev {
+		pageInfo.PrevToken, err = encodeKeysetToken(keysetDirectionPrev, sortFields, rawList[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pageInfo, nil
+}
+
+func encodeKeysetToken(direction string, sortFields []keysetSortField, raw bson.Raw) (string, error) {
+	token := &keysetToken{
+		Direction: direction,
+		Signature: keysetSignature(sortFields),
+		Values:    make(bson.A, 0, len(sortFields)),
+	}
+	for _, eachField := range sortFields {
+		var value interface{}
+		rawValue, err := raw.LookupErr(strings.Split(eachField.name, ".")...)
+		if err == nil {
+			if err := rawValue.Unmarshal(&value); err != nil {
+				return "", err
+			}
+		}
+		token.Values = append(token.Values, value)
+	}
+	data, err := bson.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeKeysetToken(value string) (*keysetToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeysetToken, err.Error())
+	}
+	token := &keysetToken{}
+	if err := bson.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeysetToken, err.Error())
+	}
+	if token.Direction != keysetDirectionNext && token.Direction != keysetDirectionPrev {
+		return nil, ErrInvalidKeysetToken
+	}
+	return token, nil
+}
+
+// convert pipeline (mongo.Pipeline,[]bson.D,bson.A...) to stage list which can be appended
+func toStageList(pipeline interface{}) ([]interface{}, error) {
+	if pipeline == nil {
+		return make([]interface{}, 0), nil
+	}
+	pipelineValue := reflect.ValueOf(pipeline)
+	// such as *mongo.Pipeline
+	for pipelineValue.Kind() == reflect.Ptr && !pipelineValue.IsNil() {
+		pipelineValue = pipelineValue.Elem()
+	}
+	if pipelineValue.Kind() != reflect.Slice && pipelineValue.Kind() != reflect.Array {
+		return nil, fmt.Errorf("pipeline must be a slice, but got %T", pipeline)
+	}
+	stageList := make([]interface{}, 0, pipelineValue.Len()+3)
+	for i := 0; i < pipelineValue.Len(); i++ {
+		stageList = append(stageList, pipelineValue.Index(i).Interface())
+	}
+	return stageList, nil
+}
@@ -0,0 +1,105 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestKeysetFilter(t *testing.T) {
+	sortFields := []keysetSortField{
+		{name: "name", asc: true},
+		{name: "_id", asc: true},
+	}
+	cases := []struct {
+		name      string
+		filter    interface{}
+		direction string
+		values    bson.A
+		want      interface{}
+	}{
+		{
+			name:      "next",
+			direction: keysetDirectionNext,
+			values:    bson.A{"b", int32(2)},
+			want: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: bson.D{{Key: "$gt", Value: "b"}}}},
+				bson.D{{Key: "name", Value: "b"}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(2)}}}},
+			}}},
+		},
+		{
+			name:      "prev includes null",
+			direction: keysetDirectionPrev,
+			values:    bson.A{"b", int32(2)},
+			want: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "name", Value: bson.D{{Key: "$lt", Value: "b"}}}},
+					bson.D{{Key: "name", Value: nil}},
+				}}},
+				bson.D{{Key: "name", Value: "b"}, {Key: "$or", Value: bson.A{
+					bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: int32(2)}}}},
+					bson.D{{Key: "_id", Value: nil}},
+				}}},
+			}}},
+		},
+		{
+			name:      "next after null",
+			direction: keysetDirectionNext,
+			values:    bson.A{nil, int32(2)},
+			want: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: nil}}}},
+				bson.D{{Key: "name", Value: nil}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(2)}}}},
+			}}},
+		},
+		{
+			name:      "prev before null",
+			direction: keysetDirectionPrev,
+			values:    bson.A{nil, int32(2)},
+			want: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: nil}, {Key: "$or", Value: bson.A{
+					bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: int32(2)}}}},
+					bson.D{{Key: "_id", Value: nil}},
+				}}},
+			}}},
+		},
+		{
+			name:      "with filter",
+			filter:    bson.M{"age": 1},
+			direction: keysetDirectionNext,
+			values:    bson.A{"b", int32(2)},
+			want: bson.D{{Key: "$and", Value: bson.A{
+				bson.M{"age": 1},
+				bson.D{{Key: "$or", Value: bson.A{
+					bson.D{{Key: "name", Value: bson.D{{Key: "$gt", Value: "b"}}}},
+					bson.D{{Key: "name", Value: "b"}, {Key: "_id", Value: bson.D{{Key: "$gt", Value: int32(2)}}}},
+				}}},
+			}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			token := &keysetToken{Direction: c.direction, Values: c.values}
+			got := keysetFilter(c.filter, sortFields, token)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("keysetFilter() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestKeysetFilterNothingBefore(t *testing.T) {
+	sortFields := []keysetSortField{{name: "name", asc: true}}
+	token := &keysetToken{Direction: keysetDirectionPrev, Values: bson.A{nil}}
+	want := bson.D{{Key: "$expr", Value: false}}
+	if got := keysetFilter(nil, sortFields, token); !reflect.DeepEqual(got, want) {
+		t.Errorf("keysetFilter() = %v, want %v", got, want)
+	}
+}
+
+func TestKeysetFilterWithoutToken(t *testing.T) {
+	filter := bson.M{"age": 1}
+	if got := keysetFilter(filter, nil, nil); !reflect.DeepEqual(got, filter) {
+		t.Errorf("keysetFilter() = %v, want %v", got, filter)
+	}
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// run the aggregate pipeline on docList,
+// only $match,$sort,$skip,$limit,$project,$unset,$count and $facet stages are supported
+func aggregate(docList []bson.D, stageList bson.A) ([]bson.D, error) {
+	for _, eachStage := range stageList {
+		stage, ok := eachStage.(bson.D)
+		if !ok || len(stage) != 1 {
+			return nil, fmt.Errorf("a pipeline stage specification object must contain exactly one field")
+		}
+		var err error
+		docList, err = aggregateStage(docList, stage[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docList, nil
+}
+
+func aggregateStage(docList []bson.D, stage bson.E) ([]bson.D, error) {
+	switch stage.Key {
+	case "$match":
+		filter, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("the match filter must be an expression in an object")
+		}
+		result := make([]bson.D, 0, len(docList))
+		for _, eachDoc := range docList {
+			matched, err := matchDocument(eachDoc, filter)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				result = append(result, eachDoc)
+			}
+		}
+		return result, nil
+	case "$sort":
+		sortDoc, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("the $sort key specification must be an object")
+		}
+		sortDocuments(docList, sortDoc)
+		return docList, nil
+	case "$skip", "$limit":
+		n, ok := toFloat(stage.Value)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number", stage.Key)
+		}
+		value := int64(n)
+		if stage.Key == "$skip" {
+			return skipAndLimit(docList, &value, nil), nil
+		}
+		return skipAndLimit(docList, nil, &value), nil
+	case "$project":
+		projection, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("$project specification must be an object")
+		}
+		return projectAll(docList, projection)
+	case "$unset":
+		projection := bson.D{}
+		switch v := stage.Value.(type) {
+		case string:
+			projection = append(projection, bson.E{Key: v, Value: int32(0)})
+		case bson.A:
+			for _, eachField := range v {
+				projection = append(projection, bson.E{Key: fmt.Sprint(eachField), Value: int32(0)})
+			}
+		default:
+			return nil, fmt.Errorf("$unset specification must be a string or an array")
+		}
+		return projectAll(docList, projection)
+	case "$count":
+		field, ok := stage.Value.(string)
+		if !ok || len(field) <= 0 {
+			return nil, fmt.Errorf("the count field must be a non-empty string")
+		}
+		if len(docList) <= 0 {
+			return []bson.D{}, nil
+		}
+		return []bson.D{{{Key: field, Value: int32(len(docList))}}}, nil
+	case "$facet":
+		facet, ok := stage.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("$facet specification must be an object")
+		}
+		result := bson.D{}
+		for _, eachFacet := range facet {
+			subStageList, ok := eachFacet.Value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("$facet sub-pipeline %s must be an array", eachFacet.Key)
+			}
+			subDocList := make([]bson.D, 0, len(docList))
+			for _, eachDoc := range docList {
+				subDocList = append(subDocList, cloneD(eachDoc))
+			}
+			subDocList, err := aggregate(subDocList, subStageList)
+			if err != nil {
+				return nil, err
+			}
+			values := make(bson.A, 0, len(subDocList))
+			for _, eachDoc := range subDocList {
+				values = append(values, eachDoc)
+			}
+			result = append(result, bson.E{Key: eachFacet.Key, Value: values})
+		}
+		return []bson.D{result}, nil
+	}
+	return nil, fmt.Errorf("%w: aggregate stage %s", ErrUnsupported, stage.Key)
+}
+
+func projectAll(docList []bson.D, projection bson.D) ([]bson.D, error) {
+	for index := range docList {
+		var err error
+		docList[index], err = project(docList[index], projection)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docList, nil
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestAggregate(t *testing.T) {
+	newDocList := func() []bson.D {
+		return []bson.D{
+			{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "c"}, {Key: "age", Value: int32(30)}},
+			{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "a"}, {Key: "age", Value: int32(20)}},
+			{{Key: "_id", Value: int32(3)}, {Key: "name", Value: "b"}, {Key: "age", Value: int32(40)}},
+		}
+	}
+	cases := []struct {
+		name     string
+		pipeline bson.A
+		want     []bson.D
+	}{
+		{
+			name:     "empty pipeline",
+			pipeline: bson.A{},
+			want:     newDocList(),
+		},
+		{
+			name: "$match $sort",
+			pipeline: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: int32(30)}}}}}},
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "age", Value: int32(-1)}}}},
+			},
+			want: []bson.D{
+				{{Key: "_id", Value: int32(3)}, {Key: "name", Value: "b"}, {Key: "age", Value: int32(40)}},
+				{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "c"}, {Key: "age", Value: int32(30)}},
+			},
+		},
+		{
+			name: "$sort $skip $limit $project",
+			pipeline: bson.A{
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "name", Value: int32(1)}}}},
+				bson.D{{Key: "$skip", Value: int32(1)}},
+				bson.D{{Key: "$limit", Value: int32(1)}},
+				bson.D{{Key: "$project", Value: bson.D{{Key: "name", Value: int32(1)}}}},
+			},
+			want: []bson.D{{{Key: "_id", Value: int32(3)}, {Key: "name", Value: "b"}}},
+		},
+		{
+			name: "$unset",
+			pipeline: bson.A{
+				bson.D{{Key: "$limit", Value: int32(1)}},
+				bson.D{{Key: "$unset", Value: bson.A{"name", "age"}}},
+			},
+			want: []bson.D{{{Key: "_id", Value: int32(1)}}},
+		},
+		{
+			name:     "$count",
+			pipeline: bson.A{bson.D{{Key: "$count", Value: "total"}}},
+			want:     []bson.D{{{Key: "total", Value: int32(3)}}},
+		},
+		{
+			name: "$count without documents",
+			pipeline: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{{Key: "name", Value: "z"}}}},
+				bson.D{{Key: "$count", Value: "total"}},
+			},
+			want: []bson.D{},
+		},
+		{
+			name: "$facet",
+			pipeline: bson.A{
+				bson.D{{Key: "$facet", Value: bson.D{
+					{Key: "items", Value: bson.A{
+						bson.D{{Key: "$sort", Value: bson.D{{Key: "age", Value: int32(1)}}}},
+						bson.D{{Key: "$limit", Value: int32(1)}},
+					}},
+					{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+				}}},
+			},
+			want: []bson.D{{
+				{Key: "items", Value: bson.A{
+					bson.D{{Key: "_id", Value: int32(2)}, {Key: "name", Value: "a"}, {Key: "age", Value: int32(20)}},
+				}},
+				{Key: "total", Value: bson.A{bson.D{{Key: "count", Value: int32(3)}}}},
+			}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := aggregate(newDocList(), c.pipeline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("aggregate() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAggregateError(t *testing.T) {
+	cases := []struct {
+		name     string
+		pipeline bson.A
+	}{
+		{name: "stage with two fields", pipeline: bson.A{bson.D{{Key: "$skip", Value: int32(1)}, {Key: "$limit", Value: int32(1)}}}},
+		{name: "negative $limit", pipeline: bson.A{bson.D{{Key: "$limit", Value: int32(-1)}}}},
+		{name: "empty $count", pipeline: bson.A{bson.D{{Key: "$count", Value: ""}}}},
+		{name: "unsupported stage", pipeline: bson.A{bson.D{{Key: "$group", Value: bson.D{}}}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := aggregate([]bson.D{}, c.pipeline); err == nil {
+				t.Errorf("aggregate(%v) error = nil, want error", c.pipeline)
+			}
+		})
+	}
+}
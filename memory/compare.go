@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// normalize any document value(struct,bson.M,bson.D,map...) to bson.D,
+// so that all values are converted to the types used by the bson decoder
+func toBsonD(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+	if d, ok := v.(bson.D); ok {
+		v = cloneD(d)
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := bson.D{}
+	if err := bson.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// normalize any array value (mongo.Pipeline,[]bson.D,bson.A...) to bson.A
+func toBsonA(v interface{}) (bson.A, error) {
+	if v == nil {
+		return bson.A{}, nil
+	}
+	d, err := toBsonD(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil, err
+	}
+	a, ok := d[0].Value.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("value must be an array, but got %T", v)
+	}
+	return a, nil
+}
+
+// deep copy document, used to isolate stored documents from callers
+func cloneD(d bson.D) bson.D {
+	if d == nil {
+		return nil
+	}
+	result := make(bson.D, 0, len(d))
+	for _, e := range d {
+		result = append(result, bson.E{Key: e.Key, Value: cloneValue(e.Value)})
+	}
+	return result
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bson.D:
+		return cloneD(value)
+	case bson.A:
+		result := make(bson.A, 0, len(value))
+		for _, item := range value {
+			result = append(result, cloneValue(item))
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// resolve all values at path, values in arrays are traversed like mongodb does
+func resolvePath(current interface{}, parts []string) []interface{} {
+	if len(parts) <= 0 {
+		return []interface{}{current}
+	}
+	switch c := current.(type) {
+	case bson.D:
+		for _, e := range c {
+			if e.Key == parts[0] {
+				return resolvePath(e.Value, parts[1:])
+			}
+		}
+		return nil
+	case bson.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil {
+			if index >= 0 && index < len(c) {
+				return resolvePath(c[index], parts[1:])
+			}
+			return nil
+		}
+		result := make([]interface{}, 0)
+		for _, item := range c {
+			if _, ok := item.(bson.D); ok {
+				result = append(result, resolvePath(item, parts)...)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// get value at path without traversing arrays
+func lookupExact(current interface{}, parts []string) (interface{}, bool) {
+	if len(parts) <= 0 {
+		return current, true
+	}
+	switch c := current.(type) {
+	case bson.D:
+		for _, e := range c {
+			if e.Key == parts[0] {
+				return lookupExact(e.Value, parts[1:])
+			}
+		}
+	case bson.A:
+		if index, err := strconv.Atoi(parts[0]); err == nil && index >= 0 && index < len(c) {
+			return lookupExact(c[index], parts[1:])
+		}
+	}
+	return nil, false
+}
+
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// values and every element of array values
+func expandCandidates(values []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+		if a, ok := v.(bson.A); ok {
+			result = append(result, a...)
+		}
+	}
+	return result
+}
+
+// bson type order, https://www.mongodb.com/docs/manual/reference/bson-type-comparison-order/
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case bson.MinKey:
+		return 0
+	case nil, bson.Null, bson.Undefined:
+		return 1
+	case int32, int64, float64, int, bson.Decimal128:
+		return 2
+	case string, bson.Symbol:
+		return 3
+	case bson.D, bson.M:
+		return 4
+	case bson.A:
+		return 5
+	case bson.Binary:
+		return 6
+	case bson.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case bson.DateTime:
+		return 9
+	case bson.Timestamp:
+		return 10
+	case bson.Regex:
+		return 11
+	case bson.MaxKey:
+		return 12
+	}
+	return 13
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case bson.Decimal128:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare two values with bson order,
+// the second return value is false when the values cannot be compared by range operators
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		if rankA < rankB {
+			return -1, false
+		}
+		return 1, false
+	}
+	switch av := a.(type) {
+	case nil, bson.Null, bson.Undefined, bson.MinKey, bson.MaxKey:
+		return 0, true
+	case string:
+		return strings.Compare(av, fmt.Sprint(b)), true
+	case bson.Symbol:
+		return strings.Compare(string(av), fmt.Sprint(b)), true
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0, true
+		}
+		if !av {
+			return -1, true
+		}
+		return 1, true
+	case bson.ObjectID:
+		bv := b.(bson.ObjectID)
+		return bytes.Compare(av[:], bv[:]), true
+	case bson.DateTime:
+		return compareInt64(int64(av), int64(b.(bson.DateTime))), true
+	case bson.Timestamp:
+		bv := b.(bson.Timestamp)
+		if av.T != bv.T {
+			return compareInt64(int64(av.T), int64(bv.T)), true
+		}
+		return compareInt64(int64(av.I), int64(bv.I)), true
+	case bson.A:
+		bv := b.(bson.A)
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c, _ := compareValues(av[i], bv[i]); c != 0 {
+				return c, true
+			}
+		}
+		return compareInt64(int64(len(av)), int64(len(bv))), true
+	}
+	if af, ok := toFloat(a); ok {
+		bf, _ := toFloat(b)
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	// documents,binary and others are compared by their bson bytes
+	return bytes.Compare(marshalValue(a), marshalValue(b)), true
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	c, ok := compareValues(a, b)
+	return ok && c == 0
+}
+
+func marshalValue(v interface{}) []byte {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return nil
+	}
+	return data
+}
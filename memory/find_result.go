@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/abmpio/mongodbr"
+)
+
+type findResult struct {
+	cur        *mongo.Cursor
+	err        error
+	repository *Repository
+	context    context.Context
+}
+
+var _ mongodbr.IFindResult = (*findResult)(nil)
+
+// #region IFindResult Members
+
+func (r *findResult) One(val interface{}) (err error) {
+	if r.err != nil {
+		return r.err
+	}
+	if !r.cur.Next(r.GetContext()) {
+		return mongo.ErrNoDocuments
+	}
+	if err := r.cur.Decode(val); err != nil {
+		return err
+	}
+	return r.repository.hookAfterFind(r.GetContext(), val)
+}
+
+func (r *findResult) ToOne() (interface{}, error) {
+	result := bson.M{}
+	err := r.One(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *findResult) All(val interface{}) (err error) {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.cur.All(r.GetContext(), val); err != nil {
+		return err
+	}
+	return r.repository.AfterFindList(r.GetContext(), val)
+}
+
+func (r *findResult) ToAll() ([]interface{}, error) {
+	if r.err != nil {
+		return nil, nil
+	}
+	var result []interface{}
+	for r.cur.Next(r.GetContext()) {
+		o := bson.M{}
+		if err := r.cur.Decode(&o); err != nil {
+			return nil, err
+		}
+		if err := r.repository.hookAfterFind(r.GetContext(), o); err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+func (r *findResult) GetContext() context.Context {
+	return contextOf(r.context)
+}
+
+// memory find result has no SingleResult, always return nil
+func (r *findResult) GetSingleResult() (res *mongo.SingleResult) {
+	return nil
+}
+
+func (r *findResult) GetCursor() (cur *mongo.Cursor) {
+	return r.cur
+}
+
+func (r *findResult) GetError() (err error) {
+	return r.err
+}
+
+// #endregion
@@ -0,0 +1,245 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/abmpio/mongodbr"
+)
+
+// option of memory repository
+type RepositoryOption func(*Repository)
+
+// add repository hooks, hooks are invoked in the order they are added,
+// same as mongodbr.WithHooks
+func WithHooks(hooks ...*mongodbr.RepositoryHooks) RepositoryOption {
+	return func(r *Repository) {
+		for _, eachHooks := range hooks {
+			if eachHooks != nil {
+				r.hooks = append(r.hooks, eachHooks)
+			}
+		}
+	}
+}
+
+var _ mongodbr.IAfterFindHookable = (*Repository)(nil)
+
+// invoke AfterFind hooks for every item of list, list is a slice or a pointer to slice
+func (r *Repository) AfterFindList(ctx context.Context, list interface{}) error {
+	listValue := reflect.ValueOf(list)
+	for listValue.Kind() == reflect.Ptr && !listValue.IsNil() {
+		listValue = listValue.Elem()
+	}
+	if listValue.Kind() != reflect.Slice && listValue.Kind() != reflect.Array {
+		return nil
+	}
+	for i := 0; i < listValue.Len(); i++ {
+		elem := listValue.Index(i)
+		var item interface{}
+		switch {
+		case elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface:
+			if elem.IsNil() {
+				continue
+			}
+			item = elem.Interface()
+		case elem.CanAddr():
+			item = elem.Addr().Interface()
+		default:
+			item = elem.Interface()
+		}
+		if err := r.hookAfterFind(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// #region hook invokers
+
+func (r *Repository) hookBeforeCreate(ctx context.Context, item interface{}) error {
+	if entityHookable, ok := item.(mongodbr.IEntityBeforeCreate); ok {
+		entityHookable.BeforeCreate()
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.BeforeCreate == nil {
+			continue
+		}
+		if err := eachHooks.BeforeCreate(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookAfterCreate(ctx context.Context, item interface{}) error {
+	if entityHookable, ok := item.(mongodbr.IEntityAfterCreate); ok {
+		if err := entityHookable.AfterCreate(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.AfterCreate == nil {
+			continue
+		}
+		if err := eachHooks.AfterCreate(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookBeforeUpdate(ctx context.Context, filter interface{}, update interface{}) error {
+	if entityHookable, ok := update.(mongodbr.IEntityBeforeUpdate); ok {
+		entityHookable.BeforeUpdate()
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.BeforeUpdate == nil {
+			continue
+		}
+		if err := eachHooks.BeforeUpdate(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookAfterUpdate(ctx context.Context, filter interface{}, update interface{}) error {
+	if entityHookable, ok := update.(mongodbr.IEntityAfterUpdate); ok {
+		if err := entityHookable.AfterUpdate(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.AfterUpdate == nil {
+			continue
+		}
+		if err := eachHooks.AfterUpdate(ctx, filter, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replace is one kind of update, so BeforeUpdate of entity is invoked too
+func (r *Repository) hookBeforeReplace(ctx context.Context, filter interface{}, doc interface{}) error {
+	if entityHookable, ok := doc.(mongodbr.IEntityBeforeUpdate); ok {
+		entityHookable.BeforeUpdate()
+	}
+	if entityHookable, ok := doc.(mongodbr.IEntityBeforeReplace); ok {
+		if err := entityHookable.BeforeReplace(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.BeforeReplace == nil {
+			continue
+		}
+		if err := eachHooks.BeforeReplace(ctx, filter, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookBeforeDelete(ctx context.Context, filter interface{}) error {
+	for _, eachHooks := range r.hooks {
+		if eachHooks.BeforeDelete == nil {
+			continue
+		}
+		if err := eachHooks.BeforeDelete(ctx, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookAfterDelete(ctx context.Context, filter interface{}, result *mongo.DeleteResult) error {
+	for _, eachHooks := range r.hooks {
+		if eachHooks.AfterDelete == nil {
+			continue
+		}
+		if err := eachHooks.AfterDelete(ctx, filter, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) hookAfterFind(ctx context.Context, item interface{}) error {
+	if entityHookable, ok := item.(mongodbr.IEntityAfterFind); ok {
+		if err := entityHookable.AfterFind(ctx); err != nil {
+			return err
+		}
+	}
+	for _, eachHooks := range r.hooks {
+		if eachHooks.AfterFind == nil {
+			continue
+		}
+		if err := eachHooks.AfterFind(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invoke before hooks of write models, same as the models supported by BulkWrite
+func (r *Repository) hookBeforeWriteModels(ctx context.Context, models []mongo.WriteModel) error {
+	for _, eachModel := range models {
+		var err error
+		switch m := eachModel.(type) {
+		case *mongo.InsertOneModel:
+			err = r.hookBeforeCreate(ctx, m.Document)
+		case *mongo.UpdateOneModel:
+			err = r.hookBeforeUpdate(ctx, m.Filter, m.Update)
+		case *mongo.UpdateManyModel:
+			err = r.hookBeforeUpdate(ctx, m.Filter, m.Update)
+		case *mongo.ReplaceOneModel:
+			err = r.hookBeforeReplace(ctx, m.Filter, m.Replacement)
+		case *mongo.DeleteOneModel:
+			err = r.hookBeforeDelete(ctx, m.Filter)
+		case *mongo.DeleteManyModel:
+			err = r.hookBeforeDelete(ctx, m.Filter)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invoke after hooks of write models
+func (r *Repository) hookAfterWriteModels(ctx context.Context, models []mongo.WriteModel) error {
+	for _, eachModel := range models {
+		var err error
+		switch m := eachModel.(type) {
+		case *mongo.InsertOneModel:
+			err = r.hookAfterCreate(ctx, m.Document)
+		case *mongo.UpdateOneModel:
+			err = r.hookAfterUpdate(ctx, m.Filter, m.Update)
+		case *mongo.UpdateManyModel:
+			err = r.hookAfterUpdate(ctx, m.Filter, m.Update)
+		case *mongo.ReplaceOneModel:
+			err = r.hookAfterUpdate(ctx, m.Filter, m.Replacement)
+		case *mongo.DeleteOneModel:
+			err = r.hookAfterDelete(ctx, m.Filter, nil)
+		case *mongo.DeleteManyModel:
+			err = r.hookAfterDelete(ctx, m.Filter, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// #endregion
+
+// ctx of options, context.Background() if not set
+func contextOf(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
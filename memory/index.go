@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/abmpio/mongodbr"
+)
+
+const (
+	_idIndexName = "_id_"
+)
+
+type indexDefine struct {
+	name               string
+	keys               bson.D
+	unique             bool
+	sparse             bool
+	expireAfterSeconds *int32
+}
+
+var _ mongodbr.IEntityIndex = (*Repository)(nil)
+
+// #region IEntityIndex Members
+
+func (r *Repository) CreateIndex(indexModel mongo.IndexModel, opts ...*options.CreateIndexesOptions) (string, error) {
+	define, err := newIndexDefine(indexModel)
+	if err != nil {
+		return "", err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, eachIndex := range r.indexes {
+		if eachIndex.name == define.name {
+			return define.name, nil
+		}
+	}
+	if define.unique {
+		for index, eachDoc := range r.documents {
+			if err := checkUnique(define, eachDoc, r.documents, index); err != nil {
+				return "", err
+			}
+		}
+	}
+	r.indexes = append(r.indexes, define)
+	return define.name, nil
+}
+
+func (r *Repository) CreateIndexes(indexModelList []mongo.IndexModel, opts ...*options.CreateIndexesOptions) ([]string, error) {
+	nameList := make([]string, 0, len(indexModelList))
+	for _, eachIndexModel := range indexModelList {
+		name, err := r.CreateIndex(eachIndexModel, opts...)
+		if err != nil {
+			return nil, err
+		}
+		nameList = append(nameList, name)
+	}
+	return nameList, nil
+}
+
+func (r *Repository) MustCreateIndex(indexModel mongo.IndexModel, opts ...*options.CreateIndexesOptions) {
+	r.CreateIndex(indexModel, opts...)
+}
+
+func (r *Repository) MustCreateIndexes(indexModelList []mongo.IndexModel, opts ...*options.CreateIndexesOptions) {
+	r.CreateIndexes(indexModelList, opts...)
+}
+
+func (r *Repository) DeleteIndex(name string) (err error) {
+	if name == _idIndexName {
+		return fmt.Errorf("cannot drop _id index")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for index, eachIndex := range r.indexes {
+		if eachIndex.name == name {
+			r.indexes = append(r.indexes[:index:index], r.indexes[index+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("index not found with name [%s]", name)
+}
+
+func (r *Repository) DeleteAllIndexes() (err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.indexes = make([]*indexDefine, 0)
+	return nil
+}
+
+func (r *Repository) ListIndexes() (indexes []map[string]interface{}, err error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	indexes = append(indexes, map[string]interface{}{
+		"v":    int32(2),
+		"key":  bson.D{{Key: "_id", Value: int32(1)}},
+		"name": _idIndexName,
+	})
+	for _, eachIndex := range r.indexes {
+		item := map[string]interface{}{
+			"v":    int32(2),
+			"key":  cloneD(eachIndex.keys),
+			"name": eachIndex.name,
+		}
+		if eachIndex.unique {
+			item["unique"] = true
+		}
+		if eachIndex.sparse {
+			item["sparse"] = true
+		}
+		if eachIndex.expireAfterSeconds != nil {
+			item["expireAfterSeconds"] = *eachIndex.expireAfterSeconds
+		}
+		indexes = append(indexes, item)
+	}
+	return indexes, nil
+}
+
+func (r *Repository) ExistIndex(name string) (bool, error) {
+	list, err := r.ListIndexes()
+	if err != nil {
+		return false, err
+	}
+	for _, eachIndex := range list {
+		if eachIndex["name"] == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// #endregion
+
+func newIndexDefine(indexModel mongo.IndexModel) (*indexDefine, error) {
+	keys, err := toBsonD(indexModel.Keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) <= 0 {
+		return nil, fmt.Errorf("index keys cannot be empty")
+	}
+	indexOptions := &options.IndexOptions{}
+	if indexModel.Options != nil {
+		for _, applyOption := range indexModel.Options.List() {
+			if err := applyOption(indexOptions); err != nil {
+				return nil, err
+			}
+		}
+	}
+	define := &indexDefine{
+		keys:               keys,
+		unique:             isTrue(indexOptions.Unique),
+		sparse:             isTrue(indexOptions.Sparse),
+		expireAfterSeconds: indexOptions.ExpireAfterSeconds,
+	}
+	if indexOptions.Name != nil {
+		define.name = *indexOptions.Name
+	} else {
+		nameList := make([]string, 0, len(keys))
+		for _, e := range keys {
+			nameList = append(nameList, fmt.Sprintf("%s_%v", e.Key, e.Value))
+		}
+		define.name = strings.Join(nameList, "_")
+	}
+	return define, nil
+}
+
+// check all unique indexes for doc,skipIndex is the position of doc self in documents
+func (r *Repository) checkUniqueLocked(doc bson.D, skipIndex int) error {
+	for _, eachIndex := range r.indexes {
+		if !eachIndex.unique {
+			continue
+		}
+		if err := checkUnique(eachIndex, doc, r.documents, skipIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkUnique(define *indexDefine, doc bson.D, documents []bson.D, skipIndex int) error {
+	key, exists := indexKey(define, doc)
+	if define.sparse && !exists {
+		return nil
+	}
+	for index, eachDoc := range documents {
+		if index == skipIndex {
+			continue
+		}
+		otherKey, otherExists := indexKey(define, eachDoc)
+		if define.sparse && !otherExists {
+			continue
+		}
+		if valuesEqual(key, otherKey) {
+			return duplicateKeyError(define.name, key)
+		}
+	}
+	return nil
+}
+
+// get the key values of document for index,the second return value is false if no key field exists
+func indexKey(define *indexDefine, doc bson.D) (bson.A, bool) {
+	key := make(bson.A, 0, len(define.keys))
+	exists := false
+	for _, e := range define.keys {
+		values := resolvePath(doc, splitPath(e.Key))
+		if len(values) <= 0 {
+			key = append(key, nil)
+			continue
+		}
+		exists = true
+		key = append(key, values[0])
+	}
+	return key, exists
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+func TestUniqueIndex(t *testing.T) {
+	cases := []struct {
+		name       string
+		sparse     bool
+		docList    []bson.M
+		wantDupKey bool
+	}{
+		{
+			name:    "different values",
+			docList: []bson.M{{"email": "a"}, {"email": "b"}},
+		},
+		{
+			name:       "same value",
+			docList:    []bson.M{{"email": "a"}, {"email": "a"}},
+			wantDupKey: true,
+		},
+		{
+			name:       "missing values are equal",
+			docList:    []bson.M{{"name": "a"}, {"name": "b"}},
+			wantDupKey: true,
+		},
+		{
+			name:    "sparse index skips missing values",
+			sparse:  true,
+			docList: []bson.M{{"name": "a"}, {"name": "b"}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewRepository("users")
+			_, err := r.CreateIndex(mongo.IndexModel{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true).SetSparse(c.sparse),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lastErr error
+			for _, eachDoc := range c.docList {
+				if _, err := r.Create(eachDoc); err != nil {
+					lastErr = err
+				}
+			}
+			if got := mongo.IsDuplicateKeyError(lastErr); got != c.wantDupKey {
+				t.Errorf("duplicate key error = %v, want %v", lastErr, c.wantDupKey)
+			}
+		})
+	}
+}
+
+func TestUniqueIndexOnUpdate(t *testing.T) {
+	r := NewRepository("users")
+	if _, err := r.CreateIndex(mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateMany([]interface{}{bson.M{"email": "a"}, bson.M{"email": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := r.UpdateOne(bson.M{"email": "b"}, bson.M{"$set": bson.M{"email": "a"}})
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Errorf("UpdateOne() error = %v, want duplicate key error", err)
+	}
+	// updating the document to its own value is not a duplicate
+	if err := r.UpdateOne(bson.M{"email": "a"}, bson.M{"$set": bson.M{"email": "a"}}); err != nil {
+		t.Errorf("UpdateOne() error = %v", err)
+	}
+}
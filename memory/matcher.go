@@ -0,0 +1,329 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/abmpio/mongodbr/builder"
+)
+
+// check if document matches the normalized filter
+func matchDocument(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		switch e.Key {
+		case builder.Op_And().String(), builder.Op_Or().String(), builder.Op_Nor().String():
+			clauses, ok := e.Value.(bson.A)
+			if !ok || len(clauses) <= 0 {
+				return false, fmt.Errorf("%s must be a nonempty array", e.Key)
+			}
+			matchedCount := 0
+			for _, eachClause := range clauses {
+				clause, ok := eachClause.(bson.D)
+				if !ok {
+					return false, fmt.Errorf("%s entries must be documents", e.Key)
+				}
+				matched, err := matchDocument(doc, clause)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					matchedCount++
+				}
+			}
+			switch e.Key {
+			case builder.Op_And().String():
+				if matchedCount != len(clauses) {
+					return false, nil
+				}
+			case builder.Op_Or().String():
+				if matchedCount <= 0 {
+					return false, nil
+				}
+			default:
+				if matchedCount > 0 {
+					return false, nil
+				}
+			}
+		case "$comment":
+			continue
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return false, fmt.Errorf("%w: top level operator %s", ErrUnsupported, e.Key)
+			}
+			matched, err := matchField(doc, e.Key, e.Value)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// check if field at path matches the condition,condition may be a value or an operator document
+func matchField(doc bson.D, path string, condition interface{}) (bool, error) {
+	values := resolvePath(doc, splitPath(path))
+	return matchValues(values, condition)
+}
+
+func matchValues(values []interface{}, condition interface{}) (bool, error) {
+	if regex, ok := condition.(bson.Regex); ok {
+		return matchRegex(values, regex.Pattern, regex.Options)
+	}
+	conditionDoc, ok := condition.(bson.D)
+	if !ok || !isOperatorDocument(conditionDoc) {
+		return matchEq(values, condition), nil
+	}
+	for _, e := range conditionDoc {
+		matched, err := matchOperator(values, e.Key, e.Value, conditionDoc)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func isOperatorDocument(d bson.D) bool {
+	return len(d) > 0 && strings.HasPrefix(d[0].Key, "$")
+}
+
+func matchOperator(values []interface{}, op string, value interface{}, conditionDoc bson.D) (bool, error) {
+	switch op {
+	case builder.Op_Eq().String():
+		return matchEq(values, value), nil
+	case builder.Op_Ne().String():
+		return !matchEq(values, value), nil
+	case builder.Op_Gt().String(), builder.Op_Gte().String(), builder.Op_Lt().String(), builder.Op_Lte().String():
+		for _, eachValue := range expandCandidates(values) {
+			c, ok := compareValues(eachValue, value)
+			if !ok {
+				continue
+			}
+			if (op == builder.Op_Gt().String() && c > 0) ||
+				(op == builder.Op_Gte().String() && c >= 0) ||
+				(op == builder.Op_Lt().String() && c < 0) ||
+				(op == builder.Op_Lte().String() && c <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case builder.Op_In().String(), builder.Op_Nin().String():
+		list, ok := value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("%s needs an array", op)
+		}
+		matched, err := matchIn(values, list)
+		if err != nil {
+			return false, err
+		}
+		if op == builder.Op_Nin().String() {
+			return !matched, nil
+		}
+		return matched, nil
+	case builder.Op_Exists().String():
+		return isTruthy(value) == (len(values) > 0), nil
+	case builder.Op_Regex().String():
+		pattern, options := "", ""
+		switch v := value.(type) {
+		case string:
+			pattern = v
+		case bson.Regex:
+			pattern, options = v.Pattern, v.Options
+		default:
+			return false, fmt.Errorf("$regex has to be a string")
+		}
+		for _, e := range conditionDoc {
+			if e.Key == "$options" {
+				options = fmt.Sprint(e.Value)
+			}
+		}
+		return matchRegex(values, pattern, options)
+	case "$options":
+		// handled by $regex
+		return true, nil
+	case builder.Op_Not().String():
+		matched, err := matchValues(values, value)
+		if err != nil {
+			return false, err
+		}
+		return !matched, nil
+	case builder.Op_ElemMatch().String():
+		condition, ok := value.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("$elemMatch needs an object")
+		}
+		for _, eachValue := range values {
+			array, ok := eachValue.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, eachItem := range array {
+				var matched bool
+				var err error
+				if isOperatorDocument(condition) {
+					matched, err = matchValues([]interface{}{eachItem}, condition)
+				} else if itemDoc, ok := eachItem.(bson.D); ok {
+					matched, err = matchDocument(itemDoc, condition)
+				}
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	case "$size":
+		size, ok := toFloat(value)
+		if !ok {
+			return false, fmt.Errorf("$size needs a number")
+		}
+		for _, eachValue := range values {
+			if array, ok := eachValue.(bson.A); ok && float64(len(array)) == size {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$all":
+		list, ok := value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("$all needs an array")
+		}
+		if len(list) <= 0 {
+			return false, nil
+		}
+		for _, eachItem := range list {
+			if !matchEq(values, eachItem) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case builder.Op_Type().String():
+		for _, eachValue := range expandCandidates(values) {
+			if matchType(eachValue, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: query operator %s", ErrUnsupported, op)
+}
+
+// equality with array semantics: a value matches if the field equals it,
+// or the field is an array which contains it. nil matches missing fields.
+func matchEq(values []interface{}, value interface{}) bool {
+	if value == nil && len(values) <= 0 {
+		return true
+	}
+	for _, eachValue := range expandCandidates(values) {
+		if valuesEqual(eachValue, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIn(values []interface{}, list bson.A) (bool, error) {
+	for _, eachItem := range list {
+		if regex, ok := eachItem.(bson.Regex); ok {
+			matched, err := matchRegex(values, regex.Pattern, regex.Options)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+			continue
+		}
+		if matchEq(values, eachItem) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func matchRegex(values []interface{}, pattern string, options string) (bool, error) {
+	flags := ""
+	for _, eachOption := range options {
+		switch eachOption {
+		case 'i', 'm', 's':
+			flags += string(eachOption)
+		}
+	}
+	if len(flags) > 0 {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, eachValue := range expandCandidates(values) {
+		if s, ok := eachValue.(string); ok && regex.MatchString(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var _typeAliasList = map[string]func(v interface{}) bool{
+	"double":    func(v interface{}) bool { _, ok := v.(float64); return ok },
+	"string":    func(v interface{}) bool { _, ok := v.(string); return ok },
+	"object":    func(v interface{}) bool { _, ok := v.(bson.D); return ok },
+	"array":     func(v interface{}) bool { _, ok := v.(bson.A); return ok },
+	"binData":   func(v interface{}) bool { _, ok := v.(bson.Binary); return ok },
+	"objectId":  func(v interface{}) bool { _, ok := v.(bson.ObjectID); return ok },
+	"bool":      func(v interface{}) bool { _, ok := v.(bool); return ok },
+	"date":      func(v interface{}) bool { _, ok := v.(bson.DateTime); return ok },
+	"null":      func(v interface{}) bool { return v == nil },
+	"regex":     func(v interface{}) bool { _, ok := v.(bson.Regex); return ok },
+	"int":       func(v interface{}) bool { _, ok := v.(int32); return ok },
+	"timestamp": func(v interface{}) bool { _, ok := v.(bson.Timestamp); return ok },
+	"long":      func(v interface{}) bool { _, ok := v.(int64); return ok },
+	"decimal":   func(v interface{}) bool { _, ok := v.(bson.Decimal128); return ok },
+	"number": func(v interface{}) bool {
+		_, ok := toFloat(v)
+		return ok
+	},
+}
+
+var _typeNumberList = map[int]string{
+	1: "double", 2: "string", 3: "object", 4: "array", 5: "binData", 7: "objectId",
+	8: "bool", 9: "date", 10: "null", 11: "regex", 16: "int", 17: "timestamp", 18: "long", 19: "decimal",
+}
+
+func matchType(v interface{}, typeValue interface{}) bool {
+	if list, ok := typeValue.(bson.A); ok {
+		for _, eachType := range list {
+			if matchType(v, eachType) {
+				return true
+			}
+		}
+		return false
+	}
+	alias, ok := typeValue.(string)
+	if !ok {
+		number, isNumber := toFloat(typeValue)
+		if !isNumber {
+			return false
+		}
+		alias = _typeNumberList[int(number)]
+	}
+	check, ok := _typeAliasList[alias]
+	return ok && check(v)
+}
+
+func isTruthy(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMatchDocument(t *testing.T) {
+	doc := bson.D{
+		{Key: "_id", Value: int32(1)},
+		{Key: "name", Value: "Alice"},
+		{Key: "age", Value: int32(30)},
+		{Key: "score", Value: 9.5},
+		{Key: "nickname", Value: nil},
+		{Key: "tags", Value: bson.A{"a", "b"}},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Paris"}, {Key: "zip", Value: "75001"}}},
+		{Key: "items", Value: bson.A{
+			bson.D{{Key: "name", Value: "x"}, {Key: "qty", Value: int32(1)}},
+			bson.D{{Key: "name", Value: "y"}, {Key: "qty", Value: int32(5)}},
+		}},
+	}
+	cases := []struct {
+		name   string
+		filter bson.D
+		want   bool
+	}{
+		{name: "empty filter", filter: bson.D{}, want: true},
+		{name: "implicit $eq", filter: bson.D{{Key: "name", Value: "Alice"}}, want: true},
+		{name: "implicit $eq not matched", filter: bson.D{{Key: "name", Value: "Bob"}}, want: false},
+		{name: "$eq", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$eq", Value: int32(30)}}}}, want: true},
+		{name: "$eq across number types", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$eq", Value: 30.0}}}}, want: true},
+		{name: "$eq null matches null", filter: bson.D{{Key: "nickname", Value: nil}}, want: true},
+		{name: "$eq null matches missing", filter: bson.D{{Key: "missing", Value: nil}}, want: true},
+		{name: "$ne", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: "Bob"}}}}, want: true},
+		{name: "$in", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"Bob", "Alice"}}}}}, want: true},
+		{name: "$in not matched", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"Bob"}}}}}, want: false},
+		{name: "$in with regex", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{bson.Regex{Pattern: "^al", Options: "i"}}}}}}, want: true},
+		{name: "$nin", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$nin", Value: bson.A{"Bob"}}}}}, want: true},
+		{name: "$nin not matched", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$nin", Value: bson.A{"Alice"}}}}}, want: false},
+		{name: "$gt", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: int32(20)}}}}, want: true},
+		{name: "$gt not matched", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: int32(30)}}}}, want: false},
+		{name: "$gte and $lt range", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$gte", Value: int32(30)}, {Key: "$lt", Value: int64(31)}}}}, want: true},
+		{name: "$gt does not compare across types", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$gt", Value: int32(1)}}}}, want: false},
+		{name: "$regex", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^Ali"}}}}, want: true},
+		{name: "$regex with $options", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ali"}, {Key: "$options", Value: "i"}}}}, want: true},
+		{name: "$regex case sensitive", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ali"}}}}, want: false},
+		{name: "regex value", filter: bson.D{{Key: "name", Value: bson.Regex{Pattern: "ce$"}}}, want: true},
+		{name: "$exists true", filter: bson.D{{Key: "nickname", Value: bson.D{{Key: "$exists", Value: true}}}}, want: true},
+		{name: "$exists false", filter: bson.D{{Key: "missing", Value: bson.D{{Key: "$exists", Value: false}}}}, want: true},
+		{name: "$exists true on missing", filter: bson.D{{Key: "missing", Value: bson.D{{Key: "$exists", Value: true}}}}, want: false},
+		{name: "$and", filter: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "name", Value: "Alice"}},
+			bson.D{{Key: "age", Value: int32(30)}},
+		}}}, want: true},
+		{name: "$and not matched", filter: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "name", Value: "Alice"}},
+			bson.D{{Key: "age", Value: int32(31)}},
+		}}}, want: false},
+		{name: "$or", filter: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "name", Value: "Bob"}},
+			bson.D{{Key: "age", Value: int32(30)}},
+		}}}, want: true},
+		{name: "$or not matched", filter: bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "name", Value: "Bob"}},
+			bson.D{{Key: "age", Value: int32(31)}},
+		}}}, want: false},
+		{name: "$nor", filter: bson.D{{Key: "$nor", Value: bson.A{
+			bson.D{{Key: "name", Value: "Bob"}},
+			bson.D{{Key: "age", Value: int32(31)}},
+		}}}, want: true},
+		{name: "$nor not matched", filter: bson.D{{Key: "$nor", Value: bson.A{
+			bson.D{{Key: "name", Value: "Alice"}},
+		}}}, want: false},
+		{name: "$not", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: int32(40)}}}}}}, want: true},
+		{name: "$not not matched", filter: bson.D{{Key: "age", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: int32(20)}}}}}}, want: false},
+		{name: "$not matches missing", filter: bson.D{{Key: "missing", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: int32(1)}}}}}}, want: true},
+		{name: "$not with regex", filter: bson.D{{Key: "name", Value: bson.D{{Key: "$not", Value: bson.Regex{Pattern: "^B"}}}}}, want: true},
+		// arrays
+		{name: "array contains value", filter: bson.D{{Key: "tags", Value: "b"}}, want: true},
+		{name: "array equals whole value", filter: bson.D{{Key: "tags", Value: bson.A{"a", "b"}}}, want: true},
+		{name: "array whole value in other order", filter: bson.D{{Key: "tags", Value: bson.A{"b", "a"}}}, want: false},
+		{name: "array $in", filter: bson.D{{Key: "tags", Value: bson.D{{Key: "$in", Value: bson.A{"c", "a"}}}}}, want: true},
+		{name: "array $nin", filter: bson.D{{Key: "tags", Value: bson.D{{Key: "$nin", Value: bson.A{"a"}}}}}, want: false},
+		{name: "array $all", filter: bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"b", "a"}}}}}, want: true},
+		{name: "array $size", filter: bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: int32(2)}}}}, want: true},
+		// dotted paths
+		{name: "embedded document", filter: bson.D{{Key: "address.city", Value: "Paris"}}, want: true},
+		{name: "embedded document not matched", filter: bson.D{{Key: "address.city", Value: "Rome"}}, want: false},
+		{name: "missing embedded field", filter: bson.D{{Key: "address.street", Value: bson.D{{Key: "$exists", Value: false}}}}, want: true},
+		{name: "path through array of documents", filter: bson.D{{Key: "items.name", Value: "y"}}, want: true},
+		{name: "path through array with operator", filter: bson.D{{Key: "items.qty", Value: bson.D{{Key: "$gt", Value: int32(4)}}}}, want: true},
+		{name: "array index", filter: bson.D{{Key: "items.1.name", Value: "y"}}, want: true},
+		{name: "array index not matched", filter: bson.D{{Key: "items.0.name", Value: "y"}}, want: false},
+		{name: "array value index", filter: bson.D{{Key: "tags.0", Value: "a"}}, want: true},
+		{name: "$elemMatch", filter: bson.D{{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "name", Value: "x"},
+			{Key: "qty", Value: bson.D{{Key: "$gt", Value: int32(2)}}},
+		}}}}}, want: false},
+		{name: "$elemMatch matched", filter: bson.D{{Key: "items", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "name", Value: "y"},
+			{Key: "qty", Value: bson.D{{Key: "$gt", Value: int32(2)}}},
+		}}}}}, want: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := matchDocument(doc, c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("matchDocument(%v) = %v, want %v", c.filter, got, c.want)
+			}
+		})
+	}
+}
+
+func TestMatchDocumentError(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter bson.D
+	}{
+		{name: "empty $or", filter: bson.D{{Key: "$or", Value: bson.A{}}}},
+		{name: "$and entry is not a document", filter: bson.D{{Key: "$and", Value: bson.A{1}}}},
+		{name: "$in needs an array", filter: bson.D{{Key: "a", Value: bson.D{{Key: "$in", Value: 1}}}}},
+		{name: "invalid regex", filter: bson.D{{Key: "a", Value: bson.D{{Key: "$regex", Value: "("}}}}},
+		{name: "unsupported top level operator", filter: bson.D{{Key: "$where", Value: "true"}}},
+		{name: "unsupported query operator", filter: bson.D{{Key: "a", Value: bson.D{{Key: "$near", Value: bson.A{}}}}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := matchDocument(bson.D{{Key: "a", Value: "x"}}, c.filter); err == nil {
+				t.Errorf("matchDocument(%v) error = nil, want error", c.filter)
+			}
+		})
+	}
+}
@@ -0,0 +1,1068 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+
+	"github.com/abmpio/mongodbr"
+	"github.com/abmpio/mongodbr/builder"
+)
+
+var (
+	ErrUnsupported = errors.New("not supported by memory repository")
+)
+
+// Repository is an in-memory implementation of mongodbr.IRepository,
+// it's used to unit test services without a running mongodb
+type Repository struct {
+	name string
+
+	lock      sync.RWMutex
+	documents []bson.D
+	indexes   []*indexDefine
+	hooks     []*mongodbr.RepositoryHooks
+}
+
+var _ mongodbr.IRepository = (*Repository)(nil)
+
+// new an in-memory repository with the collection name,
+// the entity hooks and the hooks added by WithHooks are invoked like mongodbr.MongoCol
+func NewRepository(name string, opts ...RepositoryOption) *Repository {
+	r := &Repository{
+		name:      name,
+		documents: make([]bson.D, 0),
+		indexes:   make([]*indexDefine, 0),
+	}
+	for _, eachOpt := range opts {
+		eachOpt(r)
+	}
+	return r
+}
+
+// remove all documents and indexes
+func (r *Repository) Clear() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.documents = make([]bson.D, 0)
+	r.indexes = make([]*indexDefine, 0)
+}
+
+func (r *Repository) GetName() (name string) {
+	return r.name
+}
+
+// memory repository has no mongo.Collection, always return nil
+func (r *Repository) GetCollection() (c *mongo.Collection) {
+	return nil
+}
+
+// #region IEntityCreate Members
+
+func (r *Repository) Create(data interface{}, opts ...mongodbr.MongodbrInsertOneOption) (id bson.ObjectID, err error) {
+	if isNil(data) {
+		return bson.NilObjectID, mongodbr.ErrNilItem
+	}
+	ctx := contextOf(mongodbr.MergeMongodbrInsertOneOption(opts...).WithCtx)
+	if err := r.hookBeforeCreate(ctx, data); err != nil {
+		return bson.NilObjectID, err
+	}
+	doc, err := prepareInsert(data)
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+	if err := mongodbr.Validate(data); err != nil {
+		return bson.NilObjectID, err
+	}
+	r.lock.Lock()
+	err = r.insertLocked(doc)
+	r.lock.Unlock()
+	if err != nil {
+		return bson.NilObjectID, err
+	}
+	if err := r.hookAfterCreate(ctx, data); err != nil {
+		return bson.NilObjectID, err
+	}
+	if id, ok := doc[0].Value.(bson.ObjectID); ok {
+		return id, nil
+	}
+	return bson.NilObjectID, mongodbr.ErrInvalidType
+}
+
+func (r *Repository) CreateMany(itemList []interface{}, opts ...mongodbr.MongodbrInsertManyOption) (ids []bson.ObjectID, err error) {
+	if len(itemList) <= 0 {
+		return nil, nil
+	}
+	ctx := contextOf(mongodbr.MergeMongodbrInsertManyOption(opts...).WithCtx)
+	// all before hooks are invoked before inserting, so nothing is inserted if any hook fails
+	for _, eachItem := range itemList {
+		if isNil(eachItem) {
+			return nil, mongodbr.ErrNilItem
+		}
+		if err := r.hookBeforeCreate(ctx, eachItem); err != nil {
+			return nil, err
+		}
+	}
+	docList := make([]bson.D, 0, len(itemList))
+	for _, eachItem := range itemList {
+		doc, err := prepareInsert(eachItem)
+		if err != nil {
+			return nil, err
+		}
+		docList = append(docList, doc)
+	}
+	if err := mongodbr.ValidateMany(itemList); err != nil {
+		return nil, err
+	}
+	if err := r.insertMany(docList); err != nil {
+		return nil, err
+	}
+	for _, eachItem := range itemList {
+		if err := r.hookAfterCreate(ctx, eachItem); err != nil {
+			return nil, err
+		}
+	}
+	for _, eachDoc := range docList {
+		id, ok := eachDoc[0].Value.(bson.ObjectID)
+		if !ok {
+			return nil, mongodbr.ErrInvalidType
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// #endregion
+
+// #region IEntityFind Members
+
+func (r *Repository) CountByFilter(filter interface{}, opts ...mongodbr.MongodbrCountOption) (count int64, err error) {
+	cOptions := mongodbr.MergeMongodbrCountOption(opts...)
+	docList, err := r.findDocuments(filter)
+	if err != nil {
+		return 0, err
+	}
+	docList = skipAndLimit(docList, cOptions.Skip, cOptions.Limit)
+	return int64(len(docList)), nil
+}
+
+func (r *Repository) CountAll(opts ...mongodbr.WithContextOptions) (count int64, err error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return int64(len(r.documents)), nil
+}
+
+func (r *Repository) FindAll(list interface{}, opts ...mongodbr.MongodbrFindOption) error {
+	return r.FindListByFilter(bson.M{}, list, opts...)
+}
+
+func (r *Repository) FindListByFilter(filter interface{}, list interface{}, opts ...mongodbr.MongodbrFindOption) error {
+	docList, err := r.find(filter, opts...)
+	if err != nil {
+		return err
+	}
+	if err := decodeList(docList, list); err != nil {
+		return err
+	}
+	return r.AfterFindList(contextOf(mongodbr.MergeMongodbrFindOption(opts...).WithCtx), list)
+}
+
+func (r *Repository) FindListResultByFilter(filter interface{}, opts ...mongodbr.MongodbrFindOption) mongodbr.IFindResult {
+	docList, err := r.find(filter, opts...)
+	if err != nil {
+		return &findResult{err: err}
+	}
+	cur, err := newCursor(docList)
+	return &findResult{
+		cur:        cur,
+		err:        err,
+		repository: r,
+		context:    contextOf(mongodbr.MergeMongodbrFindOption(opts...).WithCtx),
+	}
+}
+
+func (r *Repository) FindListByObjectIdList(idList []bson.ObjectID, list interface{}, opts ...mongodbr.MongodbrFindOption) error {
+	return r.FindListByFilter(bson.M{"_id": bson.M{
+		"$in": idList,
+	}}, list, opts...)
+}
+
+func (r *Repository) FindOneByObjectId(id bson.ObjectID, v interface{}, opts ...mongodbr.MongodbrFindOneOption) error {
+	return r.FindOne(bson.M{"_id": id}, v, opts...)
+}
+
+func (r *Repository) FindOne(filter interface{}, v interface{}, opts ...mongodbr.MongodbrFindOneOption) error {
+	mOptions := mongodbr.MergeMongodbrFindOneOption(opts...)
+	docList, err := r.findDocuments(filter)
+	if err != nil {
+		return err
+	}
+	docList, err = applyFindOptions(docList, mOptions.Sort, mOptions.Skip, nil, mOptions.Projection)
+	if err != nil {
+		return err
+	}
+	if len(docList) <= 0 {
+		return mongo.ErrNoDocuments
+	}
+	if err := mongo.NewSingleResultFromDocument(docList[0], nil, nil).Decode(v); err != nil {
+		return err
+	}
+	return r.hookAfterFind(contextOf(mOptions.WithCtx), v)
+}
+
+func (r *Repository) Distinct(fieldName string, filter interface{}, opts ...*mongodbr.WithContextOptions) ([]interface{}, error) {
+	docList, err := r.findDocuments(filter)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0)
+	for _, eachDoc := range docList {
+		for _, eachValue := range resolvePath(eachDoc, splitPath(fieldName)) {
+			candidates := []interface{}{eachValue}
+			if array, ok := eachValue.(bson.A); ok {
+				candidates = array
+			}
+			for _, eachCandidate := range candidates {
+				if !matchEq([]interface{}{bson.A(values)}, eachCandidate) {
+					values = append(values, eachCandidate)
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// #endregion
+
+// #region IEntityUpdate Members
+
+func (r *Repository) FindOneAndUpdate(entity mongodbr.IEntity, opts ...mongodbr.MongodbrFindOneAndUpdateOption) error {
+	uOptions := mongodbr.MergeMongodbrFindOneAndUpdateOption(opts...)
+	ctx := contextOf(uOptions.WithCtx)
+	filter := bson.M{"_id": entity.GetObjectId()}
+	if err := r.hookBeforeUpdate(ctx, filter, entity); err != nil {
+		return err
+	}
+	if err := mongodbr.Validate(entity); err != nil {
+		return err
+	}
+	versionedEntity, ok := entity.(mongodbr.IVersionedEntity)
+	if !ok {
+		update := builder.NewBsonBuilder().NewOrUpdateSet(entity).ToValue()
+		if err := r.updateById(entity.GetObjectId(), update, isTrue(uOptions.Upsert)); err != nil {
+			return err
+		}
+		return r.hookAfterUpdate(ctx, filter, entity)
+	}
+	expectedVersion := versionedEntity.GetVersion()
+	versionedEntity.SetVersion(expectedVersion + 1)
+	update := builder.NewBsonBuilder().NewOrUpdateSet(entity).ToValue()
+	versionFilter := bson.D{{Key: "_id", Value: entity.GetObjectId()}, mongodbr.VersionFilter(expectedVersion)}
+	result, err := r.update(versionFilter, update, false, false)
+	if err != nil || result.MatchedCount <= 0 {
+		versionedEntity.SetVersion(expectedVersion)
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount <= 0 {
+		return mongodbr.ErrConcurrencyConflict
+	}
+	return r.hookAfterUpdate(ctx, filter, entity)
+}
+
+func (r *Repository) FindOneAndUpdateWithId(objectId bson.ObjectID, update interface{}, opts ...mongodbr.MongodbrFindOneAndUpdateOption) error {
+	uOptions := mongodbr.MergeMongodbrFindOneAndUpdateOption(opts...)
+	ctx := contextOf(uOptions.WithCtx)
+	filter := bson.M{"_id": objectId}
+	if err := r.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return err
+	}
+	if err := r.updateById(objectId, update, isTrue(uOptions.Upsert)); err != nil {
+		return err
+	}
+	return r.hookAfterUpdate(ctx, filter, update)
+}
+
+func (r *Repository) UpdateOne(filter interface{}, update interface{}, opts ...mongodbr.MongodbrUpdateOption) error {
+	uOptions := mongodbr.MergeMongodbrUpdateOption(opts...)
+	ctx := contextOf(uOptions.WithCtx)
+	if err := r.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return err
+	}
+	if _, err := r.update(filter, update, false, isTrue(uOptions.UpdateOneOptions.Upsert)); err != nil {
+		return err
+	}
+	return r.hookAfterUpdate(ctx, filter, update)
+}
+
+func (r *Repository) UpdateMany(filter interface{}, update interface{}, opts ...mongodbr.MongodbrUpdateOption) (interface{}, error) {
+	uOptions := mongodbr.MergeMongodbrUpdateOption(opts...)
+	ctx := contextOf(uOptions.WithCtx)
+	if err := r.hookBeforeUpdate(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	result, err := r.update(filter, update, true, isTrue(uOptions.UpdateManyOptions.Upsert))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.hookAfterUpdate(ctx, filter, update); err != nil {
+		return nil, err
+	}
+	return result.UpsertedID, nil
+}
+
+// #endregion
+
+// #region replace members
+
+func (r *Repository) ReplaceById(id bson.ObjectID, doc interface{}, opts ...mongodbr.MongodbrReplaceOption) (err error) {
+	return r.Replace(bson.M{"_id": id}, doc, opts...)
+}
+
+func (r *Repository) Replace(filter interface{}, doc interface{}, opts ...mongodbr.MongodbrReplaceOption) (err error) {
+	rOptions := mongodbr.MergeMongodbrReplaceOption(opts...)
+	ctx := contextOf(rOptions.WithCtx)
+	if err := r.hookBeforeReplace(ctx, filter, doc); err != nil {
+		return err
+	}
+	if err := mongodbr.Validate(doc); err != nil {
+		return err
+	}
+	if err := r.replace(filter, doc, isTrue(rOptions.Upsert)); err != nil {
+		return err
+	}
+	return r.hookAfterUpdate(ctx, filter, doc)
+}
+
+func (r *Repository) replace(filter interface{}, doc interface{}, upsert bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	versionedEntity, ok := doc.(mongodbr.IVersionedEntity)
+	if !ok {
+		_, err := r.replaceLocked(filter, doc, upsert)
+		return err
+	}
+	expectedVersion := versionedEntity.GetVersion()
+	versionedEntity.SetVersion(expectedVersion + 1)
+	versionFilter := bson.D{{Key: "$and", Value: bson.A{filter, bson.D{mongodbr.VersionFilter(expectedVersion)}}}}
+	// same as mongodbr.MongoCol, the versioned replace never upserts
+	result, err := r.replaceLocked(versionFilter, doc, false)
+	if err != nil || (result.MatchedCount <= 0 && result.UpsertedCount <= 0) {
+		versionedEntity.SetVersion(expectedVersion)
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount <= 0 && result.UpsertedCount <= 0 {
+		return mongodbr.ErrConcurrencyConflict
+	}
+	return nil
+}
+
+// #endregion
+
+// #region IEntityDelete Members
+
+func (r *Repository) DeleteOne(id bson.ObjectID, opts ...mongodbr.MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.DeleteOneByFilter(bson.M{"_id": id}, opts...)
+}
+
+func (r *Repository) DeleteOneByFilter(filter interface{}, opts ...mongodbr.MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.delete(filter, false, opts...)
+}
+
+func (r *Repository) DeleteMany(filter interface{}, opts ...mongodbr.MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	if filter == nil {
+		err := fmt.Errorf("无法删除多条%s记录,filter参数不能为null", r.name)
+		return nil, err
+	}
+	return r.delete(filter, true, opts...)
+}
+
+func (r *Repository) delete(filter interface{}, multi bool, opts ...mongodbr.MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	ctx := contextOf(mongodbr.MergeMongodbrDeleteOption(opts...).WithCtx)
+	if err := r.hookBeforeDelete(ctx, filter); err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	result, err := r.deleteLocked(filter, multi)
+	r.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.hookAfterDelete(ctx, filter, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// #endregion
+
+// #region IEntityBulkWrite Members
+
+func (r *Repository) BulkWrite(models []mongo.WriteModel, opts ...mongodbr.MongodbrBulkWriteOption) (*mongo.BulkWriteResult, error) {
+	if len(models) <= 0 {
+		return nil, nil
+	}
+	bOptions := mongodbr.MergeMongodbrBulkWriteOption(opts...)
+	ctx := contextOf(bOptions.WithCtx)
+	if err := r.hookBeforeWriteModels(ctx, models); err != nil {
+		return nil, err
+	}
+	result, _, err := r.bulkWrite(models, bOptions.Ordered == nil || *bOptions.Ordered)
+	if err != nil {
+		return result, err
+	}
+	if err := r.hookAfterWriteModels(ctx, models); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// write models in order, also return the matched count of every model
+func (r *Repository) bulkWrite(models []mongo.WriteModel, ordered bool) (*mongo.BulkWriteResult, []int64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	matchedList := make([]int64, len(models))
+	result := &mongo.BulkWriteResult{
+		UpsertedIDs:  make(map[int64]interface{}),
+		Acknowledged: true,
+	}
+	writeErrors := make(mongo.WriteErrors, 0)
+	for index, eachModel := range models {
+		matchedCount := result.MatchedCount
+		err := r.writeModelLocked(eachModel, int64(index), result)
+		matchedList[index] = result.MatchedCount - matchedCount
+		if err == nil {
+			continue
+		}
+		writeErrors = append(writeErrors, mongo.WriteError{
+			Index:   index,
+			Code:    writeErrorCode(err),
+			Message: err.Error(),
+		})
+		if ordered {
+			break
+		}
+	}
+	if len(writeErrors) > 0 {
+		return result, matchedList, mongo.BulkWriteException{WriteErrors: toBulkWriteErrors(models, writeErrors)}
+	}
+	return result, matchedList, nil
+}
+
+func (r *Repository) BulkWriteEntityList(entityList []mongodbr.IEntity, opts ...mongodbr.MongodbrBulkWriteOption) (*mongo.BulkWriteResult, error) {
+	if len(entityList) <= 0 {
+		return nil, nil
+	}
+	bOptions := mongodbr.MergeMongodbrBulkWriteOption(opts...)
+	ctx := contextOf(bOptions.WithCtx)
+	// all before hooks are invoked before writing, so nothing is written if any hook fails
+	for _, eachEntity := range entityList {
+		if err := r.hookBeforeUpdate(ctx, bson.M{"_id": eachEntity.GetObjectId()}, eachEntity); err != nil {
+			return nil, err
+		}
+	}
+	modelList := make([]mongo.WriteModel, 0, len(entityList))
+	expectedVersionList := make(map[int]int64)
+	for index, eachEntity := range entityList {
+		currentModel := mongo.NewUpdateOneModel()
+		if versionedEntity, ok := eachEntity.(mongodbr.IVersionedEntity); ok {
+			expectedVersion := versionedEntity.GetVersion()
+			expectedVersionList[index] = expectedVersion
+			versionedEntity.SetVersion(expectedVersion + 1)
+			currentModel.SetFilter(bson.D{{Key: "_id", Value: eachEntity.GetObjectId()}, mongodbr.VersionFilter(expectedVersion)})
+		} else {
+			currentModel.SetFilter(bson.M{"_id": eachEntity.GetObjectId()})
+		}
+		currentModel.SetUpdate(builder.NewBsonBuilder().NewOrUpdateSet(eachEntity).ToValue())
+		modelList = append(modelList, currentModel)
+	}
+	result, matchedList, err := r.bulkWrite(modelList, bOptions.Ordered == nil || *bOptions.Ordered)
+	// same as mongodbr.MongoCol, only the versioned entities which are not written are conflicts,
+	// and their versions are restored
+	conflicted := false
+	for index, eachVersion := range expectedVersionList {
+		if matchedList[index] > 0 {
+			continue
+		}
+		entityList[index].(mongodbr.IVersionedEntity).SetVersion(eachVersion)
+		conflicted = true
+	}
+	if err != nil {
+		return result, err
+	}
+	if conflicted {
+		return result, mongodbr.ErrConcurrencyConflict
+	}
+	for _, eachEntity := range entityList {
+		if err := r.hookAfterUpdate(ctx, bson.M{"_id": eachEntity.GetObjectId()}, eachEntity); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (r *Repository) writeModelLocked(model mongo.WriteModel, index int64, result *mongo.BulkWriteResult) error {
+	var updateResult *mongo.UpdateResult
+	var err error
+	switch m := model.(type) {
+	case *mongo.InsertOneModel:
+		doc, err := prepareInsert(m.Document)
+		if err != nil {
+			return err
+		}
+		if err := r.insertLocked(doc); err != nil {
+			return err
+		}
+		result.InsertedCount++
+		return nil
+	case *mongo.UpdateOneModel:
+		updateResult, err = r.updateLocked(m.Filter, m.Update, false, isTrue(m.Upsert))
+	case *mongo.UpdateManyModel:
+		updateResult, err = r.updateLocked(m.Filter, m.Update, true, isTrue(m.Upsert))
+	case *mongo.ReplaceOneModel:
+		updateResult, err = r.replaceLocked(m.Filter, m.Replacement, isTrue(m.Upsert))
+	case *mongo.DeleteOneModel:
+		deleteResult, err := r.deleteLocked(m.Filter, false)
+		if err != nil {
+			return err
+		}
+		result.DeletedCount += deleteResult.DeletedCount
+		return nil
+	case *mongo.DeleteManyModel:
+		deleteResult, err := r.deleteLocked(m.Filter, true)
+		if err != nil {
+			return err
+		}
+		result.DeletedCount += deleteResult.DeletedCount
+		return nil
+	default:
+		return fmt.Errorf("%w: write model %T", ErrUnsupported, model)
+	}
+	if err != nil {
+		return err
+	}
+	result.MatchedCount += updateResult.MatchedCount
+	result.ModifiedCount += updateResult.ModifiedCount
+	result.UpsertedCount += updateResult.UpsertedCount
+	if updateResult.UpsertedID != nil {
+		result.UpsertedIDs[index] = updateResult.UpsertedID
+	}
+	return nil
+}
+
+// #endregion
+
+// #region aggregate members
+
+func (r *Repository) Aggregate(pipeline interface{}, dataList interface{}, opts ...mongodbr.MongodbrAggregateOption) (err error) {
+	stageList, err := toBsonA(pipeline)
+	if err != nil {
+		return err
+	}
+	r.lock.RLock()
+	docList := make([]bson.D, 0, len(r.documents))
+	for _, eachDoc := range r.documents {
+		docList = append(docList, cloneD(eachDoc))
+	}
+	r.lock.RUnlock()
+
+	docList, err = aggregate(docList, stageList)
+	if err != nil {
+		return err
+	}
+	if err := decodeList(docList, dataList); err != nil {
+		return err
+	}
+	aOptions := mongodbr.MergeMongodbrAggregateOption(opts...)
+	if aOptions.SkipAfterFindHook {
+		return nil
+	}
+	return r.AfterFindList(contextOf(aOptions.WithCtx), dataList)
+}
+
+// #endregion
+
+// #region internal members
+
+func isNil(item interface{}) bool {
+	return item == nil || (reflect.ValueOf(item).Kind() == reflect.Ptr && reflect.ValueOf(item).IsNil())
+}
+
+// convert the item to be inserted to document, the BeforeCreate hooks should have been invoked
+func prepareInsert(item interface{}) (bson.D, error) {
+	if isNil(item) {
+		return nil, mongodbr.ErrNilItem
+	}
+	doc, err := toBsonD(item)
+	if err != nil {
+		return nil, err
+	}
+	return ensureIdFirst(doc), nil
+}
+
+// make sure _id is the first field of document,generate a ObjectID if _id is missing
+func ensureIdFirst(doc bson.D) bson.D {
+	for i, e := range doc {
+		if e.Key != "_id" {
+			continue
+		}
+		if i == 0 {
+			return doc
+		}
+		result := append(bson.D{e}, doc[:i]...)
+		return append(result, doc[i+1:]...)
+	}
+	return append(bson.D{{Key: "_id", Value: bson.NewObjectID()}}, doc...)
+}
+
+func (r *Repository) insertMany(docList []bson.D) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, eachDoc := range docList {
+		if err := r.insertLocked(eachDoc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) insertLocked(doc bson.D) error {
+	for _, eachDoc := range r.documents {
+		if valuesEqual(eachDoc[0].Value, doc[0].Value) {
+			return duplicateKeyError("_id_", doc[0].Value)
+		}
+	}
+	if err := r.checkUniqueLocked(doc, -1); err != nil {
+		return err
+	}
+	r.documents = append(r.documents, cloneD(doc))
+	return nil
+}
+
+// find all documents matched filter,the returned documents are copies
+func (r *Repository) findDocuments(filter interface{}) ([]bson.D, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	indexList, err := r.matchLocked(filter, true)
+	if err != nil {
+		return nil, err
+	}
+	docList := make([]bson.D, 0, len(indexList))
+	for _, eachIndex := range indexList {
+		docList = append(docList, cloneD(r.documents[eachIndex]))
+	}
+	return docList, nil
+}
+
+func (r *Repository) find(filter interface{}, opts ...mongodbr.MongodbrFindOption) ([]bson.D, error) {
+	findOptions := mongodbr.MergeMongodbrFindOption(opts...)
+	docList, err := r.findDocuments(filter)
+	if err != nil {
+		return nil, err
+	}
+	return applyFindOptions(docList, findOptions.Sort, findOptions.Skip, findOptions.Limit, findOptions.Projection)
+}
+
+// get the positions of documents matched filter
+func (r *Repository) matchLocked(filter interface{}, multi bool) ([]int, error) {
+	filterDoc, err := toBsonD(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int, 0)
+	for index, eachDoc := range r.documents {
+		matched, err := matchDocument(eachDoc, filterDoc)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		result = append(result, index)
+		if !multi {
+			break
+		}
+	}
+	return result, nil
+}
+
+// update the document by id, mongo.ErrNoDocuments is returned when no document matched
+func (r *Repository) updateById(id bson.ObjectID, update interface{}, upsert bool) error {
+	result, err := r.update(bson.M{"_id": id}, update, false, upsert)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount <= 0 && result.UpsertedCount <= 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (r *Repository) update(filter interface{}, update interface{}, multi bool, upsert bool) (*mongo.UpdateResult, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.updateLocked(filter, update, multi, upsert)
+}
+
+func (r *Repository) updateLocked(filter interface{}, update interface{}, multi bool, upsert bool) (*mongo.UpdateResult, error) {
+	updateDoc, err := normalizeUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	if isReplacement(updateDoc) {
+		return nil, fmt.Errorf("update document must contain key beginning with '$'")
+	}
+	indexList, err := r.matchLocked(filter, multi)
+	if err != nil {
+		return nil, err
+	}
+	result := &mongo.UpdateResult{Acknowledged: true}
+	if len(indexList) <= 0 {
+		if !upsert {
+			return result, nil
+		}
+		filterDoc, err := toBsonD(filter)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := applyUpdate(upsertBase(filterDoc), updateDoc, true)
+		if err != nil {
+			return nil, err
+		}
+		doc = ensureIdFirst(doc)
+		if err := r.insertLocked(doc); err != nil {
+			return nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = doc[0].Value
+		return result, nil
+	}
+	for _, eachIndex := range indexList {
+		doc, err := applyUpdate(r.documents[eachIndex], updateDoc, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkUniqueLocked(doc, eachIndex); err != nil {
+			return nil, err
+		}
+		result.MatchedCount++
+		if !bytes.Equal(marshalValue(doc), marshalValue(r.documents[eachIndex])) {
+			result.ModifiedCount++
+		}
+		r.documents[eachIndex] = doc
+	}
+	return result, nil
+}
+
+func (r *Repository) replaceLocked(filter interface{}, replacement interface{}, upsert bool) (*mongo.UpdateResult, error) {
+	doc, err := toBsonD(replacement)
+	if err != nil {
+		return nil, err
+	}
+	if !isReplacement(doc) {
+		return nil, fmt.Errorf("replacement document cannot contain keys beginning with '$'")
+	}
+	indexList, err := r.matchLocked(filter, false)
+	if err != nil {
+		return nil, err
+	}
+	result := &mongo.UpdateResult{Acknowledged: true}
+	if len(indexList) <= 0 {
+		if !upsert {
+			return result, nil
+		}
+		doc = ensureIdFirst(doc)
+		if err := r.insertLocked(doc); err != nil {
+			return nil, err
+		}
+		result.UpsertedCount = 1
+		result.UpsertedID = doc[0].Value
+		return result, nil
+	}
+	index := indexList[0]
+	existingId := r.documents[index][0].Value
+	withoutId := make(bson.D, 0, len(doc))
+	for _, e := range doc {
+		if e.Key != "_id" {
+			withoutId = append(withoutId, e)
+			continue
+		}
+		if !valuesEqual(e.Value, existingId) {
+			return nil, fmt.Errorf("the _id field cannot be changed")
+		}
+	}
+	doc = append(bson.D{{Key: "_id", Value: existingId}}, withoutId...)
+	if err := r.checkUniqueLocked(doc, index); err != nil {
+		return nil, err
+	}
+	result.MatchedCount = 1
+	if !bytes.Equal(marshalValue(doc), marshalValue(r.documents[index])) {
+		result.ModifiedCount = 1
+	}
+	r.documents[index] = doc
+	return result, nil
+}
+
+func (r *Repository) deleteLocked(filter interface{}, multi bool) (*mongo.DeleteResult, error) {
+	indexList, err := r.matchLocked(filter, multi)
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[int]struct{}, len(indexList))
+	for _, eachIndex := range indexList {
+		removed[eachIndex] = struct{}{}
+	}
+	docList := make([]bson.D, 0, len(r.documents)-len(removed))
+	for index, eachDoc := range r.documents {
+		if _, ok := removed[index]; !ok {
+			docList = append(docList, eachDoc)
+		}
+	}
+	r.documents = docList
+	return &mongo.DeleteResult{DeletedCount: int64(len(removed)), Acknowledged: true}, nil
+}
+
+// build the base document of an upsert with the equality conditions of filter
+func upsertBase(filter bson.D) bson.D {
+	var doc interface{} = bson.D{}
+	for _, e := range filter {
+		if e.Key == builder.Op_And().String() {
+			clauses, _ := e.Value.(bson.A)
+			for _, eachClause := range clauses {
+				if clause, ok := eachClause.(bson.D); ok {
+					for _, eachField := range upsertBase(clause) {
+						doc, _ = setPath(doc, splitPath(eachField.Key), eachField.Value)
+					}
+				}
+			}
+			continue
+		}
+		if len(e.Key) <= 0 || e.Key[0] == '$' {
+			continue
+		}
+		value := e.Value
+		if condition, ok := value.(bson.D); ok && isOperatorDocument(condition) {
+			value = nil
+			for _, eachOp := range condition {
+				if eachOp.Key == builder.Op_Eq().String() {
+					value = eachOp.Value
+				}
+			}
+			if value == nil {
+				continue
+			}
+		}
+		doc, _ = setPath(doc, splitPath(e.Key), cloneValue(value))
+	}
+	return doc.(bson.D)
+}
+
+func normalizeUpdate(update interface{}) (bson.D, error) {
+	if update == nil {
+		return nil, fmt.Errorf("update cannot be nil")
+	}
+	switch update.(type) {
+	case bson.D, bson.M, bson.Raw, map[string]interface{}:
+	default:
+		if kind := reflect.TypeOf(update).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return nil, fmt.Errorf("%w: pipeline update", ErrUnsupported)
+		}
+	}
+	return toBsonD(update)
+}
+
+func applyFindOptions(docList []bson.D, sortValue interface{}, skip *int64, limit *int64, projection interface{}) ([]bson.D, error) {
+	if sortValue != nil {
+		sortDoc, err := toBsonD(sortValue)
+		if err != nil {
+			return nil, err
+		}
+		sortDocuments(docList, sortDoc)
+	}
+	docList = skipAndLimit(docList, skip, limit)
+	if projection == nil {
+		return docList, nil
+	}
+	projectionDoc, err := toBsonD(projection)
+	if err != nil {
+		return nil, err
+	}
+	for index := range docList {
+		docList[index], err = project(docList[index], projectionDoc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docList, nil
+}
+
+func skipAndLimit(docList []bson.D, skip *int64, limit *int64) []bson.D {
+	if skip != nil && *skip > 0 {
+		if *skip >= int64(len(docList)) {
+			return docList[:0]
+		}
+		docList = docList[*skip:]
+	}
+	if limit != nil && *limit > 0 && *limit < int64(len(docList)) {
+		docList = docList[:*limit]
+	}
+	return docList
+}
+
+func sortDocuments(docList []bson.D, sortDoc bson.D) {
+	sort.SliceStable(docList, func(i, j int) bool {
+		for _, e := range sortDoc {
+			direction, _ := toFloat(e.Value)
+			a := sortKey(docList[i], e.Key, direction < 0)
+			b := sortKey(docList[j], e.Key, direction < 0)
+			c, _ := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			if direction < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// get the sort key of document,for arrays mongodb uses the min element when ascending and the max when descending
+func sortKey(doc bson.D, path string, desc bool) interface{} {
+	candidates := make([]interface{}, 0)
+	for _, eachValue := range resolvePath(doc, splitPath(path)) {
+		if array, ok := eachValue.(bson.A); ok {
+			candidates = append(candidates, array...)
+			continue
+		}
+		candidates = append(candidates, eachValue)
+	}
+	if len(candidates) <= 0 {
+		return nil
+	}
+	result := candidates[0]
+	for _, eachCandidate := range candidates[1:] {
+		c, _ := compareValues(eachCandidate, result)
+		if (desc && c > 0) || (!desc && c < 0) {
+			result = eachCandidate
+		}
+	}
+	return result
+}
+
+// apply inclusion or exclusion projection, projection operators are not supported
+func project(doc bson.D, projection bson.D) (bson.D, error) {
+	includeId := true
+	inclusion := false
+	for _, e := range projection {
+		if _, ok := e.Value.(bson.D); ok {
+			return nil, fmt.Errorf("%w: projection operator on field %s", ErrUnsupported, e.Key)
+		}
+		if e.Key == "_id" {
+			includeId = isTruthy(e.Value)
+			continue
+		}
+		if isTruthy(e.Value) {
+			inclusion = true
+		}
+	}
+	if !inclusion {
+		var result interface{} = doc
+		for _, e := range projection {
+			if !isTruthy(e.Value) {
+				result = unsetPath(result, splitPath(e.Key))
+			}
+		}
+		return result.(bson.D), nil
+	}
+	var result interface{} = bson.D{}
+	if includeId {
+		if id, ok := lookupExact(doc, []string{"_id"}); ok {
+			result, _ = setPath(result, []string{"_id"}, id)
+		}
+	}
+	for _, e := range projection {
+		if e.Key == "_id" || !isTruthy(e.Value) {
+			continue
+		}
+		parts := splitPath(e.Key)
+		if v, ok := lookupExact(doc, parts); ok {
+			result, _ = setPath(result, parts, v)
+		}
+	}
+	return result.(bson.D), nil
+}
+
+func newCursor(docList []bson.D) (*mongo.Cursor, error) {
+	documents := make([]interface{}, 0, len(docList))
+	for _, eachDoc := range docList {
+		documents = append(documents, eachDoc)
+	}
+	return mongo.NewCursorFromDocuments(documents, nil, nil)
+}
+
+// decode documents into list,list must be a pointer to slice
+func decodeList(docList []bson.D, list interface{}) error {
+	cur, err := newCursor(docList)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	defer cur.Close(ctx)
+
+	return cur.All(ctx, list)
+}
+
+func duplicateKeyError(indexName string, value interface{}) error {
+	return mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{
+			{
+				Code:    11000,
+				Message: fmt.Sprintf("E11000 duplicate key error index: %s dup key: %v", indexName, value),
+			},
+		},
+	}
+}
+
+func writeErrorCode(err error) int {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) && len(writeException.WriteErrors) > 0 {
+		return writeException.WriteErrors[0].Code
+	}
+	return 0
+}
+
+func toBulkWriteErrors(models []mongo.WriteModel, writeErrors mongo.WriteErrors) []mongo.BulkWriteError {
+	result := make([]mongo.BulkWriteError, 0, len(writeErrors))
+	for _, eachError := range writeErrors {
+		result = append(result, mongo.BulkWriteError{
+			WriteError: eachError,
+			Request:    models[eachError.Index],
+		})
+	}
+	return result
+}
+
+func isTrue(v *bool) bool {
+	return v != nil && *v
+}
+
+// #endregion
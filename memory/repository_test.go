@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/abmpio/mongodbr"
+)
+
+type versionedItem struct {
+	mongodbr.Entity          `bson:",inline"`
+	mongodbr.VersionedEntity `bson:",inline"`
+	Name                     string `bson:"name"`
+}
+
+type plainItem struct {
+	mongodbr.Entity `bson:",inline"`
+	Name            string `bson:"name"`
+}
+
+func TestBulkWriteEntityList(t *testing.T) {
+	cases := []struct {
+		name           string
+		stored         int64
+		entityList     func(versioned *versionedItem) []mongodbr.IEntity
+		wantErr        error
+		wantVersion    int64
+		wantStored     int64
+		wantAfterCount int
+	}{
+		{
+			name:   "missing item which is not versioned is not a conflict",
+			stored: 0,
+			entityList: func(versioned *versionedItem) []mongodbr.IEntity {
+				return []mongodbr.IEntity{versioned, &plainItem{Entity: mongodbr.Entity{ObjectId: bson.NewObjectID()}, Name: "x"}}
+			},
+			wantVersion:    1,
+			wantStored:     1,
+			wantAfterCount: 2,
+		},
+		{
+			name:   "versioned item which is not matched is a conflict",
+			stored: 3,
+			entityList: func(versioned *versionedItem) []mongodbr.IEntity {
+				return []mongodbr.IEntity{versioned}
+			},
+			wantErr:     mongodbr.ErrConcurrencyConflict,
+			wantVersion: 0,
+			wantStored:  3,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			afterCount := 0
+			r := NewRepository("items", WithHooks(&mongodbr.RepositoryHooks{
+				AfterUpdate: func(ctx context.Context, filter interface{}, update interface{}) error {
+					afterCount++
+					return nil
+				},
+			}))
+			id := bson.NewObjectID()
+			stored := &versionedItem{Entity: mongodbr.Entity{ObjectId: id}, Name: "a"}
+			stored.Version = c.stored
+			if _, err := r.Create(stored); err != nil {
+				t.Fatal(err)
+			}
+			versioned := &versionedItem{Entity: mongodbr.Entity{ObjectId: id}, Name: "b"}
+			_, err := r.BulkWriteEntityList(c.entityList(versioned))
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("err = %v, want %v", err, c.wantErr)
+			}
+			if versioned.Version != c.wantVersion {
+				t.Errorf("version of entity = %d, want %d", versioned.Version, c.wantVersion)
+			}
+			found := &versionedItem{}
+			if err := r.FindOneByObjectId(id, found); err != nil {
+				t.Fatal(err)
+			}
+			if found.Version != c.wantStored {
+				t.Errorf("stored version = %d, want %d", found.Version, c.wantStored)
+			}
+			if afterCount != c.wantAfterCount {
+				t.Errorf("AfterUpdate count = %d, want %d", afterCount, c.wantAfterCount)
+			}
+		})
+	}
+}
+
+func TestReplaceVersionedWithUpsert(t *testing.T) {
+	r := NewRepository("items")
+	id := bson.NewObjectID()
+	stored := &versionedItem{Entity: mongodbr.Entity{ObjectId: id}, Name: "a"}
+	stored.Version = 2
+	if _, err := r.Create(stored); err != nil {
+		t.Fatal(err)
+	}
+	upsert := true
+	item := &versionedItem{Entity: mongodbr.Entity{ObjectId: id}, Name: "b"}
+	item.Version = 1
+	err := r.ReplaceById(id, item, func(o *mongodbr.MongodbrReplaceOptions) {
+		o.Upsert = &upsert
+	})
+	if !errors.Is(err, mongodbr.ErrConcurrencyConflict) {
+		t.Fatalf("err = %v, want ErrConcurrencyConflict", err)
+	}
+	if item.Version != 1 {
+		t.Errorf("version = %d, want 1", item.Version)
+	}
+}
@@ -0,0 +1,401 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/abmpio/mongodbr/builder"
+)
+
+// check if update is a replacement document(no update operators)
+func isReplacement(update bson.D) bool {
+	return len(update) <= 0 || !strings.HasPrefix(update[0].Key, "$")
+}
+
+// apply update operators to doc and return the updated document,
+// isInsert indicates the update is applied to an upserted document
+func applyUpdate(doc bson.D, update bson.D, isInsert bool) (bson.D, error) {
+	var current interface{} = cloneD(doc)
+	for _, eachOp := range update {
+		fields, ok := eachOp.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("modifier %s expects a document", eachOp.Key)
+		}
+		for _, eachField := range fields {
+			if strings.Contains(eachField.Key, "$") {
+				return nil, fmt.Errorf("%w: positional path %s", ErrUnsupported, eachField.Key)
+			}
+			if eachField.Key == "_id" && eachOp.Key != "$setOnInsert" && !isInsert {
+				if existing, ok := lookupExact(current, []string{"_id"}); ok && valuesEqual(existing, eachField.Value) {
+					continue
+				}
+				if eachOp.Key == "$set" {
+					return nil, fmt.Errorf("performing an update on the path '_id' would modify the immutable field '_id'")
+				}
+			}
+			var err error
+			current, err = applyFieldUpdate(current, eachOp.Key, splitPath(eachField.Key), eachField.Value, isInsert)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return current.(bson.D), nil
+}
+
+func applyFieldUpdate(doc interface{}, op string, parts []string, value interface{}, isInsert bool) (interface{}, error) {
+	switch op {
+	case builder.Op_Set().String():
+		return setPath(doc, parts, cloneValue(value))
+	case "$setOnInsert":
+		if !isInsert {
+			return doc, nil
+		}
+		return setPath(doc, parts, cloneValue(value))
+	case "$unset":
+		return unsetPath(doc, parts), nil
+	case "$inc":
+		existing, ok := lookupExact(doc, parts)
+		if !ok || existing == nil {
+			return setPath(doc, parts, value)
+		}
+		sum, err := addNumbers(existing, value)
+		if err != nil {
+			return nil, err
+		}
+		return setPath(doc, parts, sum)
+	case builder.Op_Mul().String():
+		existing, ok := lookupExact(doc, parts)
+		if !ok || existing == nil {
+			// like mongodb, $mul a missing field sets it to zero of the same type
+			zero, err := mulNumbers(int32(0), value)
+			if err != nil {
+				return nil, err
+			}
+			return setPath(doc, parts, zero)
+		}
+		product, err := mulNumbers(existing, value)
+		if err != nil {
+			return nil, err
+		}
+		return setPath(doc, parts, product)
+	case builder.Op_Min().String(), builder.Op_Max().String():
+		existing, ok := lookupExact(doc, parts)
+		if ok {
+			c, _ := compareValues(value, existing)
+			if (op == builder.Op_Min().String() && c >= 0) || (op == builder.Op_Max().String() && c <= 0) {
+				return doc, nil
+			}
+		}
+		return setPath(doc, parts, cloneValue(value))
+	case builder.Op_Rename().String():
+		newName, ok := value.(string)
+		if !ok || len(newName) <= 0 {
+			return nil, fmt.Errorf("the 'to' field for $rename must be a string")
+		}
+		existing, ok := lookupExact(doc, parts)
+		if !ok {
+			return doc, nil
+		}
+		doc = unsetPath(doc, parts)
+		return setPath(doc, splitPath(newName), existing)
+	case builder.Op_CurrentDate().String():
+		now := time.Now()
+		if typeSpec, ok := value.(bson.D); ok {
+			for _, e := range typeSpec {
+				if e.Key == "$type" && e.Value == "timestamp" {
+					return setPath(doc, parts, bson.Timestamp{T: uint32(now.Unix())})
+				}
+			}
+		}
+		return setPath(doc, parts, bson.NewDateTimeFromTime(now))
+	case builder.Op_Push().String(), builder.Op_AddToSet().String():
+		array, err := arrayAt(doc, parts)
+		if err != nil {
+			return nil, err
+		}
+		array, err = pushValues(array, op, value)
+		if err != nil {
+			return nil, err
+		}
+		return setPath(doc, parts, array)
+	case builder.Op_Pull().String(), builder.Op_PullAll().String():
+		array, err := arrayAt(doc, parts)
+		if err != nil {
+			return nil, err
+		}
+		array, err = pullValues(array, op, value)
+		if err != nil {
+			return nil, err
+		}
+		return setPath(doc, parts, array)
+	case builder.Op_Pop().String():
+		array, err := arrayAt(doc, parts)
+		if err != nil || len(array) <= 0 {
+			return doc, err
+		}
+		if n, _ := toFloat(value); n < 0 {
+			array = array[1:]
+		} else {
+			array = array[:len(array)-1]
+		}
+		return setPath(doc, parts, array)
+	}
+	return nil, fmt.Errorf("%w: update operator %s", ErrUnsupported, op)
+}
+
+// set value at path, intermediate documents are created when missing
+func setPath(current interface{}, parts []string, value interface{}) (interface{}, error) {
+	if len(parts) <= 0 {
+		return value, nil
+	}
+	switch c := current.(type) {
+	case nil:
+		return setPath(bson.D{}, parts, value)
+	case bson.D:
+		for i := range c {
+			if c[i].Key == parts[0] {
+				v, err := setPath(c[i].Value, parts[1:], value)
+				if err != nil {
+					return nil, err
+				}
+				c[i].Value = v
+				return c, nil
+			}
+		}
+		v, err := setPath(nil, parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return append(c, bson.E{Key: parts[0], Value: v}), nil
+	case bson.A:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("cannot create field '%s' in array", parts[0])
+		}
+		for len(c) <= index {
+			c = append(c, nil)
+		}
+		v, err := setPath(c[index], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		c[index] = v
+		return c, nil
+	}
+	return nil, fmt.Errorf("cannot create field '%s' in element of type %T", parts[0], current)
+}
+
+func unsetPath(current interface{}, parts []string) interface{} {
+	if len(parts) <= 0 {
+		return current
+	}
+	switch c := current.(type) {
+	case bson.D:
+		for i := range c {
+			if c[i].Key != parts[0] {
+				continue
+			}
+			if len(parts) == 1 {
+				return append(c[:i:i], c[i+1:]...)
+			}
+			c[i].Value = unsetPath(c[i].Value, parts[1:])
+			return c
+		}
+	case bson.A:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index >= len(c) {
+			return c
+		}
+		if len(parts) == 1 {
+			// like mongodb, $unset an array element sets it to null
+			c[index] = nil
+			return c
+		}
+		c[index] = unsetPath(c[index], parts[1:])
+	}
+	return current
+}
+
+func arrayAt(doc interface{}, parts []string) (bson.A, error) {
+	existing, ok := lookupExact(doc, parts)
+	if !ok || existing == nil {
+		return bson.A{}, nil
+	}
+	array, ok := existing.(bson.A)
+	if !ok {
+		return nil, fmt.Errorf("the field '%s' must be an array but is of type %T", strings.Join(parts, "."), existing)
+	}
+	return cloneValue(array).(bson.A), nil
+}
+
+func pushValues(array bson.A, op string, value interface{}) (bson.A, error) {
+	items := bson.A{value}
+	position := -1
+	var slice *int
+	var sortSpec interface{}
+	if modifiers, ok := value.(bson.D); ok && len(modifiers) > 0 && modifiers[0].Key == "$each" {
+		for _, e := range modifiers {
+			switch e.Key {
+			case "$each":
+				each, ok := e.Value.(bson.A)
+				if !ok {
+					return nil, fmt.Errorf("$each requires an array")
+				}
+				items = each
+			case "$position":
+				n, _ := toFloat(e.Value)
+				position = int(n)
+			case "$slice":
+				n, _ := toFloat(e.Value)
+				v := int(n)
+				slice = &v
+			case "$sort":
+				sortSpec = e.Value
+			default:
+				return nil, fmt.Errorf("%w: push modifier %s", ErrUnsupported, e.Key)
+			}
+		}
+	}
+	if op == builder.Op_AddToSet().String() {
+		for _, eachItem := range items {
+			if !matchEq([]interface{}{array}, eachItem) {
+				array = append(array, eachItem)
+			}
+		}
+		return array, nil
+	}
+	if position < 0 || position > len(array) {
+		array = append(array, items...)
+	} else {
+		array = append(array[:position:position], append(items, array[position:]...)...)
+	}
+	if sortSpec != nil {
+		sortArray(array, sortSpec)
+	}
+	if slice != nil {
+		switch {
+		case *slice >= 0 && *slice < len(array):
+			array = array[:*slice]
+		case *slice < 0 && -*slice < len(array):
+			array = array[len(array)+*slice:]
+		}
+	}
+	return array, nil
+}
+
+func pullValues(array bson.A, op string, value interface{}) (bson.A, error) {
+	result := make(bson.A, 0, len(array))
+	for _, eachItem := range array {
+		var matched bool
+		var err error
+		if op == builder.Op_PullAll().String() {
+			list, ok := value.(bson.A)
+			if !ok {
+				return nil, fmt.Errorf("$pullAll requires an array argument")
+			}
+			matched, err = matchIn([]interface{}{eachItem}, list)
+		} else if condition, ok := value.(bson.D); ok {
+			itemDoc, isDoc := eachItem.(bson.D)
+			if !isOperatorDocument(condition) && isDoc {
+				matched, err = matchDocument(itemDoc, condition)
+			} else {
+				matched, err = matchValues([]interface{}{eachItem}, condition)
+			}
+		} else {
+			matched = valuesEqual(eachItem, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			result = append(result, eachItem)
+		}
+	}
+	return result, nil
+}
+
+func addNumbers(a interface{}, b interface{}) (interface{}, error) {
+	if _, ok := toFloat(b); !ok {
+		return nil, fmt.Errorf("cannot increment with non-numeric argument")
+	}
+	switch av := a.(type) {
+	case int32:
+		if bv, ok := b.(int32); ok {
+			return av + bv, nil
+		}
+		if bv, ok := b.(int64); ok {
+			return int64(av) + bv, nil
+		}
+	case int64:
+		if bv, ok := b.(int32); ok {
+			return av + int64(bv), nil
+		}
+		if bv, ok := b.(int64); ok {
+			return av + bv, nil
+		}
+	}
+	af, ok := toFloat(a)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply $inc to a value of non-numeric type %T", a)
+	}
+	bf, _ := toFloat(b)
+	return af + bf, nil
+}
+
+// sort array by $sort modifier of $push, spec is 1,-1 or a sort document
+func sortArray(array bson.A, spec interface{}) {
+	if sortDoc, ok := spec.(bson.D); ok {
+		docList := make([]bson.D, 0, len(array))
+		for _, eachItem := range array {
+			itemDoc, _ := eachItem.(bson.D)
+			docList = append(docList, itemDoc)
+		}
+		sortDocuments(docList, sortDoc)
+		for i := range docList {
+			array[i] = docList[i]
+		}
+		return
+	}
+	direction, _ := toFloat(spec)
+	sort.SliceStable(array, func(i, j int) bool {
+		c, _ := compareValues(array[i], array[j])
+		if direction < 0 {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+func mulNumbers(a interface{}, b interface{}) (interface{}, error) {
+	if _, ok := toFloat(b); !ok {
+		return nil, fmt.Errorf("cannot multiply with non-numeric argument")
+	}
+	switch av := a.(type) {
+	case int32:
+		if bv, ok := b.(int32); ok {
+			return av * bv, nil
+		}
+		if bv, ok := b.(int64); ok {
+			return int64(av) * bv, nil
+		}
+	case int64:
+		if bv, ok := b.(int32); ok {
+			return av * int64(bv), nil
+		}
+		if bv, ok := b.(int64); ok {
+			return av * bv, nil
+		}
+	}
+	af, ok := toFloat(a)
+	if !ok {
+		return nil, fmt.Errorf("cannot apply $mul to a value of non-numeric type %T", a)
+	}
+	bf, _ := toFloat(b)
+	return af * bf, nil
+}
@@ -0,0 +1,238 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	doc := bson.D{
+		{Key: "_id", Value: int32(1)},
+		{Key: "name", Value: "a"},
+		{Key: "count", Value: int32(1)},
+		{Key: "tags", Value: bson.A{"x", "y", "x"}},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Paris"}}},
+		{Key: "items", Value: bson.A{
+			bson.D{{Key: "name", Value: "x"}, {Key: "qty", Value: int32(1)}},
+			bson.D{{Key: "name", Value: "y"}, {Key: "qty", Value: int32(5)}},
+		}},
+	}
+	cases := []struct {
+		name   string
+		update bson.D
+		want   bson.D
+	}{
+		{
+			name:   "$set existing field keeps order",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "name", Value: "b"}}}},
+			want:   bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "b"}},
+		},
+		{
+			name:   "$set new field is appended",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "age", Value: int32(2)}}}},
+			want:   bson.D{{Key: "age", Value: int32(2)}},
+		},
+		{
+			name:   "$set dotted path",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "address.zip", Value: "75001"}}}},
+			want:   bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "Paris"}, {Key: "zip", Value: "75001"}}}},
+		},
+		{
+			name:   "$set creates embedded documents",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "a.b.c", Value: int32(1)}}}},
+			want:   bson.D{{Key: "a", Value: bson.D{{Key: "b", Value: bson.D{{Key: "c", Value: int32(1)}}}}}},
+		},
+		{
+			name:   "$set array index",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "items.1.qty", Value: int32(6)}}}},
+			want: bson.D{{Key: "items", Value: bson.A{
+				bson.D{{Key: "name", Value: "x"}, {Key: "qty", Value: int32(1)}},
+				bson.D{{Key: "name", Value: "y"}, {Key: "qty", Value: int32(6)}},
+			}}},
+		},
+		{
+			name:   "$set same _id",
+			update: bson.D{{Key: "$set", Value: bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "b"}}}},
+			want:   bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "b"}},
+		},
+		{
+			name:   "$unset",
+			update: bson.D{{Key: "$unset", Value: bson.D{{Key: "name", Value: ""}, {Key: "missing", Value: ""}}}},
+			want:   bson.D{{Key: "_id", Value: int32(1)}, {Key: "count", Value: int32(1)}},
+		},
+		{
+			name:   "$unset dotted path",
+			update: bson.D{{Key: "$unset", Value: bson.D{{Key: "address.city", Value: ""}}}},
+			want:   bson.D{{Key: "address", Value: bson.D{}}},
+		},
+		{
+			name:   "$unset array element sets null",
+			update: bson.D{{Key: "$unset", Value: bson.D{{Key: "tags.0", Value: ""}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{nil, "y", "x"}}},
+		},
+		{
+			name:   "$inc",
+			update: bson.D{{Key: "$inc", Value: bson.D{{Key: "count", Value: int32(2)}, {Key: "other", Value: int32(3)}}}},
+			want:   bson.D{{Key: "count", Value: int32(3)}, {Key: "other", Value: int32(3)}},
+		},
+		{
+			name:   "$push",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: "z"}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{"x", "y", "x", "z"}}},
+		},
+		{
+			name:   "$push to missing field",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "list", Value: "z"}}}},
+			want:   bson.D{{Key: "list", Value: bson.A{"z"}}},
+		},
+		{
+			name:   "$push array as one element",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.A{"z"}}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{"x", "y", "x", bson.A{"z"}}}},
+		},
+		{
+			name: "$push $each $position",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.D{
+				{Key: "$each", Value: bson.A{"a", "b"}},
+				{Key: "$position", Value: int32(1)},
+			}}}}},
+			want: bson.D{{Key: "tags", Value: bson.A{"x", "a", "b", "y", "x"}}},
+		},
+		{
+			name: "$push $each $sort $slice",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.D{
+				{Key: "$each", Value: bson.A{"w"}},
+				{Key: "$sort", Value: int32(-1)},
+				{Key: "$slice", Value: int32(3)},
+			}}}}},
+			want: bson.D{{Key: "tags", Value: bson.A{"y", "x", "x"}}},
+		},
+		{
+			name: "$push $each negative $slice",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.D{
+				{Key: "$each", Value: bson.A{"z"}},
+				{Key: "$slice", Value: int32(-2)},
+			}}}}},
+			want: bson.D{{Key: "tags", Value: bson.A{"x", "z"}}},
+		},
+		{
+			name: "$push $each $sort by field",
+			update: bson.D{{Key: "$push", Value: bson.D{{Key: "items", Value: bson.D{
+				{Key: "$each", Value: bson.A{bson.D{{Key: "name", Value: "z"}, {Key: "qty", Value: int32(3)}}}},
+				{Key: "$sort", Value: bson.D{{Key: "qty", Value: int32(-1)}}},
+			}}}}},
+			want: bson.D{{Key: "items", Value: bson.A{
+				bson.D{{Key: "name", Value: "y"}, {Key: "qty", Value: int32(5)}},
+				bson.D{{Key: "name", Value: "z"}, {Key: "qty", Value: int32(3)}},
+				bson.D{{Key: "name", Value: "x"}, {Key: "qty", Value: int32(1)}},
+			}}},
+		},
+		{
+			name:   "$addToSet",
+			update: bson.D{{Key: "$addToSet", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$each", Value: bson.A{"x", "z"}}}}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{"x", "y", "x", "z"}}},
+		},
+		{
+			name:   "$pull value",
+			update: bson.D{{Key: "$pull", Value: bson.D{{Key: "tags", Value: "x"}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{"y"}}},
+		},
+		{
+			name:   "$pull condition",
+			update: bson.D{{Key: "$pull", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$in", Value: bson.A{"y", "z"}}}}}}},
+			want:   bson.D{{Key: "tags", Value: bson.A{"x", "x"}}},
+		},
+		{
+			name:   "$pull documents",
+			update: bson.D{{Key: "$pull", Value: bson.D{{Key: "items", Value: bson.D{{Key: "qty", Value: bson.D{{Key: "$gt", Value: int32(2)}}}}}}}},
+			want: bson.D{{Key: "items", Value: bson.A{
+				bson.D{{Key: "name", Value: "x"}, {Key: "qty", Value: int32(1)}},
+			}}},
+		},
+		{
+			name:   "$pull missing field",
+			update: bson.D{{Key: "$pull", Value: bson.D{{Key: "list", Value: "x"}}}},
+			want:   bson.D{{Key: "list", Value: bson.A{}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			original := cloneD(doc)
+			got, err := applyUpdate(doc, c.update, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the fields of want are checked
+			for _, eachField := range c.want {
+				value, ok := lookupExact(got, []string{eachField.Key})
+				if !ok || !reflect.DeepEqual(value, eachField.Value) {
+					t.Errorf("%s = %v, want %v", eachField.Key, value, eachField.Value)
+				}
+			}
+			if !reflect.DeepEqual(doc, original) {
+				t.Errorf("document is modified: %v", doc)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateOrder(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: int32(1)}, {Key: "a", Value: int32(1)}, {Key: "b", Value: int32(2)}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "a", Value: int32(3)}, {Key: "c", Value: int32(4)}}},
+		{Key: "$unset", Value: bson.D{{Key: "b", Value: ""}}},
+	}
+	want := bson.D{{Key: "_id", Value: int32(1)}, {Key: "a", Value: int32(3)}, {Key: "c", Value: int32(4)}}
+	got, err := applyUpdate(doc, update, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyUpdate() = %v, want %v", got, want)
+	}
+}
+
+func TestApplyUpdateError(t *testing.T) {
+	doc := bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}}
+	cases := []struct {
+		name   string
+		update bson.D
+	}{
+		{name: "modifier is not a document", update: bson.D{{Key: "$set", Value: int32(1)}}},
+		{name: "change _id", update: bson.D{{Key: "$set", Value: bson.D{{Key: "_id", Value: int32(2)}}}}},
+		{name: "positional path", update: bson.D{{Key: "$set", Value: bson.D{{Key: "items.$.qty", Value: int32(1)}}}}},
+		{name: "$push to non array", update: bson.D{{Key: "$push", Value: bson.D{{Key: "name", Value: "x"}}}}},
+		{name: "$pull from non array", update: bson.D{{Key: "$pull", Value: bson.D{{Key: "name", Value: "x"}}}}},
+		{name: "$inc non number", update: bson.D{{Key: "$inc", Value: bson.D{{Key: "name", Value: int32(1)}}}}},
+		{name: "unsupported operator", update: bson.D{{Key: "$bit", Value: bson.D{{Key: "name", Value: int32(1)}}}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := applyUpdate(doc, c.update, false); err == nil {
+				t.Errorf("applyUpdate(%v) error = nil, want error", c.update)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateSetOnInsert(t *testing.T) {
+	update := bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "created", Value: true}}}}
+	doc := bson.D{{Key: "_id", Value: int32(1)}}
+	got, err := applyUpdate(doc, update, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Errorf("applyUpdate() = %v, want %v", got, doc)
+	}
+	got, err = applyUpdate(doc, update, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{{Key: "_id", Value: int32(1)}, {Key: "created", Value: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyUpdate() on insert = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,514 @@
+package mongodbr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// default upper bounds of latency histograms
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type MetricsCollectorOptions struct {
+	// upper bounds of latency histograms, DefaultLatencyBuckets if empty
+	Buckets []time.Duration
+	// prefix of prometheus metric names, "mongodbr" if empty
+	Namespace string
+}
+
+type MetricsCollectorOption func(*MetricsCollectorOptions)
+
+// merge MetricsCollectorOption list and return one *MetricsCollectorOptions
+func MergeMetricsCollectorOption(opts ...MetricsCollectorOption) *MetricsCollectorOptions {
+	o := &MetricsCollectorOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if len(o.Buckets) <= 0 {
+		o.Buckets = DefaultLatencyBuckets
+	}
+	o.Buckets = append([]time.Duration(nil), o.Buckets...)
+	sort.Slice(o.Buckets, func(i, j int) bool {
+		return o.Buckets[i] < o.Buckets[j]
+	})
+	if len(o.Namespace) <= 0 {
+		o.Namespace = "mongodbr"
+	}
+	return o
+}
+
+// MetricsCollectorOption with upper bounds of latency histograms
+func MetricsCollectorOptionWithBuckets(buckets ...time.Duration) MetricsCollectorOption {
+	return func(o *MetricsCollectorOptions) {
+		o.Buckets = buckets
+	}
+}
+
+// MetricsCollectorOption with prefix of prometheus metric names
+func MetricsCollectorOptionWithNamespace(namespace string) MetricsCollectorOption {
+	return func(o *MetricsCollectorOptions) {
+		o.Namespace = namespace
+	}
+}
+
+// collect command and connection pool metrics from the events of driver,
+// wire it with EnableMetrics when creating client
+type MetricsCollector struct {
+	options *MetricsCollectorOptions
+
+	lock       sync.Mutex
+	operations map[operationKey]*operationStats
+	pools      map[string]*poolStats
+	inflight   sync.Map
+}
+
+type operationKey struct {
+	database   string
+	collection string
+	operation  string
+}
+
+type operationStats struct {
+	count    int64
+	errors   int64
+	duration *histogram
+}
+
+type poolStats struct {
+	maxSize            uint64
+	connectionsCreated int64
+	connectionsClosed  int64
+	checkOutStarted    int64
+	checkedOut         int64
+	checkOutFailed     int64
+	checkedIn          int64
+	cleared            int64
+	wait               *histogram
+}
+
+// collector used by clients whose monitoring metrics is enabled in ClientConfig
+var DefaultMetricsCollector = NewMetricsCollector()
+
+// new MetricsCollector
+func NewMetricsCollector(opts ...MetricsCollectorOption) *MetricsCollector {
+	return &MetricsCollector{
+		options:    MergeMetricsCollectorOption(opts...),
+		operations: make(map[operationKey]*operationStats),
+		pools:      make(map[string]*poolStats),
+	}
+}
+
+// collect metrics of client with collector,
+// the monitors are combined with the existing monitors of client options
+func EnableMetrics(collector *MetricsCollector) func(*options.ClientOptions) {
+	return func(co *options.ClientOptions) {
+		AppendCommandMonitor(co, collector.CommandMonitor())
+		AppendPoolMonitor(co, collector.PoolMonitor())
+	}
+}
+
+// combine monitor with the existing pool monitor of client options
+func AppendPoolMonitor(co *options.ClientOptions, monitor *event.PoolMonitor) {
+	existing := co.PoolMonitor
+	if existing == nil || existing.Event == nil {
+		co.SetPoolMonitor(monitor)
+		return
+	}
+	co.SetPoolMonitor(&event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			existing.Event(e)
+			if monitor != nil && monitor.Event != nil {
+				monitor.Event(e)
+			}
+		},
+	})
+}
+
+// CommandMonitor which feeds collector
+func (m *MetricsCollector) CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			m.inflight.Store(commandKey{connectionId: e.ConnectionID, requestId: e.RequestID}, commandCollection(e.Command))
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.commandFinished(&e.CommandFinishedEvent, false)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			m.commandFinished(&e.CommandFinishedEvent, true)
+		},
+	}
+}
+
+// PoolMonitor which feeds collector
+func (m *MetricsCollector) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: m.poolEvent,
+	}
+}
+
+// clear all collected metrics
+func (m *MetricsCollector) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.operations = make(map[operationKey]*operationStats)
+	m.pools = make(map[string]*poolStats)
+}
+
+func (m *MetricsCollector) commandFinished(e *event.CommandFinishedEvent, failed bool) {
+	collection := ""
+	if value, ok := m.inflight.LoadAndDelete(commandKey{connectionId: e.ConnectionID, requestId: e.RequestID}); ok {
+		collection = value.(string)
+	}
+	key := operationKey{
+		database:   e.DatabaseName,
+		collection: collection,
+		operation:  e.CommandName,
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats, ok := m.operations[key]
+	if !ok {
+		stats = &operationStats{
+			duration: newHistogram(len(m.options.Buckets)),
+		}
+		m.operations[key] = stats
+	}
+	stats.count++
+	if failed {
+		stats.errors++
+	}
+	stats.duration.observe(m.options.Buckets, e.Duration)
+}
+
+func (m *MetricsCollector) poolEvent(e *event.PoolEvent) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	stats, ok := m.pools[e.Address]
+	if !ok {
+		stats = &poolStats{
+			wait: newHistogram(len(m.options.Buckets)),
+		}
+		m.pools[e.Address] = stats
+	}
+	switch e.Type {
+	case event.ConnectionPoolCreated:
+		if e.PoolOptions != nil {
+			stats.maxSize = e.PoolOptions.MaxPoolSize
+		}
+	case event.ConnectionPoolCleared:
+		stats.cleared++
+	case event.ConnectionCreated:
+		stats.connectionsCreated++
+	case event.ConnectionClosed:
+		stats.connectionsClosed++
+	case event.ConnectionCheckOutStarted:
+		stats.checkOutStarted++
+	case event.ConnectionCheckedOut:
+		stats.checkedOut++
+		stats.wait.observe(m.options.Buckets, e.Duration)
+	case event.ConnectionCheckOutFailed:
+		stats.checkOutFailed++
+		stats.wait.observe(m.options.Buckets, e.Duration)
+	case event.ConnectionCheckedIn:
+		stats.checkedIn++
+	}
+}
+
+// #region snapshot
+
+// point in time copy of collected metrics
+type MetricsSnapshot struct {
+	Namespace  string
+	Operations []*OperationMetrics
+	Pools      []*PoolMetrics
+}
+
+// metrics of one operation on one collection
+type OperationMetrics struct {
+	Database   string
+	Collection string
+	// command name, such as find, insert, update
+	Operation string
+	Count     int64
+	Errors    int64
+	Duration  *Histogram
+}
+
+// metrics of the connection pool of one server
+type PoolMetrics struct {
+	Address            string
+	MaxSize            uint64
+	ConnectionsCreated int64
+	ConnectionsClosed  int64
+	// opened and not closed connections
+	ConnectionsOpen int64
+	// checked out and not checked in connections
+	ConnectionsInUse int64
+	CheckOutStarted  int64
+	CheckedOut       int64
+	CheckOutFailed   int64
+	CheckedIn        int64
+	// check outs which are waiting for a connection
+	CheckOutWaiting int64
+	Cleared         int64
+	// time spent to check out connections
+	Wait *Histogram
+}
+
+type Histogram struct {
+	// cumulative count of observations less than or equal to the upper bound
+	Buckets []HistogramBucket
+	Count   int64
+	Sum     time.Duration
+}
+
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      int64
+}
+
+// take a snapshot of collected metrics, sorted by database, collection, operation and address
+func (m *MetricsCollector) Snapshot() *MetricsSnapshot {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	snapshot := &MetricsSnapshot{
+		Namespace:  m.options.Namespace,
+		Operations: make([]*OperationMetrics, 0, len(m.operations)),
+		Pools:      make([]*PoolMetrics, 0, len(m.pools)),
+	}
+	for key, stats := range m.operations {
+		snapshot.Operations = append(snapshot.Operations, &OperationMetrics{
+			Database:   key.database,
+			Collection: key.collection,
+			Operation:  key.operation,
+			Count:      stats.count,
+			Errors:     stats.errors,
+			Duration:   stats.duration.snapshot(m.options.Buckets),
+		})
+	}
+	sort.Slice(snapshot.Operations, func(i, j int) bool {
+		a, b := snapshot.Operations[i], snapshot.Operations[j]
+		if a.Database != b.Database {
+			return a.Database < b.Database
+		}
+		if a.Collection != b.Collection {
+			return a.Collection < b.Collection
+		}
+		return a.Operation < b.Operation
+	})
+	for address, stats := range m.pools {
+		snapshot.Pools = append(snapshot.Pools, &PoolMetrics{
+			Address:            address,
+			MaxSize:            stats.maxSize,
+			ConnectionsCreated: stats.connectionsCreated,
+			ConnectionsClosed:  stats.connectionsClosed,
+			ConnectionsOpen:    stats.connectionsCreated - stats.connectionsClosed,
+			ConnectionsInUse:   stats.checkedOut - stats.checkedIn,
+			CheckOutStarted:    stats.checkOutStarted,
+			CheckedOut:         stats.checkedOut,
+			CheckOutFailed:     stats.checkOutFailed,
+			CheckedIn:          stats.checkedIn,
+			CheckOutWaiting:    stats.checkOutStarted - stats.checkedOut - stats.checkOutFailed,
+			Cleared:            stats.cleared,
+			Wait:               stats.wait.snapshot(m.options.Buckets),
+		})
+	}
+	sort.Slice(snapshot.Pools, func(i, j int) bool {
+		return snapshot.Pools[i].Address < snapshot.Pools[j].Address
+	})
+	return snapshot
+}
+
+// #endregion
+
+// #region histogram
+
+type histogram struct {
+	// counts[i] is the count of observations in bucket i, the last one is +Inf
+	counts []int64
+	count  int64
+	sum    time.Duration
+}
+
+func newHistogram(bucketCount int) *histogram {
+	return &histogram{
+		counts: make([]int64, bucketCount+1),
+	}
+}
+
+func (h *histogram) observe(buckets []time.Duration, d time.Duration) {
+	index := sort.Search(len(buckets), func(i int) bool {
+		return d <= buckets[i]
+	})
+	h.counts[index]++
+	h.count++
+	h.sum += d
+}
+
+func (h *histogram) snapshot(buckets []time.Duration) *Histogram {
+	result := &Histogram{
+		Buckets: make([]HistogramBucket, 0, len(buckets)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+	var cumulative int64
+	for index, eachBound := range buckets {
+		cumulative += h.counts[index]
+		result.Buckets = append(result.Buckets, HistogramBucket{UpperBound: eachBound, Count: cumulative})
+	}
+	return result
+}
+
+// #endregion
+
+// #region prometheus
+
+// write metrics in prometheus text exposition format
+func (m *MetricsCollector) WritePrometheus(w io.Writer) error {
+	return m.Snapshot().WritePrometheus(w)
+}
+
+// write snapshot in prometheus text exposition format
+func (s *MetricsSnapshot) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	p := &prometheusWriter{w: bw, namespace: s.Namespace}
+
+	p.header("command_total", "counter", "Total number of mongodb commands.")
+	for _, each := range s.Operations {
+		p.sample("command_total", each.labels(), float64(each.Count))
+	}
+	p.header("command_errors_total", "counter", "Total number of failed mongodb commands.")
+	for _, each := range s.Operations {
+		p.sample("command_errors_total", each.labels(), float64(each.Errors))
+	}
+	p.header("command_duration_seconds", "histogram", "Duration of mongodb commands in seconds.")
+	for _, each := range s.Operations {
+		p.histogram("command_duration_seconds", each.labels(), each.Duration)
+	}
+
+	poolGauges := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(*PoolMetrics) float64
+	}{
+		{"pool_max_size", "gauge", "Max size of connection pool.", func(p *PoolMetrics) float64 { return float64(p.MaxSize) }},
+		{"pool_connections_created_total", "counter", "Total number of created connections.", func(p *PoolMetrics) float64 { return float64(p.ConnectionsCreated) }},
+		{"pool_connections_closed_total", "counter", "Total number of closed connections.", func(p *PoolMetrics) float64 { return float64(p.ConnectionsClosed) }},
+		{"pool_connections_open", "gauge", "Number of open connections.", func(p *PoolMetrics) float64 { return float64(p.ConnectionsOpen) }},
+		{"pool_connections_in_use", "gauge", "Number of checked out connections.", func(p *PoolMetrics) float64 { return float64(p.ConnectionsInUse) }},
+		{"pool_checkouts_total", "counter", "Total number of connection check outs.", func(p *PoolMetrics) float64 { return float64(p.CheckedOut) }},
+		{"pool_checkout_failures_total", "counter", "Total number of failed connection check outs.", func(p *PoolMetrics) float64 { return float64(p.CheckOutFailed) }},
+		{"pool_checkout_waiting", "gauge", "Number of check outs waiting for a connection.", func(p *PoolMetrics) float64 { return float64(p.CheckOutWaiting) }},
+		{"pool_cleared_total", "counter", "Total number of connection pool clears.", func(p *PoolMetrics) float64 { return float64(p.Cleared) }},
+	}
+	for _, eachGauge := range poolGauges {
+		p.header(eachGauge.name, eachGauge.kind, eachGauge.help)
+		for _, each := range s.Pools {
+			p.sample(eachGauge.name, [][2]string{{"address", each.Address}}, eachGauge.value(each))
+		}
+	}
+	p.header("pool_checkout_wait_seconds", "histogram", "Time spent to check out connections in seconds.")
+	for _, each := range s.Pools {
+		p.histogram("pool_checkout_wait_seconds", [][2]string{{"address", each.Address}}, each.Wait)
+	}
+
+	if p.err != nil {
+		return p.err
+	}
+	return bw.Flush()
+}
+
+func (o *OperationMetrics) labels() [][2]string {
+	return [][2]string{
+		{"database", o.Database},
+		{"collection", o.Collection},
+		{"operation", o.Operation},
+	}
+}
+
+type prometheusWriter struct {
+	w         *bufio.Writer
+	namespace string
+	err       error
+}
+
+func (p *prometheusWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+func (p *prometheusWriter) header(name string, kind string, help string) {
+	p.printf("# HELP %s_%s %s\n", p.namespace, name, help)
+	p.printf("# TYPE %s_%s %s\n", p.namespace, name, kind)
+}
+
+func (p *prometheusWriter) sample(name string, labels [][2]string, value float64) {
+	p.printf("%s_%s%s %s\n", p.namespace, name, formatLabels(labels), formatFloat(value))
+}
+
+func (p *prometheusWriter) histogram(name string, labels [][2]string, h *Histogram) {
+	for _, eachBucket := range h.Buckets {
+		bucketLabels := append(append([][2]string(nil), labels...), [2]string{"le", formatFloat(eachBucket.UpperBound.Seconds())})
+		p.sample(name+"_bucket", bucketLabels, float64(eachBucket.Count))
+	}
+	p.sample(name+"_bucket", append(append([][2]string(nil), labels...), [2]string{"le", "+Inf"}), float64(h.Count))
+	p.sample(name+"_sum", labels, h.Sum.Seconds())
+	p.sample(name+"_count", labels, float64(h.Count))
+}
+
+var _labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels [][2]string) string {
+	if len(labels) <= 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for index, eachLabel := range labels {
+		if index > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(eachLabel[0])
+		sb.WriteString(`="`)
+		sb.WriteString(_labelValueReplacer.Replace(eachLabel[1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// #endregion
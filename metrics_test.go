@@ -0,0 +1,142 @@
+package mongodbr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/event"
+)
+
+const _prometheusGolden = `
+# HELP app_command_total Total number of mongodb commands.
+# TYPE app_command_total counter
+app_command_total{database="db",collection="a\"b\\c",operation="insert"} 1
+app_command_total{database="db",collection="users",operation="find"} 2
+# HELP app_command_errors_total Total number of failed mongodb commands.
+# TYPE app_command_errors_total counter
+app_command_errors_total{database="db",collection="a\"b\\c",operation="insert"} 0
+app_command_errors_total{database="db",collection="users",operation="find"} 1
+# HELP app_command_duration_seconds Duration of mongodb commands in seconds.
+# TYPE app_command_duration_seconds histogram
+app_command_duration_seconds_bucket{database="db",collection="a\"b\\c",operation="insert",le="0.01"} 0
+app_command_duration_seconds_bucket{database="db",collection="a\"b\\c",operation="insert",le="0.1"} 0
+app_command_duration_seconds_bucket{database="db",collection="a\"b\\c",operation="insert",le="+Inf"} 1
+app_command_duration_seconds_sum{database="db",collection="a\"b\\c",operation="insert"} 0.2
+app_command_duration_seconds_count{database="db",collection="a\"b\\c",operation="insert"} 1
+app_command_duration_seconds_bucket{database="db",collection="users",operation="find",le="0.01"} 1
+app_command_duration_seconds_bucket{database="db",collection="users",operation="find",le="0.1"} 2
+app_command_duration_seconds_bucket{database="db",collection="users",operation="find",le="+Inf"} 2
+app_command_duration_seconds_sum{database="db",collection="users",operation="find"} 0.055
+app_command_duration_seconds_count{database="db",collection="users",operation="find"} 2
+# HELP app_pool_max_size Max size of connection pool.
+# TYPE app_pool_max_size gauge
+app_pool_max_size{address="h1:27017"} 10
+# HELP app_pool_connections_created_total Total number of created connections.
+# TYPE app_pool_connections_created_total counter
+app_pool_connections_created_total{address="h1:27017"} 2
+# HELP app_pool_connections_closed_total Total number of closed connections.
+# TYPE app_pool_connections_closed_total counter
+app_pool_connections_closed_total{address="h1:27017"} 1
+# HELP app_pool_connections_open Number of open connections.
+# TYPE app_pool_connections_open gauge
+app_pool_connections_open{address="h1:27017"} 1
+# HELP app_pool_connections_in_use Number of checked out connections.
+# TYPE app_pool_connections_in_use gauge
+app_pool_connections_in_use{address="h1:27017"} 1
+# HELP app_pool_checkouts_total Total number of connection check outs.
+# TYPE app_pool_checkouts_total counter
+app_pool_checkouts_total{address="h1:27017"} 2
+# HELP app_pool_checkout_failures_total Total number of failed connection check outs.
+# TYPE app_pool_checkout_failures_total counter
+app_pool_checkout_failures_total{address="h1:27017"} 1
+# HELP app_pool_checkout_waiting Number of check outs waiting for a connection.
+# TYPE app_pool_checkout_waiting gauge
+app_pool_checkout_waiting{address="h1:27017"} 1
+# HELP app_pool_cleared_total Total number of connection pool clears.
+# TYPE app_pool_cleared_total counter
+app_pool_cleared_total{address="h1:27017"} 1
+# HELP app_pool_checkout_wait_seconds Time spent to check out connections in seconds.
+# TYPE app_pool_checkout_wait_seconds histogram
+app_pool_checkout_wait_seconds_bucket{address="h1:27017",le="0.01"} 1
+app_pool_checkout_wait_seconds_bucket{address="h1:27017",le="0.1"} 2
+app_pool_checkout_wait_seconds_bucket{address="h1:27017",le="+Inf"} 3
+app_pool_checkout_wait_seconds_sum{address="h1:27017"} 0.171
+app_pool_checkout_wait_seconds_count{address="h1:27017"} 3
+`
+
+func TestWritePrometheus(t *testing.T) {
+	collector := NewMetricsCollector(
+		MetricsCollectorOptionWithNamespace("app"),
+		MetricsCollectorOptionWithBuckets(100*time.Millisecond, 10*time.Millisecond),
+	)
+	commandMonitor := collector.CommandMonitor()
+	commandList := []struct {
+		database  string
+		command   bson.D
+		requestId int64
+		duration  time.Duration
+		failed    bool
+	}{
+		{"db", bson.D{{Key: "find", Value: "users"}}, 1, 5 * time.Millisecond, false},
+		{"db", bson.D{{Key: "find", Value: "users"}}, 2, 50 * time.Millisecond, true},
+		{"db", bson.D{{Key: "insert", Value: `a"b\c`}}, 3, 200 * time.Millisecond, false},
+	}
+	ctx := context.Background()
+	for _, each := range commandList {
+		command, err := bson.Marshal(each.command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commandMonitor.Started(ctx, &event.CommandStartedEvent{
+			Command:      command,
+			DatabaseName: each.database,
+			CommandName:  each.command[0].Key,
+			RequestID:    each.requestId,
+			ConnectionID: "c1",
+		})
+		finished := event.CommandFinishedEvent{
+			Duration:     each.duration,
+			DatabaseName: each.database,
+			CommandName:  each.command[0].Key,
+			RequestID:    each.requestId,
+			ConnectionID: "c1",
+		}
+		if each.failed {
+			commandMonitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: finished})
+		} else {
+			commandMonitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: finished})
+		}
+	}
+
+	poolMonitor := collector.PoolMonitor()
+	poolEventList := []*event.PoolEvent{
+		{Type: event.ConnectionPoolCreated, PoolOptions: &event.MonitorPoolOptions{MaxPoolSize: 10}},
+		{Type: event.ConnectionCreated},
+		{Type: event.ConnectionCreated},
+		{Type: event.ConnectionClosed},
+		{Type: event.ConnectionCheckOutStarted},
+		{Type: event.ConnectionCheckOutStarted},
+		{Type: event.ConnectionCheckOutStarted},
+		{Type: event.ConnectionCheckOutStarted},
+		{Type: event.ConnectionCheckedOut, Duration: time.Millisecond},
+		{Type: event.ConnectionCheckedOut, Duration: 20 * time.Millisecond},
+		{Type: event.ConnectionCheckOutFailed, Duration: 150 * time.Millisecond},
+		{Type: event.ConnectionCheckedIn},
+		{Type: event.ConnectionPoolCleared},
+	}
+	for _, each := range poolEventList {
+		each.Address = "h1:27017"
+		poolMonitor.Event(each)
+	}
+
+	var sb strings.Builder
+	if err := collector.WritePrometheus(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != strings.TrimPrefix(_prometheusGolden, "\n") {
+		t.Errorf("prometheus output:\n%s\nwant:\n%s", got, _prometheusGolden)
+	}
+}
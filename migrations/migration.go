@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// one schema migration, Version must be unique and greater than 0,
+// migrations are applied in ascending order of Version and rolled back in descending order
+type Migration struct {
+	Version     int64
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	// can be nil if the migration cannot be rolled back
+	Down func(ctx context.Context, db *mongo.Database) error
+}
+
+// applied status of one migration
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	Applied     bool
+	// nil if not applied
+	AppliedAt *time.Time
+}
+
+var (
+	_registeredLock       sync.Mutex
+	_registeredMigrations = make([]*Migration, 0)
+)
+
+// register migration globally, usually called in init(),
+// the global migrations are added to every Migrator created after
+func Register(migration *Migration) error {
+	_registeredLock.Lock()
+	defer _registeredLock.Unlock()
+
+	list, err := addMigration(_registeredMigrations, migration)
+	if err != nil {
+		return err
+	}
+	_registeredMigrations = list
+	return nil
+}
+
+// register migration globally, panic if error
+func MustRegister(migration *Migration) {
+	if err := Register(migration); err != nil {
+		panic(err)
+	}
+}
+
+func registeredMigrations() []*Migration {
+	_registeredLock.Lock()
+	defer _registeredLock.Unlock()
+
+	return append(make([]*Migration, 0, len(_registeredMigrations)), _registeredMigrations...)
+}
+
+// add migration to list and keep list sorted by Version
+func addMigration(list []*Migration, migration *Migration) ([]*Migration, error) {
+	if migration == nil {
+		return nil, fmt.Errorf("migration cannot be nil")
+	}
+	if migration.Version <= 0 {
+		return nil, fmt.Errorf("version of migration must be greater than 0, but got %d", migration.Version)
+	}
+	if migration.Up == nil {
+		return nil, fmt.Errorf("Up of migration %d cannot be nil", migration.Version)
+	}
+	for _, eachMigration := range list {
+		if eachMigration.Version == migration.Version {
+			return nil, fmt.Errorf("migration %d has been registered", migration.Version)
+		}
+	}
+	list = append(list, migration)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func noop(ctx context.Context, db *mongo.Database) error {
+	return nil
+}
+
+func newMigration(version int64) *Migration {
+	return &Migration{Version: version, Up: noop, Down: noop}
+}
+
+func versionsOf(list []*Migration) []int64 {
+	versionList := make([]int64, 0, len(list))
+	for _, eachMigration := range list {
+		versionList = append(versionList, eachMigration.Version)
+	}
+	return versionList
+}
+
+func TestAddMigration(t *testing.T) {
+	cases := []struct {
+		name      string
+		list      []*Migration
+		migration *Migration
+		want      []int64
+		wantErr   string
+	}{
+		{
+			name:      "first migration",
+			migration: newMigration(3),
+			want:      []int64{3},
+		},
+		{
+			name:      "sorted by version",
+			list:      []*Migration{newMigration(1), newMigration(5)},
+			migration: newMigration(3),
+			want:      []int64{1, 3, 5},
+		},
+		{
+			name:      "nil migration",
+			migration: nil,
+			wantErr:   "cannot be nil",
+		},
+		{
+			name:      "zero version",
+			migration: newMigration(0),
+			wantErr:   "must be greater than 0",
+		},
+		{
+			name:      "negative version",
+			migration: newMigration(-1),
+			wantErr:   "must be greater than 0",
+		},
+		{
+			name:      "nil Up",
+			migration: &Migration{Version: 1, Down: noop},
+			wantErr:   "Up of migration 1 cannot be nil",
+		},
+		{
+			name:      "duplicate version",
+			list:      []*Migration{newMigration(1), newMigration(2)},
+			migration: newMigration(2),
+			wantErr:   "migration 2 has been registered",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := addMigration(c.list, c.migration)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %s", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := versionsOf(list); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("versions = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMigratorRegister(t *testing.T) {
+	m := NewMigrator("db")
+	if err := m.Register(newMigration(2), newMigration(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(newMigration(1)); err == nil {
+		t.Error("err = nil, want error of duplicate version")
+	}
+	migrations := m.Migrations()
+	if got := versionsOf(migrations); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("versions = %v, want [1 2]", got)
+	}
+	// Migrations returns a copy
+	migrations[0] = newMigration(9)
+	if got := versionsOf(m.Migrations()); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("versions = %v after modifying the copy, want [1 2]", got)
+	}
+}
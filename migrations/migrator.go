@@ -0,0 +1,450 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/abmpio/mongodbr"
+	mongodbrErr "github.com/abmpio/mongodbr/err"
+)
+
+const (
+	DefaultCollectionName = "_migrations"
+	DefaultLockTTL        = 10 * time.Minute
+
+	lockCollectionSuffix = "_lock"
+	lockId               = "migrations"
+)
+
+var (
+	ErrLocked       = errors.New("migrations are locked by another instance")
+	ErrIrreversible = errors.New("migration cannot be rolled back")
+	ErrNoClient     = errors.New("mongodb client is not registered")
+	// the lock expired and was taken by another instance while migrating
+	ErrLockLost = errors.New("migrations lock is lost")
+)
+
+// record of applied migration in _migrations collection
+type migrationRecord struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+type MigratorOptions struct {
+	// key of registered client, default is mongodbr.DefaultAlias
+	ClientKey string
+	// collection which records the applied versions, default is _migrations
+	CollectionName string
+	// run every migration and its record in one transaction, requires replica set
+	UseTransaction bool
+	// only report the migrations which would be applied or rolled back
+	DryRun bool
+	// the lock expires after LockTTL, in case the instance holding the lock crashed
+	LockTTL time.Duration
+	// owner of lock, default is hostname and pid
+	Owner string
+	// logger of applied migrations and lock errors, slog.Default() if nil
+	Logger *slog.Logger
+}
+
+type MigratorOption func(*MigratorOptions)
+
+// migrator with client key
+func MigratorOptionWithClientKey(clientKey string) MigratorOption {
+	return func(o *MigratorOptions) {
+		o.ClientKey = clientKey
+	}
+}
+
+// migrator with collection name which records the applied versions
+func MigratorOptionWithCollectionName(collectionName string) MigratorOption {
+	return func(o *MigratorOptions) {
+		o.CollectionName = collectionName
+	}
+}
+
+// run every migration in mongodbr.RunTransactionWithContext
+func MigratorOptionWithTransaction() MigratorOption {
+	return func(o *MigratorOptions) {
+		o.UseTransaction = true
+	}
+}
+
+// only report, do not run migrations
+func MigratorOptionWithDryRun() MigratorOption {
+	return func(o *MigratorOptions) {
+		o.DryRun = true
+	}
+}
+
+// migrator with lock ttl
+func MigratorOptionWithLockTTL(ttl time.Duration) MigratorOption {
+	return func(o *MigratorOptions) {
+		o.LockTTL = ttl
+	}
+}
+
+// migrator with lock owner
+func MigratorOptionWithOwner(owner string) MigratorOption {
+	return func(o *MigratorOptions) {
+		o.Owner = owner
+	}
+}
+
+// migrator with logger of applied migrations and lock errors
+func MigratorOptionWithLogger(logger *slog.Logger) MigratorOption {
+	return func(o *MigratorOptions) {
+		o.Logger = logger
+	}
+}
+
+// Migrator runs the migrations of one database
+type Migrator struct {
+	databaseName string
+	options      *MigratorOptions
+	migrations   []*Migration
+}
+
+// new Migrator for database, the globally registered migrations are added
+func NewMigrator(databaseName string, opts ...MigratorOption) *Migrator {
+	o := &MigratorOptions{
+		ClientKey:      mongodbr.DefaultAlias,
+		CollectionName: DefaultCollectionName,
+		LockTTL:        DefaultLockTTL,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if len(o.Owner) <= 0 {
+		hostname, _ := os.Hostname()
+		o.Owner = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return &Migrator{
+		databaseName: databaseName,
+		options:      o,
+		migrations:   registeredMigrations(),
+	}
+}
+
+// register migrations to this migrator only
+func (m *Migrator) Register(migrations ...*Migration) error {
+	for _, eachMigration := range migrations {
+		list, err := addMigration(m.migrations, eachMigration)
+		if err != nil {
+			return err
+		}
+		m.migrations = list
+	}
+	return nil
+}
+
+// get all migrations sorted by version
+func (m *Migrator) Migrations() []*Migration {
+	return append(make([]*Migration, 0, len(m.migrations)), m.migrations...)
+}
+
+// apply all pending migrations, return the applied versions
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// apply pending migrations whose version <= targetVersion, 0 means all,
+// return the applied versions(or the versions would be applied when DryRun)
+func (m *Migrator) UpTo(ctx context.Context, targetVersion int64) ([]int64, error) {
+	db, err := m.database()
+	if err != nil {
+		return nil, err
+	}
+	return m.runLocked(ctx, db, func(ctx context.Context) ([]int64, error) {
+		applied, err := m.appliedRecords(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		versionList := make([]int64, 0)
+		for _, eachMigration := range pendingMigrations(m.migrations, applied, targetVersion) {
+			if !m.options.DryRun {
+				if err := m.apply(ctx, db, eachMigration, true); err != nil {
+					return versionList, fmt.Errorf("migration %d up failed: %w", eachMigration.Version, err)
+				}
+			}
+			versionList = append(versionList, eachMigration.Version)
+		}
+		return versionList, nil
+	})
+}
+
+// roll back applied migrations whose version > targetVersion in descending order,
+// return the rolled back versions(or the versions would be rolled back when DryRun)
+func (m *Migrator) DownTo(ctx context.Context, targetVersion int64) ([]int64, error) {
+	db, err := m.database()
+	if err != nil {
+		return nil, err
+	}
+	return m.runLocked(ctx, db, func(ctx context.Context) ([]int64, error) {
+		applied, err := m.appliedRecords(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		versionList := make([]int64, 0)
+		for _, eachMigration := range appliedMigrationsAfter(m.migrations, applied, targetVersion) {
+			if eachMigration.Down == nil {
+				return versionList, fmt.Errorf("migration %d: %w", eachMigration.Version, ErrIrreversible)
+			}
+			if !m.options.DryRun {
+				if err := m.apply(ctx, db, eachMigration, false); err != nil {
+					return versionList, fmt.Errorf("migration %d down failed: %w", eachMigration.Version, err)
+				}
+			}
+			versionList = append(versionList, eachMigration.Version)
+		}
+		return versionList, nil
+	})
+}
+
+// list status of all migrations, including the applied versions which are not registered
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	db, err := m.database()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedRecords(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	statusList := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, eachMigration := range m.migrations {
+		status := &MigrationStatus{
+			Version:     eachMigration.Version,
+			Description: eachMigration.Description,
+		}
+		if record, ok := applied[eachMigration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, eachMigration.Version)
+		}
+		statusList = append(statusList, status)
+	}
+	for _, eachRecord := range applied {
+		statusList = append(statusList, &MigrationStatus{
+			Version:     eachRecord.Version,
+			Description: eachRecord.Description,
+			Applied:     true,
+			AppliedAt:   &eachRecord.AppliedAt,
+		})
+	}
+	sortStatusList(statusList)
+	return statusList, nil
+}
+
+// run migration up or down and update its record
+func (m *Migrator) apply(ctx context.Context, db *mongo.Database, migration *Migration, up bool) error {
+	fn := func(sc context.Context) error {
+		collection := db.Collection(m.options.CollectionName)
+		if up {
+			if err := migration.Up(sc, db); err != nil {
+				return err
+			}
+			_, err := collection.InsertOne(sc, &migrationRecord{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			})
+			return err
+		}
+		if err := migration.Down(sc, db); err != nil {
+			return err
+		}
+		_, err := collection.DeleteOne(sc, bson.D{{Key: "_id", Value: migration.Version}})
+		return err
+	}
+	if up {
+		m.options.Logger.Info("migrations: applying", slog.Int64("version", migration.Version), slog.String("description", migration.Description))
+	} else {
+		m.options.Logger.Info("migrations: rolling back", slog.Int64("version", migration.Version), slog.String("description", migration.Description))
+	}
+	if m.options.UseTransaction {
+		return mongodbr.RunTransactionWithContext(ctx, fn,
+			mongodbr.RunTransactionOptionWithClientKey(m.options.ClientKey))
+	}
+	return fn(ctx)
+}
+
+func (m *Migrator) appliedRecords(ctx context.Context, db *mongo.Database) (map[int64]*migrationRecord, error) {
+	cur, err := db.Collection(m.options.CollectionName).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	recordList := make([]*migrationRecord, 0)
+	if err := cur.All(ctx, &recordList); err != nil {
+		return nil, err
+	}
+	result := make(map[int64]*migrationRecord, len(recordList))
+	for _, eachRecord := range recordList {
+		result[eachRecord.Version] = eachRecord
+	}
+	return result, nil
+}
+
+// run fn while holding the lock, dry run does not take the lock,
+// the lock is renewed every LockTTL/3 while fn runs, the ctx of fn is canceled if the lock is lost
+func (m *Migrator) runLocked(ctx context.Context, db *mongo.Database, fn func(ctx context.Context) ([]int64, error)) ([]int64, error) {
+	if m.options.DryRun {
+		return fn(ctx)
+	}
+	if err := m.lock(ctx, db); err != nil {
+		return nil, err
+	}
+	defer m.unlock(db)
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.heartbeat(fnCtx, db, done, cancel)
+	}()
+	versionList, err := fn(fnCtx)
+	close(done)
+	<-stopped
+	if cause := context.Cause(fnCtx); errors.Is(cause, ErrLockLost) {
+		return versionList, cause
+	}
+	return versionList, err
+}
+
+// extend expireAt of the lock until done is closed, cancel with ErrLockLost if the lock is taken by others
+func (m *Migrator) heartbeat(ctx context.Context, db *mongo.Database, done <-chan struct{}, cancel context.CancelCauseFunc) {
+	interval := m.options.LockTTL / 3
+	if interval <= 0 {
+		interval = DefaultLockTTL / 3
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		filter := bson.D{
+			{Key: "_id", Value: lockId},
+			{Key: "owner", Value: m.options.Owner},
+		}
+		update := bson.D{{Key: "$set", Value: bson.D{
+			{Key: "expireAt", Value: time.Now().Add(m.options.LockTTL)},
+		}}}
+		res, err := m.lockCollection(db).UpdateOne(ctx, filter, update)
+		if err != nil {
+			// the lock is still held until it expires, try again on next tick
+			m.options.Logger.Warn("migrations: renew lock failed", slog.Any("error", err))
+			continue
+		}
+		if res.MatchedCount <= 0 {
+			cancel(ErrLockLost)
+			return
+		}
+	}
+}
+
+func (m *Migrator) lock(ctx context.Context, db *mongo.Database) error {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: lockId},
+		{Key: "expireAt", Value: bson.D{{Key: "$lt", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "owner", Value: m.options.Owner},
+		{Key: "lockedAt", Value: now},
+		{Key: "expireAt", Value: now.Add(m.options.LockTTL)},
+	}}}
+	_, err := m.lockCollection(db).UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		if mongodbrErr.IsDuplicateKeyError(err) {
+			// the lock exists and has not expired
+			return ErrLocked
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *Migrator) unlock(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: lockId},
+		{Key: "owner", Value: m.options.Owner},
+	}
+	if _, err := m.lockCollection(db).DeleteOne(ctx, filter); err != nil {
+		m.options.Logger.Error("migrations: release lock failed", slog.Any("error", err))
+	}
+}
+
+func (m *Migrator) lockCollection(db *mongo.Database) *mongo.Collection {
+	return db.Collection(m.options.CollectionName + lockCollectionSuffix)
+}
+
+func (m *Migrator) database() (*mongo.Database, error) {
+	if len(m.databaseName) <= 0 {
+		return nil, fmt.Errorf("databaseName cannot be empty")
+	}
+	db := mongodbr.GetDatabaseByKey(m.options.ClientKey, m.databaseName)
+	if db == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoClient, m.options.ClientKey)
+	}
+	return db, nil
+}
+
+// the migrations which are not applied and whose version <= targetVersion in ascending order, 0 means all
+func pendingMigrations(migrations []*Migration, applied map[int64]*migrationRecord, targetVersion int64) []*Migration {
+	result := make([]*Migration, 0)
+	for _, eachMigration := range migrations {
+		if targetVersion > 0 && eachMigration.Version > targetVersion {
+			break
+		}
+		if _, ok := applied[eachMigration.Version]; ok {
+			continue
+		}
+		result = append(result, eachMigration)
+	}
+	return result
+}
+
+// the applied migrations whose version > targetVersion in descending order
+func appliedMigrationsAfter(migrations []*Migration, applied map[int64]*migrationRecord, targetVersion int64) []*Migration {
+	result := make([]*Migration, 0)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		eachMigration := migrations[i]
+		if eachMigration.Version <= targetVersion {
+			break
+		}
+		if _, ok := applied[eachMigration.Version]; !ok {
+			continue
+		}
+		result = append(result, eachMigration)
+	}
+	return result
+}
+
+func sortStatusList(statusList []*MigrationStatus) {
+	sort.Slice(statusList, func(i, j int) bool {
+		return statusList[i].Version < statusList[j].Version
+	})
+}
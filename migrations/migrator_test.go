@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSelectMigrations(t *testing.T) {
+	migrations := []*Migration{newMigration(1), newMigration(2), newMigration(3), newMigration(5)}
+	appliedOf := func(versionList ...int64) map[int64]*migrationRecord {
+		applied := make(map[int64]*migrationRecord, len(versionList))
+		for _, eachVersion := range versionList {
+			applied[eachVersion] = &migrationRecord{Version: eachVersion}
+		}
+		return applied
+	}
+	cases := []struct {
+		name          string
+		applied       map[int64]*migrationRecord
+		targetVersion int64
+		wantUp        []int64
+		wantDown      []int64
+	}{
+		{
+			name:          "nothing applied",
+			applied:       appliedOf(),
+			targetVersion: 0,
+			wantUp:        []int64{1, 2, 3, 5},
+			wantDown:      []int64{},
+		},
+		{
+			name:          "all applied",
+			applied:       appliedOf(1, 2, 3, 5),
+			targetVersion: 0,
+			wantUp:        []int64{},
+			wantDown:      []int64{5, 3, 2, 1},
+		},
+		{
+			name:          "gap is applied",
+			applied:       appliedOf(1, 3),
+			targetVersion: 0,
+			wantUp:        []int64{2, 5},
+			wantDown:      []int64{3, 1},
+		},
+		{
+			name:          "target version",
+			applied:       appliedOf(1, 2, 5),
+			targetVersion: 3,
+			wantUp:        []int64{3},
+			wantDown:      []int64{5},
+		},
+		{
+			name:          "target version is not registered",
+			applied:       appliedOf(1),
+			targetVersion: 4,
+			wantUp:        []int64{2, 3},
+			wantDown:      []int64{},
+		},
+		{
+			name:          "applied version which is not registered",
+			applied:       appliedOf(1, 4),
+			targetVersion: 1,
+			wantUp:        []int64{},
+			wantDown:      []int64{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := versionsOf(pendingMigrations(migrations, c.applied, c.targetVersion)); !reflect.DeepEqual(got, c.wantUp) {
+				t.Errorf("pendingMigrations() = %v, want %v", got, c.wantUp)
+			}
+			if got := versionsOf(appliedMigrationsAfter(migrations, c.applied, c.targetVersion)); !reflect.DeepEqual(got, c.wantDown) {
+				t.Errorf("appliedMigrationsAfter() = %v, want %v", got, c.wantDown)
+			}
+		})
+	}
+}
+
+func TestNewMigratorOptions(t *testing.T) {
+	m := NewMigrator("db")
+	if m.options.Logger != slog.Default() {
+		t.Error("Logger is not slog.Default()")
+	}
+	if m.options.CollectionName != DefaultCollectionName || m.options.LockTTL != DefaultLockTTL || len(m.options.Owner) <= 0 {
+		t.Errorf("unexpected default options %+v", m.options)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m = NewMigrator("db", MigratorOptionWithLogger(logger), MigratorOptionWithOwner("me"))
+	if m.options.Logger != logger || m.options.Owner != "me" {
+		t.Errorf("unexpected options %+v", m.options)
+	}
+}
@@ -9,6 +9,9 @@ import (
 type MongodbrAggregateOptions struct {
 	*options.AggregateOptions
 	WithContextOptions
+
+	// the result documents are not entities, such as $facet result
+	SkipAfterFindHook bool
 }
 
 func (o *MongodbrAggregateOptions) List() []func(*options.AggregateOptions) error {
@@ -38,3 +41,11 @@ func MongodbrAggregateOptionWithContext(ctx context.Context) MongodbrAggregateOp
 		mco.WithCtx = ctx
 	}
 }
+
+// do not invoke AfterFind hooks for the result documents, used when they are not entities,
+// such as the result of $group or $facet
+func MongodbrAggregateOptionWithoutAfterFindHook() MongodbrAggregateOption {
+	return func(mco *MongodbrAggregateOptions) {
+		mco.SkipAfterFindHook = true
+	}
+}
@@ -0,0 +1,81 @@
+package mongodbr
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type MongodbrFindPageOptions struct {
+	WithContextOptions
+
+	Sort       interface{}
+	Projection interface{}
+	// compute items and total with one $facet aggregation
+	UseFacet bool
+	// do not count total, only check if there is a next page
+	SkipTotal bool
+}
+
+type MongodbrFindPageOption func(*MongodbrFindPageOptions)
+
+// merge MongodbrFindPageOption list and return one *MongodbrFindPageOptions
+func MergeMongodbrFindPageOption(opts ...MongodbrFindPageOption) *MongodbrFindPageOptions {
+	o := &MongodbrFindPageOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	return o
+}
+
+// MongodbrFindPageOption with context
+func MongodbrFindPageOptionWithContext(ctx context.Context) MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		o.WithCtx = ctx
+	}
+}
+
+// MongodbrFindPageOption with sort
+func MongodbrFindPageOptionWithSort(sort bson.D) MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		if len(sort) > 0 {
+			o.Sort = sort
+		}
+	}
+}
+
+// MongodbrFindPageOption with projection
+func MongodbrFindPageOptionWithProjection(projection interface{}) MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		o.Projection = projection
+	}
+}
+
+// MongodbrFindPageOption with specified fields
+func MongodbrFindPageOptionWithSpecifiedFields(fieldNameList []string) MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		if len(fieldNameList) <= 0 {
+			return
+		}
+		projection := bson.D{}
+		for _, eachFieldName := range fieldNameList {
+			projection = append(projection, bson.E{Key: eachFieldName, Value: 1})
+		}
+		o.Projection = projection
+	}
+}
+
+// compute items and total with one $facet aggregation instead of count + find
+func MongodbrFindPageOptionWithFacet() MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		o.UseFacet = true
+	}
+}
+
+// skip the total count, PageResult.Total and PageResult.PageCount will be -1,
+// only PageResult.HasNext is computed
+func MongodbrFindPageOptionWithoutTotal() MongodbrFindPageOption {
+	return func(o *MongodbrFindPageOptions) {
+		o.SkipTotal = true
+	}
+}
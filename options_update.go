@@ -21,6 +21,19 @@ func (o *MongodbrFindOneAndUpdateOptions) List() []func(*options.FindOneAndUpdat
 
 type MongodbrFindOneAndUpdateOption func(*MongodbrFindOneAndUpdateOptions)
 
+// copy of options without upsert, used by the update of IVersionedEntity,
+// whose upsert would insert a duplicate _id instead of failing when the version does not match
+func (o *MongodbrFindOneAndUpdateOptions) withoutUpsert() *MongodbrFindOneAndUpdateOptions {
+	result := *o
+	findOneAndUpdateOptions := options.FindOneAndUpdateOptions{}
+	if o.FindOneAndUpdateOptions != nil {
+		findOneAndUpdateOptions = *o.FindOneAndUpdateOptions
+	}
+	findOneAndUpdateOptions.Upsert = nil
+	result.FindOneAndUpdateOptions = &findOneAndUpdateOptions
+	return &result
+}
+
 // #region MongodbrFindOneAndUpdateOption members
 
 // merge MongodbrFindOneAndUpdateOption list and return one *MongodbrFindOneAndUpdateOptions
@@ -41,6 +54,19 @@ func MongodbrFindOneAndUpdateOptionWithContext(ctx context.Context) MongodbrFind
 	}
 }
 
+// MongodbrFindOneAndUpdateOption with arrayFilters, used by filtered positional operator $[<identifier>]
+func MongodbrFindOneAndUpdateOptionWithArrayFilters(arrayFilters []interface{}) MongodbrFindOneAndUpdateOption {
+	return func(mfoo *MongodbrFindOneAndUpdateOptions) {
+		if len(arrayFilters) <= 0 {
+			return
+		}
+		if mfoo.FindOneAndUpdateOptions == nil {
+			mfoo.FindOneAndUpdateOptions = &options.FindOneAndUpdateOptions{}
+		}
+		mfoo.ArrayFilters = arrayFilters
+	}
+}
+
 // #endregion
 
 // UpdateOptions with context
@@ -73,6 +99,23 @@ func MongodbrUpdateOptionWithContext(ctx context.Context) MongodbrUpdateOption {
 	}
 }
 
+// MongodbrUpdateOption with arrayFilters, used by filtered positional operator $[<identifier>]
+func MongodbrUpdateOptionWithArrayFilters(arrayFilters []interface{}) MongodbrUpdateOption {
+	return func(mfoo *MongodbrUpdateOptions) {
+		if len(arrayFilters) <= 0 {
+			return
+		}
+		if mfoo.UpdateOneOptions == nil {
+			mfoo.UpdateOneOptions = &options.UpdateOneOptions{}
+		}
+		if mfoo.UpdateManyOptions == nil {
+			mfoo.UpdateManyOptions = &options.UpdateManyOptions{}
+		}
+		mfoo.UpdateOneOptions.ArrayFilters = arrayFilters
+		mfoo.UpdateManyOptions.ArrayFilters = arrayFilters
+	}
+}
+
 // #endregion
 
 // ReplaceOptions with context
@@ -90,6 +133,18 @@ func (o *MongodbrReplaceOptions) List() []func(*options.ReplaceOptions) error {
 
 type MongodbrReplaceOption func(*MongodbrReplaceOptions)
 
+// copy of options without upsert, same as MongodbrFindOneAndUpdateOptions.withoutUpsert
+func (o *MongodbrReplaceOptions) withoutUpsert() *MongodbrReplaceOptions {
+	result := *o
+	replaceOptions := options.ReplaceOptions{}
+	if o.ReplaceOptions != nil {
+		replaceOptions = *o.ReplaceOptions
+	}
+	replaceOptions.Upsert = nil
+	result.ReplaceOptions = &replaceOptions
+	return &result
+}
+
 // #region MongodbrReplaceOption Members
 
 // merge MongodbrReplaceOption list and return one *MongodbrReplaceOptions
@@ -191,6 +246,10 @@ type MongodbrDeleteOptions struct {
 	*options.DeleteOneOptions
 	*options.DeleteManyOptions
 	WithContextOptions
+
+	// user who deletes the records, only used by soft delete,
+	// the current user of context is used if it's empty
+	DeleterId string
 }
 
 type MongodbrDeleteOption func(*MongodbrDeleteOptions)
@@ -216,4 +275,11 @@ func MongodbrDeleteOptionWithContext(ctx context.Context) MongodbrDeleteOption {
 	}
 }
 
+// MongodbrDeleteOption with deleter id, only used by soft delete
+func MongodbrDeleteOptionWithDeleterId(userId string) MongodbrDeleteOption {
+	return func(mfoo *MongodbrDeleteOptions) {
+		mfoo.DeleterId = userId
+	}
+}
+
 // #endregion
@@ -0,0 +1,59 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/abmpio/mongodbr/builder"
+)
+
+func TestWithoutUpsert(t *testing.T) {
+	upsert := true
+	findOneAndUpdateOptions := &MongodbrFindOneAndUpdateOptions{
+		FindOneAndUpdateOptions: &options.FindOneAndUpdateOptions{Upsert: &upsert},
+	}
+	if got := findOneAndUpdateOptions.withoutUpsert(); got.Upsert != nil {
+		t.Errorf("Upsert = %v, want nil", *got.Upsert)
+	}
+	if findOneAndUpdateOptions.Upsert != &upsert {
+		t.Errorf("Upsert of the original options is changed")
+	}
+	if got := (&MongodbrFindOneAndUpdateOptions{}).withoutUpsert(); got.FindOneAndUpdateOptions == nil || got.Upsert != nil {
+		t.Errorf("withoutUpsert() of empty options = %+v", got.FindOneAndUpdateOptions)
+	}
+
+	replaceOptions := &MongodbrReplaceOptions{
+		ReplaceOptions: &options.ReplaceOptions{Upsert: &upsert},
+	}
+	if got := replaceOptions.withoutUpsert(); got.Upsert != nil {
+		t.Errorf("Upsert = %v, want nil", *got.Upsert)
+	}
+	if replaceOptions.Upsert != &upsert {
+		t.Errorf("Upsert of the original options is changed")
+	}
+}
+
+func TestArrayFiltersOption(t *testing.T) {
+	b := builder.NewBsonBuilder().
+		AppendSetField(builder.PositionalFiltered("grades", "elem"), 100).
+		AppendArrayFilter("elem", builder.Cond().Gte(85))
+	want := []interface{}{bson.D{{Key: "elem", Value: bson.D{{Key: "$gte", Value: 85}}}}}
+
+	updateOptions := MergeMongodbrUpdateOption(MongodbrUpdateOptionWithArrayFilters(b.ArrayFilters()))
+	if !reflect.DeepEqual(updateOptions.UpdateOneOptions.ArrayFilters, want) {
+		t.Errorf("UpdateOneOptions.ArrayFilters = %v, want %v", updateOptions.UpdateOneOptions.ArrayFilters, want)
+	}
+	if !reflect.DeepEqual(updateOptions.UpdateManyOptions.ArrayFilters, want) {
+		t.Errorf("UpdateManyOptions.ArrayFilters = %v, want %v", updateOptions.UpdateManyOptions.ArrayFilters, want)
+	}
+	findOneAndUpdateOptions := MergeMongodbrFindOneAndUpdateOption(MongodbrFindOneAndUpdateOptionWithArrayFilters(b.ArrayFilters()))
+	if !reflect.DeepEqual(findOneAndUpdateOptions.ArrayFilters, want) {
+		t.Errorf("FindOneAndUpdateOptions.ArrayFilters = %v, want %v", findOneAndUpdateOptions.ArrayFilters, want)
+	}
+	if got := MergeMongodbrUpdateOption(MongodbrUpdateOptionWithArrayFilters(nil)); got.UpdateOneOptions.ArrayFilters != nil {
+		t.Errorf("ArrayFilters = %v, want nil", got.UpdateOneOptions.ArrayFilters)
+	}
+}
@@ -0,0 +1,365 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/abmpio/mongodbr"
+)
+
+const (
+	DefaultBatchSize      = 100
+	DefaultPollInterval   = time.Second
+	DefaultLockTTL        = 30 * time.Second
+	DefaultMaxAttempts    = 10
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+)
+
+// Publisher publishes events to message broker,
+// an event is marked as dispatched only if Publish returns nil, otherwise it's retried,
+// so the same event may be published more than once and consumers should be idempotent by Event.Id
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// func as Publisher
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+var _ Publisher = PublisherFunc(nil)
+
+type DispatcherOptions struct {
+	// max events published by one DispatchOnce, default is 100
+	BatchSize int
+	// wait time when no event is pending, default is 1s
+	PollInterval time.Duration
+	// the claim of event expires after LockTTL, so that the events claimed by a crashed dispatcher are published again,
+	// it should be longer than the time of Publish, default is 30s
+	LockTTL time.Duration
+	// event is marked as failed after MaxAttempts failures, 0 means retry forever, default is 10
+	MaxAttempts int
+	// wait time before the first retry, doubled for each retry, default is 1s
+	InitialBackoff time.Duration
+	// max wait time before retry, default is 5m
+	MaxBackoff time.Duration
+	// watch the inserted events by change stream instead of waiting PollInterval, requires replica set,
+	// polling is still used as fallback
+	UseChangeStream bool
+	// owner of claimed events, default is hostname and pid
+	Owner string
+	// called when Publish failed
+	OnError func(event *Event, err error)
+	// logger of dispatch and watch errors, slog.Default() if nil
+	Logger *slog.Logger
+}
+
+type DispatcherOption func(*DispatcherOptions)
+
+// dispatcher with batch size
+func DispatcherOptionWithBatchSize(batchSize int) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.BatchSize = batchSize
+	}
+}
+
+// dispatcher with poll interval
+func DispatcherOptionWithPollInterval(interval time.Duration) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.PollInterval = interval
+	}
+}
+
+// dispatcher with lock ttl of claimed events
+func DispatcherOptionWithLockTTL(ttl time.Duration) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.LockTTL = ttl
+	}
+}
+
+// dispatcher with max attempts, 0 means retry forever
+func DispatcherOptionWithMaxAttempts(maxAttempts int) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.MaxAttempts = maxAttempts
+	}
+}
+
+// dispatcher with retry backoff, the wait time grows exponentially from initial and does not exceed max
+func DispatcherOptionWithBackoff(initial time.Duration, max time.Duration) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.InitialBackoff = initial
+		o.MaxBackoff = max
+	}
+}
+
+// watch inserted events by change stream
+func DispatcherOptionWithChangeStream() DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.UseChangeStream = true
+	}
+}
+
+// dispatcher with owner of claimed events
+func DispatcherOptionWithOwner(owner string) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.Owner = owner
+	}
+}
+
+// dispatcher with callback of publish error
+func DispatcherOptionWithOnError(onError func(event *Event, err error)) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.OnError = onError
+	}
+}
+
+// dispatcher with logger of dispatch and watch errors
+func DispatcherOptionWithLogger(logger *slog.Logger) DispatcherOption {
+	return func(o *DispatcherOptions) {
+		o.Logger = logger
+	}
+}
+
+// Dispatcher publishes the pending events of outbox with at-least-once delivery,
+// several dispatchers can run on the same outbox, every event is claimed by one of them at a time
+type Dispatcher struct {
+	outbox    *Outbox
+	publisher Publisher
+	options   *DispatcherOptions
+}
+
+// new Dispatcher for outbox
+func NewDispatcher(outbox *Outbox, publisher Publisher, opts ...DispatcherOption) *Dispatcher {
+	o := &DispatcherOptions{
+		BatchSize:      DefaultBatchSize,
+		PollInterval:   DefaultPollInterval,
+		LockTTL:        DefaultLockTTL,
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultPollInterval
+	}
+	if o.LockTTL <= 0 {
+		o.LockTTL = DefaultLockTTL
+	}
+	if len(o.Owner) <= 0 {
+		hostname, _ := os.Hostname()
+		o.Owner = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	return &Dispatcher{
+		outbox:    outbox,
+		publisher: publisher,
+		options:   o,
+	}
+}
+
+// publish pending events until ctx is done, return the error of ctx,
+// the errors of publishing are retried and passed to OnError
+func (d *Dispatcher) Run(ctx context.Context) error {
+	if d.publisher == nil {
+		return fmt.Errorf("publisher cannot be nil")
+	}
+	var notify <-chan struct{}
+	if d.options.UseChangeStream {
+		notify = d.watch(ctx)
+	}
+	for {
+		count, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.options.Logger.Error("outbox: dispatch failed", slog.Any("error", err))
+		}
+		// more events may be pending
+		if err == nil && count >= d.options.BatchSize {
+			continue
+		}
+		timer := time.NewTimer(d.options.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		case <-notify:
+			timer.Stop()
+		}
+	}
+}
+
+// claim and publish at most BatchSize pending events in order of _id, return the count of handled events,
+// events failed to publish are counted and scheduled to retry
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	if d.publisher == nil {
+		return 0, fmt.Errorf("publisher cannot be nil")
+	}
+	collection, err := d.outbox.Collection()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for count < d.options.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		event, err := d.claim(ctx, collection)
+		if err != nil {
+			return count, err
+		}
+		if event == nil {
+			return count, nil
+		}
+		if err := d.dispatch(ctx, collection, event); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// claim the next pending event whose claim is expired or not claimed, return nil if none
+func (d *Dispatcher) claim(ctx context.Context, collection *mongo.Collection) (*Event, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: StatusPending},
+		{Key: "nextAttemptAt", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "lockedUntil", Value: nil}},
+			bson.D{{Key: "lockedUntil", Value: bson.D{{Key: "$lt", Value: now}}}},
+		}},
+	}
+	// Owner is shared by the dispatchers of one process, so every claim has its own token
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "lockedBy", Value: d.options.Owner},
+		{Key: "lockedUntil", Value: now.Add(d.options.LockTTL)},
+		{Key: "claimToken", Value: bson.NewObjectID().Hex()},
+	}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+	event := &Event{}
+	if err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(event); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return event, nil
+}
+
+// publish event and record the result, the record is ignored if the claim has expired and been taken by others
+func (d *Dispatcher) dispatch(ctx context.Context, collection *mongo.Collection, event *Event) error {
+	publishErr := d.publish(ctx, event)
+	// the event is published again after lock expired if ctx is done before recorded
+	if publishErr != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: event.Id},
+		{Key: "claimToken", Value: event.ClaimToken},
+	}
+	set := d.resultOf(event, publishErr, now)
+	if publishErr != nil && d.options.OnError != nil {
+		d.options.OnError(event, publishErr)
+	}
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{
+			{Key: "lockedBy", Value: ""},
+			{Key: "lockedUntil", Value: ""},
+			{Key: "claimToken", Value: ""},
+		}},
+	}
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// the fields set to event after publishing, the event is dispatched if publishErr is nil,
+// otherwise it's scheduled to retry, or failed after MaxAttempts
+func (d *Dispatcher) resultOf(event *Event, publishErr error, now time.Time) bson.D {
+	if publishErr == nil {
+		return bson.D{
+			{Key: "status", Value: StatusDispatched},
+			{Key: "dispatchedAt", Value: now},
+		}
+	}
+	attempts := event.Attempts + 1
+	status := StatusPending
+	if d.options.MaxAttempts > 0 && attempts >= d.options.MaxAttempts {
+		status = StatusFailed
+	}
+	return bson.D{
+		{Key: "status", Value: status},
+		{Key: "attempts", Value: attempts},
+		{Key: "lastError", Value: publishErr.Error()},
+		{Key: "nextAttemptAt", Value: now.Add(d.backoff(attempts))},
+	}
+}
+
+// call publisher and convert panic to error
+func (d *Dispatcher) publish(ctx context.Context, event *Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in publisher: %v", r)
+		}
+	}()
+	return d.publisher.Publish(ctx, event)
+}
+
+// exponential backoff with equal jitter for the attempts-th failure
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	return mongodbr.ExponentialBackoff(d.options.InitialBackoff, d.options.MaxBackoff, attempts)
+}
+
+// watch inserted events of outbox, the returned channel is notified for every insert until ctx is done,
+// watch is stopped if the change stream cannot be opened or failed, polling still works in that case
+func (d *Dispatcher) watch(ctx context.Context) <-chan struct{} {
+	notify := make(chan struct{}, 1)
+	collection, err := d.outbox.Collection()
+	if err != nil {
+		d.options.Logger.Error("outbox: watch failed", slog.Any("error", err))
+		return notify
+	}
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}},
+	}
+	stream, err := collection.Watch(ctx, pipeline)
+	if err != nil {
+		d.options.Logger.Warn("outbox: watch failed, fall back to polling", slog.Any("error", err))
+		return notify
+	}
+	go func() {
+		defer stream.Close(context.Background())
+		for stream.Next(ctx) {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			d.options.Logger.Warn("outbox: watch stopped, fall back to polling", slog.Any("error", err))
+		}
+	}()
+	return notify
+}
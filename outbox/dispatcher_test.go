@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDispatcherBackoff(t *testing.T) {
+	cases := []struct {
+		name     string
+		initial  time.Duration
+		max      time.Duration
+		attempts int
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "default", initial: DefaultInitialBackoff, max: DefaultMaxBackoff, attempts: 3, wantMin: 2 * time.Second, wantMax: 4 * time.Second},
+		{name: "capped by max", initial: DefaultInitialBackoff, max: DefaultMaxBackoff, attempts: 100, wantMin: DefaultMaxBackoff / 2, wantMax: DefaultMaxBackoff},
+		{name: "no backoff", initial: 0, max: 0, attempts: 100},
+		// retry forever without max
+		{name: "without max at attempt 35", initial: time.Second, attempts: 35, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+		{name: "without max at attempt 10000", initial: time.Second, attempts: 10000, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewDispatcher(NewOutbox("db"), nil,
+				DispatcherOptionWithBackoff(c.initial, c.max),
+				DispatcherOptionWithMaxAttempts(0))
+			for i := 0; i < 100; i++ {
+				if got := d.backoff(c.attempts); got < c.wantMin || got > c.wantMax {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", c.attempts, got, c.wantMin, c.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatcherResultOf(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	publishErr := errors.New("broker is down")
+	cases := []struct {
+		name        string
+		maxAttempts int
+		attempts    int
+		publishErr  error
+		wantStatus  string
+		// expected attempts and backoff range, only checked if publishErr is not nil
+		wantAttempts int
+		wantMin      time.Duration
+		wantMax      time.Duration
+	}{
+		{
+			name:       "published",
+			attempts:   3,
+			wantStatus: StatusDispatched,
+		},
+		{
+			name:         "first failure is retried",
+			maxAttempts:  3,
+			attempts:     0,
+			publishErr:   publishErr,
+			wantStatus:   StatusPending,
+			wantAttempts: 1,
+			wantMin:      500 * time.Millisecond,
+			wantMax:      time.Second,
+		},
+		{
+			name:         "failure before max attempts is retried",
+			maxAttempts:  3,
+			attempts:     1,
+			publishErr:   publishErr,
+			wantStatus:   StatusPending,
+			wantAttempts: 2,
+			wantMin:      time.Second,
+			wantMax:      2 * time.Second,
+		},
+		{
+			name:         "failure of max attempts is failed",
+			maxAttempts:  3,
+			attempts:     2,
+			publishErr:   publishErr,
+			wantStatus:   StatusFailed,
+			wantAttempts: 3,
+			wantMin:      2 * time.Second,
+			wantMax:      4 * time.Second,
+		},
+		{
+			name:         "retry forever without max attempts",
+			maxAttempts:  0,
+			attempts:     1000,
+			publishErr:   publishErr,
+			wantStatus:   StatusPending,
+			wantAttempts: 1001,
+			wantMin:      DefaultMaxBackoff / 2,
+			wantMax:      DefaultMaxBackoff,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := NewDispatcher(NewOutbox("db"), nil,
+				DispatcherOptionWithMaxAttempts(c.maxAttempts),
+				DispatcherOptionWithBackoff(time.Second, DefaultMaxBackoff))
+			set := d.resultOf(&Event{Attempts: c.attempts}, c.publishErr, now)
+			fields := make(map[string]interface{}, len(set))
+			for _, e := range set {
+				fields[e.Key] = e.Value
+			}
+			if fields["status"] != c.wantStatus {
+				t.Errorf("status = %v, want %s", fields["status"], c.wantStatus)
+			}
+			if c.publishErr == nil {
+				want := bson.D{{Key: "status", Value: StatusDispatched}, {Key: "dispatchedAt", Value: now}}
+				if !reflect.DeepEqual(set, want) {
+					t.Errorf("$set = %v, want %v", set, want)
+				}
+				return
+			}
+			if fields["attempts"] != c.wantAttempts {
+				t.Errorf("attempts = %v, want %d", fields["attempts"], c.wantAttempts)
+			}
+			if fields["lastError"] != c.publishErr.Error() {
+				t.Errorf("lastError = %v, want %s", fields["lastError"], c.publishErr)
+			}
+			nextAttemptAt, _ := fields["nextAttemptAt"].(time.Time)
+			if wait := nextAttemptAt.Sub(now); wait < c.wantMin || wait > c.wantMax {
+				t.Errorf("nextAttemptAt is %v after now, want between %v and %v", wait, c.wantMin, c.wantMax)
+			}
+			if _, ok := fields["dispatchedAt"]; ok {
+				t.Error("dispatchedAt is set for a failure")
+			}
+		})
+	}
+}
+
+func TestDispatcherPublishPanic(t *testing.T) {
+	d := NewDispatcher(NewOutbox("db"), PublisherFunc(func(ctx context.Context, event *Event) error {
+		panic("boom")
+	}))
+	if err := d.publish(context.Background(), &Event{}); err == nil || !strings.Contains(err.Error(), "panic in publisher: boom") {
+		t.Errorf("err = %v, want error of panic", err)
+	}
+}
@@ -0,0 +1,281 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/abmpio/mongodbr"
+)
+
+const (
+	DefaultCollectionName = "_outbox"
+
+	// waiting to be published, including the failed ones which will be retried
+	StatusPending = "pending"
+	// published
+	StatusDispatched = "dispatched"
+	// failed MaxAttempts times, will not be retried
+	StatusFailed = "failed"
+)
+
+var (
+	ErrNoClient = errors.New("mongodb client is not registered")
+	// Append is called without transaction
+	ErrNoTransaction = errors.New("outbox events must be appended in a transaction")
+)
+
+// event stored in outbox collection
+type Event struct {
+	Id bson.ObjectID `bson:"_id" json:"id"`
+	// such as the name of domain event, used by publisher to route event
+	Topic string `bson:"topic" json:"topic"`
+	// such as the id of aggregate, optional
+	Key     string            `bson:"key,omitempty" json:"key,omitempty"`
+	Headers map[string]string `bson:"headers,omitempty" json:"headers,omitempty"`
+	// bson document of event, use DecodePayload to decode it
+	Payload bson.Raw `bson:"payload" json:"payload"`
+
+	Status    string    `bson:"status" json:"status"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	// failed times of publishing
+	Attempts      int        `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LastError     string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	DispatchedAt  *time.Time `bson:"dispatchedAt,omitempty" json:"dispatchedAt,omitempty"`
+
+	// the dispatcher which is publishing the event, the claim expires at LockedUntil
+	LockedBy    string     `bson:"lockedBy,omitempty" json:"-"`
+	LockedUntil *time.Time `bson:"lockedUntil,omitempty" json:"-"`
+	// unique token of the claim, the result of publishing is recorded only if the claim is not taken by others
+	ClaimToken string `bson:"claimToken,omitempty" json:"-"`
+}
+
+// new pending event, payload is marshaled as bson document
+func NewEvent(topic string, payload interface{}) (*Event, error) {
+	if len(topic) <= 0 {
+		return nil, fmt.Errorf("topic cannot be empty")
+	}
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload of %s failed: %w", topic, err)
+	}
+	return &Event{
+		Topic:   topic,
+		Payload: raw,
+	}, nil
+}
+
+// set key of event
+func (e *Event) WithKey(key string) *Event {
+	e.Key = key
+	return e
+}
+
+// set header of event
+func (e *Event) WithHeader(key string, value string) *Event {
+	if e.Headers == nil {
+		e.Headers = make(map[string]string)
+	}
+	e.Headers[key] = value
+	return e
+}
+
+// decode payload into v
+func (e *Event) DecodePayload(v interface{}) error {
+	return bson.Unmarshal(e.Payload, v)
+}
+
+type OutboxOptions struct {
+	// key of registered client, default is mongodbr.DefaultAlias,
+	// must be the client of the transaction which events are appended in
+	ClientKey string
+	// default is _outbox
+	CollectionName string
+	// allow Append without transaction
+	AllowWithoutTransaction bool
+	// dispatched events are removed after the retention by ttl index, 0 means keep them
+	DispatchedRetention time.Duration
+}
+
+type OutboxOption func(*OutboxOptions)
+
+// outbox with client key
+func OutboxOptionWithClientKey(clientKey string) OutboxOption {
+	return func(o *OutboxOptions) {
+		o.ClientKey = clientKey
+	}
+}
+
+// outbox with collection name
+func OutboxOptionWithCollectionName(collectionName string) OutboxOption {
+	return func(o *OutboxOptions) {
+		o.CollectionName = collectionName
+	}
+}
+
+// allow Append without transaction, the events are not atomic with other writes in that case
+func OutboxOptionWithoutTransaction() OutboxOption {
+	return func(o *OutboxOptions) {
+		o.AllowWithoutTransaction = true
+	}
+}
+
+// remove dispatched events after retention, the ttl index is created by EnsureIndexes
+func OutboxOptionWithDispatchedRetention(retention time.Duration) OutboxOption {
+	return func(o *OutboxOptions) {
+		o.DispatchedRetention = retention
+	}
+}
+
+// Outbox stores events in one collection of database
+type Outbox struct {
+	databaseName string
+	options      *OutboxOptions
+}
+
+// new Outbox for database
+func NewOutbox(databaseName string, opts ...OutboxOption) *Outbox {
+	o := &OutboxOptions{
+		ClientKey:      mongodbr.DefaultAlias,
+		CollectionName: DefaultCollectionName,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	return &Outbox{
+		databaseName: databaseName,
+		options:      o,
+	}
+}
+
+// get options of outbox
+func (o *Outbox) Options() OutboxOptions {
+	return *o.options
+}
+
+// append events with ctx, ctx should be the one passed to fn of mongodbr.RunTransactionWithContext,
+// so that events are committed or aborted together with other writes of the transaction
+func (o *Outbox) Append(ctx context.Context, events ...*Event) error {
+	if len(events) <= 0 {
+		return nil
+	}
+	if !o.options.AllowWithoutTransaction && !mongodbr.InTransaction(ctx) {
+		return ErrNoTransaction
+	}
+	collection, err := o.Collection()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	documents := make([]interface{}, 0, len(events))
+	for _, eachEvent := range events {
+		if eachEvent == nil {
+			return mongodbr.ErrNilItem
+		}
+		if len(eachEvent.Topic) <= 0 {
+			return fmt.Errorf("topic cannot be empty")
+		}
+		if eachEvent.Id.IsZero() {
+			eachEvent.Id = bson.NewObjectID()
+		}
+		if eachEvent.CreatedAt.IsZero() {
+			eachEvent.CreatedAt = now
+		}
+		if eachEvent.NextAttemptAt.IsZero() {
+			eachEvent.NextAttemptAt = eachEvent.CreatedAt
+		}
+		if len(eachEvent.Payload) <= 0 {
+			eachEvent.Payload = bson.Raw(emptyDocument)
+		}
+		eachEvent.Status = StatusPending
+		documents = append(documents, eachEvent)
+	}
+	_, err = collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(true))
+	return err
+}
+
+// new event with topic and payload, and append it
+func (o *Outbox) AppendPayload(ctx context.Context, topic string, payload interface{}) (*Event, error) {
+	event, err := NewEvent(topic, payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Append(ctx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// set failed events to pending, so that they will be published again, return the count of events
+func (o *Outbox) Requeue(ctx context.Context, idList ...bson.ObjectID) (int64, error) {
+	collection, err := o.Collection()
+	if err != nil {
+		return 0, err
+	}
+	filter := bson.D{{Key: "status", Value: StatusFailed}}
+	if len(idList) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$in", Value: idList}}})
+	}
+	res, err := collection.UpdateMany(ctx, filter, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: StatusPending},
+			{Key: "attempts", Value: 0},
+			{Key: "nextAttemptAt", Value: time.Now()},
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// create the indexes used by dispatcher, and the ttl index of dispatched events if DispatchedRetention > 0
+func (o *Outbox) EnsureIndexes(ctx context.Context) error {
+	collection, err := o.Collection()
+	if err != nil {
+		return err
+	}
+	// the claim query matches status, sorts by _id and filters nextAttemptAt by range,
+	// so the keys follow equality, sort and range order
+	models := []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "_id", Value: 1},
+				{Key: "nextAttemptAt", Value: 1},
+			},
+			Options: options.Index().SetName("status__id_nextAttemptAt"),
+		},
+	}
+	if o.options.DispatchedRetention > 0 {
+		models = append(models, mongo.IndexModel{
+			Keys: bson.D{{Key: "dispatchedAt", Value: 1}},
+			Options: options.Index().
+				SetName("dispatchedAt_ttl").
+				SetExpireAfterSeconds(int32(o.options.DispatchedRetention / time.Second)),
+		})
+	}
+	_, err = collection.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+// get outbox collection
+func (o *Outbox) Collection() (*mongo.Collection, error) {
+	if len(o.databaseName) <= 0 {
+		return nil, fmt.Errorf("databaseName cannot be empty")
+	}
+	db := mongodbr.GetDatabaseByKey(o.options.ClientKey, o.databaseName)
+	if db == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoClient, o.options.ClientKey)
+	}
+	return db.Collection(o.options.CollectionName), nil
+}
+
+// bson of {}
+var emptyDocument = []byte{5, 0, 0, 0, 0}
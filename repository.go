@@ -42,11 +42,12 @@ func NewRepository(databaseName string, collectionName string, opts ...func(*New
 	for _, eachOpt := range opts {
 		eachOpt(o)
 	}
-	var collection *mongo.Collection
-	if len(o.clientKey) <= 0 {
-		collection = GetCollection(o.databaseName, o.collectionName)
-	} else {
-		collection = GetCollectionByKey(o.clientKey, o.databaseName, o.collectionName)
+	// resolve collection by key for every operation, so that the re-registered client is used
+	getCollection := func() *mongo.Collection {
+		if len(o.clientKey) <= 0 {
+			return GetCollection(o.databaseName, o.collectionName)
+		}
+		return GetCollectionByKey(o.clientKey, o.databaseName, o.collectionName)
 	}
 	mongodbrOpts := make([]RepositoryOption, 0)
 	if len(o.DefaultSortField) > 0 {
@@ -55,9 +56,7 @@ func NewRepository(databaseName string, collectionName string, opts ...func(*New
 			return fo
 		}))
 	}
-	repositoryBase, err := NewRepositoryBase(func() *mongo.Collection {
-		return collection
-	}, mongodbrOpts...)
+	repositoryBase, err := NewRepositoryBase(getCollection, mongodbrOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -13,11 +13,24 @@ func (r *RepositoryBase) Aggregate(pipeline interface{}, dataList interface{}, o
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, aOptions.WithCtx)
 	defer cancel()
 
-	cur, err := r.collection.Aggregate(ctx, pipeline, aOptions)
+	ctx, span := r.startSpan(ctx, "aggregate", nil)
+	defer endSpan(span, &err)
+
+	pipeline, err = r.configuration.notDeletedPipeline(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	cur, err := r.collection().Aggregate(ctx, pipeline, aOptions)
 	if err != nil {
 		return err
 	}
 	defer cur.Close(ctx)
 
-	return cur.All(ctx, dataList)
+	if err := cur.All(ctx, dataList); err != nil {
+		return err
+	}
+	if aOptions.SkipAfterFindHook {
+		return nil
+	}
+	return r.configuration.hookAfterFindList(ctx, dataList)
 }
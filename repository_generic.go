@@ -0,0 +1,241 @@
+package mongodbr
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Repository is a repository whose whole surface is typed on T,
+// it's built on RepositoryBase and MongoCol
+type Repository[T any] struct {
+	base *RepositoryBase
+}
+
+// new a Repository[T] instance with database name and collection name
+func NewRepositoryT[T any](databaseName string, collectionName string, opts ...func(*NewRepositoryOption)) (*Repository[T], error) {
+	repositoryBase, err := NewRepository(databaseName, collectionName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRepositoryTWith[T](repositoryBase), nil
+}
+
+// new a Repository[T] instance with a RepositoryBase, panic if repositoryBase is nil
+func NewRepositoryTWith[T any](repositoryBase *RepositoryBase) *Repository[T] {
+	if repositoryBase == nil {
+		panic("repositoryBase cannot be nil")
+	}
+	return &Repository[T]{
+		base: repositoryBase,
+	}
+}
+
+// get the underlying RepositoryBase
+func (r *Repository[T]) GetRepositoryBase() *RepositoryBase {
+	return r.base
+}
+
+func (r *Repository[T]) GetName() string {
+	return r.base.GetName()
+}
+
+func (r *Repository[T]) GetCollection() *mongo.Collection {
+	return r.base.GetCollection()
+}
+
+// #region create members
+
+func (r *Repository[T]) Create(item *T, opts ...MongodbrInsertOneOption) (bson.ObjectID, error) {
+	if item == nil {
+		return bson.NilObjectID, ErrNilItem
+	}
+	return r.base.Create(item, opts...)
+}
+
+func (r *Repository[T]) CreateMany(itemList []*T, opts ...MongodbrInsertManyOption) ([]bson.ObjectID, error) {
+	list := make([]interface{}, 0, len(itemList))
+	for _, eachItem := range itemList {
+		if eachItem == nil {
+			return nil, ErrNilItem
+		}
+		list = append(list, eachItem)
+	}
+	return r.base.CreateMany(list, opts...)
+}
+
+// #endregion
+
+// #region find members
+
+func (r *Repository[T]) CountByFilter(filter interface{}, opts ...MongodbrCountOption) (int64, error) {
+	return r.base.CountByFilter(filter, opts...)
+}
+
+func (r *Repository[T]) CountAll(opts ...WithContextOptions) (int64, error) {
+	return r.base.CountAll(opts...)
+}
+
+// find one T by _id, return nil if not found
+func (r *Repository[T]) FindById(id bson.ObjectID, opts ...MongodbrFindOneOption) (*T, error) {
+	return FindTByObjectId[T](r.base, id, opts...)
+}
+
+// find one T by filter, return nil if not found
+func (r *Repository[T]) FindOne(filter interface{}, opts ...MongodbrFindOneOption) (*T, error) {
+	return FindOneTByFilter[T](r.base, filter, opts...)
+}
+
+func (r *Repository[T]) FindAll(opts ...MongodbrFindOption) ([]*T, error) {
+	return FindAllT[T](r.base, opts...)
+}
+
+func (r *Repository[T]) FindListByFilter(filter interface{}, opts ...MongodbrFindOption) ([]*T, error) {
+	return FindTByFilter[T](r.base, filter, opts...)
+}
+
+func (r *Repository[T]) FindListByIdList(idList []bson.ObjectID, opts ...MongodbrFindOption) ([]*T, error) {
+	return FindTListByObjectIdList[T](r.base, idList, opts...)
+}
+
+// iterate all T matched filter one by one,
+// stop iteration and return the error if fn return error
+func (r *Repository[T]) Stream(filter interface{}, fn func(item *T) error, opts ...MongodbrFindOption) error {
+	return r.base.StreamByFilter(filter, func() interface{} {
+		return new(T)
+	}, func(item interface{}) error {
+		return fn(item.(*T))
+	}, opts...)
+}
+
+// find one page of T by filter, pageIndex starts from 1
+func (r *Repository[T]) FindPage(filter interface{}, pageIndex int64, pageSize int64, opts ...MongodbrFindPageOption) (*PageResult[T], error) {
+	return FindPage[T](r.base, filter, pageIndex, pageSize, opts...)
+}
+
+// find one page of T with keyset pagination
+func (r *Repository[T]) FindPageByKeyset(filter interface{}, request *KeysetPageRequest, opts ...MongodbrFindOption) ([]*T, *KeysetPageInfo, error) {
+	list := make([]*T, 0)
+	pageInfo, err := r.base.FindListByKeyset(filter, &list, request, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, pageInfo, nil
+}
+
+func (r *Repository[T]) Distinct(fieldName string, filter interface{}, opts ...*WithContextOptions) ([]interface{}, error) {
+	return r.base.Distinct(fieldName, filter, opts...)
+}
+
+// #endregion
+
+// #region update members
+
+// update T by its _id with $set, T must implement IEntity
+func (r *Repository[T]) Update(item *T, opts ...MongodbrFindOneAndUpdateOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	entity, ok := any(item).(IEntity)
+	if !ok {
+		return ErrInvalidType
+	}
+	return r.base.FindOneAndUpdate(entity, opts...)
+}
+
+func (r *Repository[T]) UpdateById(id bson.ObjectID, update interface{}, opts ...MongodbrFindOneAndUpdateOption) error {
+	return r.base.FindOneAndUpdateWithId(id, update, opts...)
+}
+
+func (r *Repository[T]) UpdateOne(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) error {
+	return r.base.UpdateOne(filter, update, opts...)
+}
+
+func (r *Repository[T]) UpdateMany(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) (interface{}, error) {
+	return r.base.UpdateMany(filter, update, opts...)
+}
+
+// bulk update T list by their _id, T must implement IEntity
+func (r *Repository[T]) BulkUpdate(itemList []*T, opts ...MongodbrBulkWriteOption) (*mongo.BulkWriteResult, error) {
+	entityList := make([]IEntity, 0, len(itemList))
+	for _, eachItem := range itemList {
+		if eachItem == nil {
+			return nil, ErrNilItem
+		}
+		entity, ok := any(eachItem).(IEntity)
+		if !ok {
+			return nil, ErrInvalidType
+		}
+		entityList = append(entityList, entity)
+	}
+	return r.base.BulkWriteEntityList(entityList, opts...)
+}
+
+// #endregion
+
+// #region replace members
+
+func (r *Repository[T]) Replace(filter interface{}, item *T, opts ...MongodbrReplaceOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	return r.base.Replace(filter, item, opts...)
+}
+
+func (r *Repository[T]) ReplaceById(id bson.ObjectID, item *T, opts ...MongodbrReplaceOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	return r.base.ReplaceById(id, item, opts...)
+}
+
+// #endregion
+
+// #region delete members
+
+func (r *Repository[T]) DeleteById(id bson.ObjectID, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteOne(id, opts...)
+}
+
+func (r *Repository[T]) DeleteOne(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteOneByFilter(filter, opts...)
+}
+
+func (r *Repository[T]) DeleteMany(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteMany(filter, opts...)
+}
+
+// restore soft deleted T list matched filter
+func (r *Repository[T]) Restore(filter interface{}, opts ...MongodbrUpdateOption) (int64, error) {
+	return r.base.Restore(filter, opts...)
+}
+
+// physically remove T list matched filter, even if soft delete is enabled
+func (r *Repository[T]) HardDelete(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.HardDelete(filter, opts...)
+}
+
+// physically remove all T of the collection, even if soft delete is enabled
+func (r *Repository[T]) HardDeleteAll(opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.HardDeleteAll(opts...)
+}
+
+// #endregion
+
+// #region index members
+
+// synchronize the indexes declared by struct tags of T with the indexes of collection
+func (r *Repository[T]) SyncIndexes(opts ...SyncIndexesOption) (*SyncIndexesResult, error) {
+	return SyncIndexes(r.base, new(T), opts...)
+}
+
+// #endregion
+
+// #region aggregate members
+
+// aggregate and decode every result document as T,
+// use AggregateT when the pipeline returns another shape
+func (r *Repository[T]) Aggregate(pipeline interface{}, opts ...MongodbrAggregateOption) ([]*T, error) {
+	return AggregateT[T](r.base, pipeline, opts...)
+}
+
+// #endregion
@@ -0,0 +1,70 @@
+package mongodbr
+
+import (
+	"errors"
+	"testing"
+)
+
+type notEntity struct {
+	Name string
+}
+
+func TestRepositoryUpdateInvalidItem(t *testing.T) {
+	cases := []struct {
+		name    string
+		update  func() error
+		wantErr error
+	}{
+		{
+			name:    "nil item",
+			update:  func() error { return (&Repository[Entity]{}).Update(nil) },
+			wantErr: ErrNilItem,
+		},
+		{
+			name:    "item is not IEntity",
+			update:  func() error { return (&Repository[notEntity]{}).Update(&notEntity{}) },
+			wantErr: ErrInvalidType,
+		},
+		{
+			name: "nil item of bulk update",
+			update: func() error {
+				_, err := (&Repository[Entity]{}).BulkUpdate([]*Entity{nil})
+				return err
+			},
+			wantErr: ErrNilItem,
+		},
+		{
+			name: "item of bulk update is not IEntity",
+			update: func() error {
+				_, err := (&Repository[notEntity]{}).BulkUpdate([]*notEntity{{}})
+				return err
+			},
+			wantErr: ErrInvalidType,
+		},
+		{
+			name:    "nil keyed item",
+			update:  func() error { return (&KeyedRepository[KeyedEntity[string], string]{}).Update(nil) },
+			wantErr: ErrNilItem,
+		},
+		{
+			name:    "keyed item is not IKeyedEntity",
+			update:  func() error { return (&KeyedRepository[notEntity, string]{}).Update(&notEntity{}) },
+			wantErr: ErrInvalidType,
+		},
+		{
+			name: "nil item of keyed bulk update",
+			update: func() error {
+				_, err := (&KeyedRepository[KeyedEntity[string], string]{}).BulkUpdate([]*KeyedEntity[string]{nil})
+				return err
+			},
+			wantErr: ErrNilItem,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := c.update(); !errors.Is(err, c.wantErr) {
+				t.Errorf("err = %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
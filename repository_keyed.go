@@ -0,0 +1,340 @@
+package mongodbr
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// entity whose _id is of type K, such as string, int64 or uuid.UUID
+type IKeyedEntity[K comparable] interface {
+	GetId() K
+}
+
+// entity whose _id is of type K, embed it instead of Entity when _id is not bson.ObjectID
+type KeyedEntity[K comparable] struct {
+	Id K `json:"id,omitempty" bson:"_id"`
+}
+
+var _ IEntityIdSetter = (*KeyedEntity[string])(nil)
+
+func (entity KeyedEntity[K]) GetId() K {
+	return entity.Id
+}
+
+// #region IEntityIdSetter Members
+
+func (entity *KeyedEntity[K]) IsIdEmpty() bool {
+	var zero K
+	return entity.Id == zero
+}
+
+// id must be convertible to K, bson.ObjectID is converted to its hex string when K is string
+func (entity *KeyedEntity[K]) SetId(id interface{}) error {
+	if objectId, ok := id.(bson.ObjectID); ok {
+		if key, ok := any(objectId.Hex()).(K); ok {
+			entity.Id = key
+			return nil
+		}
+	}
+	key, err := toKey[K](id)
+	if err != nil {
+		return err
+	}
+	entity.Id = key
+	return nil
+}
+
+// #endregion
+
+// KeyedRepository is a repository typed on T whose _id is of type K,
+// use it when _id is not bson.ObjectID, it's built on RepositoryBase and MongoCol
+type KeyedRepository[T any, K comparable] struct {
+	base *RepositoryBase
+}
+
+// new a KeyedRepository[T, K] instance with database name and collection name
+func NewKeyedRepository[T any, K comparable](databaseName string, collectionName string, opts ...func(*NewRepositoryOption)) (*KeyedRepository[T, K], error) {
+	repositoryBase, err := NewRepository(databaseName, collectionName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyedRepositoryWith[T, K](repositoryBase), nil
+}
+
+// new a KeyedRepository[T, K] instance with a RepositoryBase, panic if repositoryBase is nil
+func NewKeyedRepositoryWith[T any, K comparable](repositoryBase *RepositoryBase) *KeyedRepository[T, K] {
+	if repositoryBase == nil {
+		panic("repositoryBase cannot be nil")
+	}
+	return &KeyedRepository[T, K]{
+		base: repositoryBase,
+	}
+}
+
+// get the underlying RepositoryBase
+func (r *KeyedRepository[T, K]) GetRepositoryBase() *RepositoryBase {
+	return r.base
+}
+
+func (r *KeyedRepository[T, K]) GetName() string {
+	return r.base.GetName()
+}
+
+func (r *KeyedRepository[T, K]) GetCollection() *mongo.Collection {
+	return r.base.GetCollection()
+}
+
+// #region create members
+
+func (r *KeyedRepository[T, K]) Create(item *T, opts ...MongodbrInsertOneOption) (K, error) {
+	var zero K
+	if item == nil {
+		return zero, ErrNilItem
+	}
+	insertedId, err := r.base.InsertOne(item, opts...)
+	if insertedId == nil {
+		return zero, err
+	}
+	key, keyErr := toKey[K](insertedId)
+	if err != nil {
+		return key, err
+	}
+	return key, keyErr
+}
+
+func (r *KeyedRepository[T, K]) CreateMany(itemList []*T, opts ...MongodbrInsertManyOption) ([]K, error) {
+	list := make([]interface{}, 0, len(itemList))
+	for _, eachItem := range itemList {
+		if eachItem == nil {
+			return nil, ErrNilItem
+		}
+		list = append(list, eachItem)
+	}
+	insertedIds, err := r.base.InsertMany(list, opts...)
+	if insertedIds == nil {
+		return nil, err
+	}
+	keyList := make([]K, 0, len(insertedIds))
+	for _, eachId := range insertedIds {
+		key, keyErr := toKey[K](eachId)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, err
+}
+
+// #endregion
+
+// #region find members
+
+func (r *KeyedRepository[T, K]) CountByFilter(filter interface{}, opts ...MongodbrCountOption) (int64, error) {
+	return r.base.CountByFilter(filter, opts...)
+}
+
+func (r *KeyedRepository[T, K]) CountAll(opts ...WithContextOptions) (int64, error) {
+	return r.base.CountAll(opts...)
+}
+
+// find one T by _id, return nil if not found
+func (r *KeyedRepository[T, K]) FindById(id K, opts ...MongodbrFindOneOption) (*T, error) {
+	return FindOneTByFilter[T](r.base, bson.M{"_id": id}, opts...)
+}
+
+// find one T by filter, return nil if not found
+func (r *KeyedRepository[T, K]) FindOne(filter interface{}, opts ...MongodbrFindOneOption) (*T, error) {
+	return FindOneTByFilter[T](r.base, filter, opts...)
+}
+
+func (r *KeyedRepository[T, K]) FindAll(opts ...MongodbrFindOption) ([]*T, error) {
+	return FindAllT[T](r.base, opts...)
+}
+
+func (r *KeyedRepository[T, K]) FindListByFilter(filter interface{}, opts ...MongodbrFindOption) ([]*T, error) {
+	return FindTByFilter[T](r.base, filter, opts...)
+}
+
+func (r *KeyedRepository[T, K]) FindListByIdList(idList []K, opts ...MongodbrFindOption) ([]*T, error) {
+	return FindTByFilter[T](r.base, bson.M{"_id": bson.M{"$in": idList}}, opts...)
+}
+
+// iterate all T matched filter one by one,
+// stop iteration and return the error if fn return error
+func (r *KeyedRepository[T, K]) Stream(filter interface{}, fn func(item *T) error, opts ...MongodbrFindOption) error {
+	return r.base.StreamByFilter(filter, func() interface{} {
+		return new(T)
+	}, func(item interface{}) error {
+		return fn(item.(*T))
+	}, opts...)
+}
+
+// find one page of T by filter, pageIndex starts from 1
+func (r *KeyedRepository[T, K]) FindPage(filter interface{}, pageIndex int64, pageSize int64, opts ...MongodbrFindPageOption) (*PageResult[T], error) {
+	return FindPage[T](r.base, filter, pageIndex, pageSize, opts...)
+}
+
+// find one page of T with keyset pagination
+func (r *KeyedRepository[T, K]) FindPageByKeyset(filter interface{}, request *KeysetPageRequest, opts ...MongodbrFindOption) ([]*T, *KeysetPageInfo, error) {
+	list := make([]*T, 0)
+	pageInfo, err := r.base.FindListByKeyset(filter, &list, request, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return list, pageInfo, nil
+}
+
+func (r *KeyedRepository[T, K]) Distinct(fieldName string, filter interface{}, opts ...*WithContextOptions) ([]interface{}, error) {
+	return r.base.Distinct(fieldName, filter, opts...)
+}
+
+// #endregion
+
+// #region update members
+
+// update T by its _id with $set, T must implement IKeyedEntity[K]
+func (r *KeyedRepository[T, K]) Update(item *T, opts ...MongodbrFindOneAndUpdateOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	entity, ok := any(item).(IKeyedEntity[K])
+	if !ok {
+		return ErrInvalidType
+	}
+	return r.base.UpdateItemById(entity.GetId(), item, opts...)
+}
+
+func (r *KeyedRepository[T, K]) UpdateById(id K, update interface{}, opts ...MongodbrFindOneAndUpdateOption) error {
+	return r.base.UpdateOneById(id, update, opts...)
+}
+
+func (r *KeyedRepository[T, K]) UpdateOne(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) error {
+	return r.base.UpdateOne(filter, update, opts...)
+}
+
+func (r *KeyedRepository[T, K]) UpdateMany(filter interface{}, update interface{}, opts ...MongodbrUpdateOption) (interface{}, error) {
+	return r.base.UpdateMany(filter, update, opts...)
+}
+
+// bulk update T list by their _id, T must implement IKeyedEntity[K]
+func (r *KeyedRepository[T, K]) BulkUpdate(itemList []*T, opts ...MongodbrBulkWriteOption) (*mongo.BulkWriteResult, error) {
+	idList := make([]interface{}, 0, len(itemList))
+	list := make([]interface{}, 0, len(itemList))
+	for _, eachItem := range itemList {
+		if eachItem == nil {
+			return nil, ErrNilItem
+		}
+		entity, ok := any(eachItem).(IKeyedEntity[K])
+		if !ok {
+			return nil, ErrInvalidType
+		}
+		idList = append(idList, entity.GetId())
+		list = append(list, eachItem)
+	}
+	return r.base.BulkUpdateItemsById(idList, list, opts...)
+}
+
+// #endregion
+
+// #region replace members
+
+func (r *KeyedRepository[T, K]) Replace(filter interface{}, item *T, opts ...MongodbrReplaceOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	return r.base.Replace(filter, item, opts...)
+}
+
+func (r *KeyedRepository[T, K]) ReplaceById(id K, item *T, opts ...MongodbrReplaceOption) error {
+	if item == nil {
+		return ErrNilItem
+	}
+	return r.base.Replace(bson.M{"_id": id}, item, opts...)
+}
+
+// #endregion
+
+// #region delete members
+
+func (r *KeyedRepository[T, K]) DeleteById(id K, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteOneByFilter(bson.M{"_id": id}, opts...)
+}
+
+func (r *KeyedRepository[T, K]) DeleteOne(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteOneByFilter(filter, opts...)
+}
+
+func (r *KeyedRepository[T, K]) DeleteMany(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.DeleteMany(filter, opts...)
+}
+
+// restore soft deleted T list matched filter
+func (r *KeyedRepository[T, K]) Restore(filter interface{}, opts ...MongodbrUpdateOption) (int64, error) {
+	return r.base.Restore(filter, opts...)
+}
+
+// physically remove T list matched filter, even if soft delete is enabled
+func (r *KeyedRepository[T, K]) HardDelete(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.HardDelete(filter, opts...)
+}
+
+// physically remove all T of the collection, even if soft delete is enabled
+func (r *KeyedRepository[T, K]) HardDeleteAll(opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	return r.base.HardDeleteAll(opts...)
+}
+
+// #endregion
+
+// #region index members
+
+// synchronize the indexes declared by struct tags of T with the indexes of collection
+func (r *KeyedRepository[T, K]) SyncIndexes(opts ...SyncIndexesOption) (*SyncIndexesResult, error) {
+	return SyncIndexes(r.base, new(T), opts...)
+}
+
+// #endregion
+
+// #region aggregate members
+
+// aggregate and decode every result document as T,
+// use AggregateT when the pipeline returns another shape
+func (r *KeyedRepository[T, K]) Aggregate(pipeline interface{}, opts ...MongodbrAggregateOption) ([]*T, error) {
+	return AggregateT[T](r.base, pipeline, opts...)
+}
+
+// #endregion
+
+// registry used to convert _id to K, uuid.UUID is decoded from binary
+var _keyRegistry = func() *bson.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeEncoder(_tUUID, bson.ValueEncoderFunc(uuidEncodeValue))
+	registry.RegisterTypeDecoder(_tUUID, bson.ValueDecoderFunc(uuidDecodeValue))
+	return registry
+}()
+
+// convert _id which is returned by driver to K,
+// the driver returns _id as it's decoded into interface{}, such as bson.Binary for uuid.UUID
+func toKey[K comparable](id interface{}) (K, error) {
+	if key, ok := id.(K); ok {
+		return key, nil
+	}
+	var holder struct {
+		Value K `bson:"v"`
+	}
+	buf := new(bytes.Buffer)
+	encoder := bson.NewEncoder(bson.NewDocumentWriter(buf))
+	encoder.SetRegistry(_keyRegistry)
+	if err := encoder.Encode(bson.D{{Key: "v", Value: id}}); err != nil {
+		return holder.Value, fmt.Errorf("%w: cannot convert _id of type %T to %s, %v", ErrInvalidType, id, reflect.TypeOf(holder.Value), err)
+	}
+	decoder := bson.NewDecoder(bson.NewDocumentReader(buf))
+	decoder.SetRegistry(_keyRegistry)
+	if err := decoder.Decode(&holder); err != nil {
+		return holder.Value, fmt.Errorf("%w: cannot convert _id of type %T to %s, %v", ErrInvalidType, id, reflect.TypeOf(holder.Value), err)
+	}
+	return holder.Value, nil
+}
@@ -63,3 +63,13 @@ func FindTListByObjectIdList[T any](repository IRepository, idList []bson.Object
 	}
 	return list, nil
 }
+
+// aggregate and decode every result document as T
+func AggregateT[T any](repository IRepository, pipeline interface{}, opts ...MongodbrAggregateOption) ([]*T, error) {
+	list := make([]*T, 0)
+	err := repository.Aggregate(pipeline, &list, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -1,8 +1,10 @@
 package mongodbr
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -11,7 +13,10 @@ import (
 
 type MongoCol struct {
 	configuration *Configuration
-	collection    *mongo.Collection
+	// resolve the collection for every operation, so that a re-registered client is used
+	getCollection func() *mongo.Collection
+	// the last resolved collection, used if getCollection returns nil
+	lastCollection atomic.Pointer[mongo.Collection]
 }
 
 // new MongoCol instance, panic if col is nil
@@ -19,17 +24,55 @@ func NewMongoCol(col *mongo.Collection, opts ...*Configuration) *MongoCol {
 	if col == nil {
 		panic(errors.New("col cannot be nil"))
 	}
+	return newMongoColWith(func() *mongo.Collection {
+		return col
+	}, opts...)
+}
+
+func newMongoColWith(getCollection func() *mongo.Collection, opts ...*Configuration) *MongoCol {
 	c := NewConfiguration()
 	if len(opts) > 0 {
 		c = opts[0]
 	}
 	mongoCol := &MongoCol{
 		configuration: c,
-		collection:    col,
+		getCollection: getCollection,
+	}
+	if mongoCol.collection() == nil {
+		panic(errors.New("col cannot be nil"))
 	}
 	return mongoCol
 }
 
+// get the current collection
+func (c *MongoCol) collection() *mongo.Collection {
+	if col := c.getCollection(); col != nil {
+		c.lastCollection.Store(col)
+		return col
+	}
+	return c.lastCollection.Load()
+}
+
+// the bson registry of the client of collection, so that entities are encoded as the driver does,
+// the registry with uuid codec is used if the client is not registered
+func (c *MongoCol) bsonRegistry() *bson.Registry {
+	col := c.collection()
+	if col == nil {
+		return _keyRegistry
+	}
+	clientOptions := DefaultClientRegistry.optionsOf(col.Database().Client())
+	if clientOptions == nil {
+		return _keyRegistry
+	}
+	if clientOptions.Registry == nil {
+		// same as the default registry of driver
+		return _defaultBsonRegistry
+	}
+	return clientOptions.Registry
+}
+
+var _defaultBsonRegistry = bson.NewRegistry()
+
 // RepositoryBase represents a mongodb repository
 type RepositoryBase struct {
 	documentName string
@@ -38,16 +81,17 @@ type RepositoryBase struct {
 
 var _ IRepository = (*RepositoryBase)(nil)
 
-// new一个新的实例
+// new一个新的实例,getDbCollection is called for every operation,
+// so that the repository follows the client re-registered by RegistClient if getDbCollection resolves it by key
 func NewRepositoryBase(getDbCollection func() *mongo.Collection, opts ...RepositoryOption) (*RepositoryBase, error) {
 	if getDbCollection == nil {
 		err := fmt.Errorf("getDbCollection参数不能为nil")
 		return nil, err
 	}
-	coll := getDbCollection()
+	mongoCol := newMongoColWith(getDbCollection)
 	repository := &RepositoryBase{
-		MongoCol:     NewMongoCol(coll),
-		documentName: coll.Name(),
+		MongoCol:     mongoCol,
+		documentName: mongoCol.collection().Name(),
 	}
 	for _, eachItem := range opts {
 		eachItem(repository.configuration)
@@ -58,8 +102,37 @@ func NewRepositoryBase(getDbCollection func() *mongo.Collection, opts ...Reposit
 // #region create members
 
 func (r *RepositoryBase) Create(item interface{}, opts ...MongodbrInsertOneOption) (id bson.ObjectID, err error) {
+	insertedId, err := r.InsertOne(item, opts...)
+	objectId, ok := insertedId.(bson.ObjectID)
+	if err != nil {
+		return objectId, err
+	}
+	if !ok {
+		return bson.NilObjectID, ErrInvalidType
+	}
+	return objectId, nil
+}
+
+func (r *RepositoryBase) CreateMany(itemList []interface{}, opts ...MongodbrInsertManyOption) (ids []bson.ObjectID, err error) {
+	insertedIds, err := r.InsertMany(itemList, opts...)
+	if err != nil && insertedIds == nil {
+		return nil, err
+	}
+	for _, v := range insertedIds {
+		switch v := v.(type) {
+		case bson.ObjectID:
+			ids = append(ids, v)
+		default:
+			return nil, ErrInvalidType
+		}
+	}
+	return ids, err
+}
+
+// insert item and return its _id, the _id can be of any type
+func (r *RepositoryBase) InsertOne(item interface{}, opts ...MongodbrInsertOneOption) (_ interface{}, err error) {
 	if item == nil {
-		return bson.NilObjectID, fmt.Errorf("item is nil,col:%s", r.documentName)
+		return nil, fmt.Errorf("item is nil,col:%s", r.documentName)
 	}
 
 	insertOneOptions := &MongodbrInsertOneOptions{
@@ -71,18 +144,27 @@ func (r *RepositoryBase) Create(item interface{}, opts ...MongodbrInsertOneOptio
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, insertOneOptions.WithCtx)
 	defer cancel()
 
-	r.onBeforeCreate(item)
-	res, err := r.collection.InsertOne(ctx, item, insertOneOptions)
+	ctx, span := r.startSpan(ctx, "insertOne", nil)
+	defer endSpan(span, &err)
+
+	if err := r.configuration.hookBeforeCreate(ctx, item); err != nil {
+		return nil, err
+	}
+	if err := r.configuration.validate(item); err != nil {
+		return nil, err
+	}
+	res, err := r.collection().InsertOne(ctx, item, insertOneOptions)
 	if err != nil {
-		return bson.NilObjectID, err
+		return nil, err
 	}
-	if id, ok := res.InsertedID.(bson.ObjectID); ok {
-		return id, nil
+	if err := r.configuration.hookAfterCreate(ctx, item); err != nil {
+		return res.InsertedID, err
 	}
-	return bson.NilObjectID, ErrInvalidType
+	return res.InsertedID, nil
 }
 
-func (r *RepositoryBase) CreateMany(itemList []interface{}, opts ...MongodbrInsertManyOption) (ids []bson.ObjectID, err error) {
+// insert item list and return their _id list, the _id can be of any type
+func (r *RepositoryBase) InsertMany(itemList []interface{}, opts ...MongodbrInsertManyOption) (_ []interface{}, err error) {
 	if len(itemList) <= 0 {
 		return nil, nil
 	}
@@ -96,22 +178,28 @@ func (r *RepositoryBase) CreateMany(itemList []interface{}, opts ...MongodbrInse
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, insertManyOptions.WithCtx)
 	defer cancel()
 
+	ctx, span := r.startSpan(ctx, "insertMany", nil)
+	defer endSpan(span, &err)
+
+	// all before hooks are invoked before inserting, so nothing is inserted if any hook fails
 	for index := range itemList {
-		r.onBeforeCreate(itemList[index])
+		if err := r.configuration.hookBeforeCreate(ctx, itemList[index]); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.configuration.validateMany(itemList); err != nil {
+		return nil, err
 	}
-	res, err := r.collection.InsertMany(ctx, itemList, insertManyOptions)
+	res, err := r.collection().InsertMany(ctx, itemList, insertManyOptions)
 	if err != nil {
 		return nil, err
 	}
-	for _, v := range res.InsertedIDs {
-		switch v := v.(type) {
-		case bson.ObjectID:
-			ids = append(ids, v)
-		default:
-			return nil, ErrInvalidType
+	for index := range itemList {
+		if err := r.configuration.hookAfterCreate(ctx, itemList[index]); err != nil {
+			return res.InsertedIDs, err
 		}
 	}
-	return ids, nil
+	return res.InsertedIDs, nil
 }
 
 // #endregion
@@ -120,6 +208,9 @@ func (r *RepositoryBase) ReplaceById(id bson.ObjectID, doc interface{}, opts ...
 	return r.Replace(bson.M{"_id": id}, doc, opts...)
 }
 
+// replace the document matched filter,
+// if doc implements IVersionedEntity, only the expected version is matched and the version is incremented,
+// ErrConcurrencyConflict is returned when no document matched, and upsert of options is ignored
 func (r *RepositoryBase) Replace(filter interface{}, doc interface{}, opts ...MongodbrReplaceOption) (err error) {
 	rOptions := &MongodbrReplaceOptions{
 		ReplaceOptions: &options.ReplaceOptions{},
@@ -130,11 +221,37 @@ func (r *RepositoryBase) Replace(filter interface{}, doc interface{}, opts ...Mo
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, rOptions.WithCtx)
 	defer cancel()
 
-	_, err = r.collection.ReplaceOne(ctx, filter, doc, rOptions)
+	ctx, span := r.startSpan(ctx, "replaceOne", filter)
+	defer endSpan(span, &err)
+
+	if err := r.configuration.hookBeforeReplace(ctx, filter, doc); err != nil {
+		return err
+	}
+	if err := r.configuration.validate(doc); err != nil {
+		return err
+	}
+	versionedEntity, ok := doc.(IVersionedEntity)
+	if !ok {
+		_, err = r.collection().ReplaceOne(ctx, filter, doc, rOptions)
+		if err != nil {
+			return err
+		}
+		return r.configuration.hookAfterUpdate(ctx, filter, doc)
+	}
+
+	// only replace the expected version, and increment it
+	expectedVersion := versionedEntity.GetVersion()
+	versionedEntity.SetVersion(expectedVersion + 1)
+	result, err := r.collection().ReplaceOne(ctx, withVersionFilter(filter, expectedVersion), doc, rOptions.withoutUpsert())
 	if err != nil {
+		versionedEntity.SetVersion(expectedVersion)
 		return err
 	}
-	return nil
+	if result.MatchedCount <= 0 && result.UpsertedCount <= 0 {
+		versionedEntity.SetVersion(expectedVersion)
+		return ErrConcurrencyConflict
+	}
+	return r.configuration.hookAfterUpdate(ctx, filter, doc)
 }
 
 // 删除指定id的记录
@@ -149,12 +266,7 @@ func (r *RepositoryBase) DeleteOne(id bson.ObjectID, opts ...MongodbrDeleteOptio
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
 	defer cancel()
 
-	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id}, asOptionLister(deleteOptions.DeleteOneOptions))
-	if err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return r.delete(ctx, bson.M{"_id": id}, false, r.configuration.softDelete, deleteOptions)
 }
 
 // 删除指定条件的一条记录
@@ -169,12 +281,7 @@ func (r *RepositoryBase) DeleteOneByFilter(filter interface{}, opts ...MongodbrD
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
 	defer cancel()
 
-	result, err := r.collection.DeleteOne(ctx, filter, asOptionLister(deleteOptions.DeleteOneOptions))
-	if err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return r.delete(ctx, filter, false, r.configuration.softDelete, deleteOptions)
 }
 
 // 删除多条记录
@@ -193,12 +300,7 @@ func (r *RepositoryBase) DeleteMany(filter interface{}, opts ...MongodbrDeleteOp
 	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
 	defer cancel()
 
-	result, err := r.collection.DeleteMany(ctx, filter, asOptionLister(deleteOptions.DeleteManyOptions))
-	if err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return r.delete(ctx, filter, true, r.configuration.softDelete, deleteOptions)
 }
 
 func (r *RepositoryBase) GetName() (name string) {
@@ -206,13 +308,37 @@ func (r *RepositoryBase) GetName() (name string) {
 }
 
 func (r *RepositoryBase) GetCollection() (c *mongo.Collection) {
-	return r.collection
+	return r.collection()
 }
 
-func (r *RepositoryBase) onBeforeCreate(item interface{}) {
-	entityHookable, ok := item.(IEntityBeforeCreate)
-	if !ok {
-		return
+// delete or soft delete the documents matched filter, with delete hooks
+func (r *RepositoryBase) delete(ctx context.Context, filter interface{}, many bool, soft bool, deleteOptions *MongodbrDeleteOptions) (_ *mongo.DeleteResult, err error) {
+	operation := "deleteOne"
+	if many {
+		operation = "deleteMany"
+	}
+	ctx, span := r.startSpan(ctx, operation, filter)
+	defer endSpan(span, &err)
+	if soft {
+		span.SetAttributes(Attr(AttributeDbSoftDelete, true))
 	}
-	entityHookable.BeforeCreate()
+
+	if err := r.configuration.hookBeforeDelete(ctx, filter); err != nil {
+		return nil, err
+	}
+	var result *mongo.DeleteResult
+	if soft {
+		result, err = r.softDelete(ctx, filter, many, deleteOptions)
+	} else if many {
+		result, err = r.collection().DeleteMany(ctx, filter, asOptionLister(deleteOptions.DeleteManyOptions))
+	} else {
+		result, err = r.collection().DeleteOne(ctx, filter, asOptionLister(deleteOptions.DeleteOneOptions))
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := r.configuration.hookAfterDelete(ctx, filter, result); err != nil {
+		return result, err
+	}
+	return result, nil
 }
@@ -0,0 +1,268 @@
+package mongodbr
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+const (
+	FieldIsDeleted    = "isDeleted"
+	FieldDeletionTime = "deletionTime"
+	FieldDeleterId    = "deleterId"
+)
+
+type softDeleteDisabledKey struct{}
+
+// enable soft delete, deletes will set isDeleted/deletionTime/deleterId fields instead of removing documents,
+// and all queries will ignore the deleted documents
+func WithSoftDelete() RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.softDelete = true
+	}
+}
+
+// return a context with which the queries will include soft deleted documents
+func WithSoftDeleteDisabled(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, softDeleteDisabledKey{}, true)
+}
+
+// check if soft delete filter is disabled in ctx
+func IsSoftDeleteDisabled(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	disabled, _ := ctx.Value(softDeleteDisabledKey{}).(bool)
+	return disabled
+}
+
+// is soft delete enabled
+func (c *Configuration) IsSoftDelete() bool {
+	return c.softDelete
+}
+
+// append not deleted predicate to filter when soft delete is enabled
+func (c *Configuration) notDeletedFilter(ctx context.Context, filter interface{}) interface{} {
+	if c == nil || !c.softDelete || IsSoftDeleteDisabled(ctx) {
+		return filter
+	}
+	notDeleted := bson.D{{Key: FieldIsDeleted, Value: bson.D{{Key: "$ne", Value: true}}}}
+	if filter == nil {
+		return notDeleted
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, notDeleted}}}
+}
+
+// stages which must be the first stage of pipeline, the not deleted $match is inserted after them
+var _leadingStageNames = map[string]bool{
+	"$search":       true,
+	"$searchMeta":   true,
+	"$vectorSearch": true,
+	"$collStats":    true,
+	"$indexStats":   true,
+	"$documents":    true,
+	"$changeStream": true,
+}
+
+// prepend not deleted $match stage to pipeline when soft delete is enabled,
+// pipeline of any slice type is normalized to bson.A, otherwise an error is returned.
+// the predicate is merged into the query of leading $geoNear stage,
+// and the $match is inserted after the other stages which must be the first stage, such as $search
+func (c *Configuration) notDeletedPipeline(ctx context.Context, pipeline interface{}) (interface{}, error) {
+	if c == nil || !c.softDelete || IsSoftDeleteDisabled(ctx) {
+		return pipeline, nil
+	}
+	stageList, err := toStageList(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	match := bson.D{{Key: "$match", Value: c.notDeletedFilter(ctx, nil)}}
+	if len(stageList) <= 0 {
+		return bson.A{match}, nil
+	}
+	name, value := stageOf(stageList[0])
+	if name == "$geoNear" {
+		if geoNear, ok := c.notDeletedGeoNear(ctx, value); ok {
+			result := append(bson.A{bson.D{{Key: name, Value: geoNear}}}, stageList[1:]...)
+			return result, nil
+		}
+	}
+	if name == "$geoNear" || _leadingStageNames[name] {
+		result := append(bson.A{stageList[0], match}, stageList[1:]...)
+		return result, nil
+	}
+	return append(bson.A{match}, stageList...), nil
+}
+
+// the $geoNear stage document whose query contains the not deleted predicate,
+// return false if the stage document is not a bson.D or a map
+func (c *Configuration) notDeletedGeoNear(ctx context.Context, value interface{}) (bson.D, bool) {
+	var geoNear bson.D
+	switch v := value.(type) {
+	case bson.D:
+		geoNear = append(make(bson.D, 0, len(v)+1), v...)
+	case bson.M:
+		geoNear = mapToSortedD(v)
+	case map[string]interface{}:
+		geoNear = mapToSortedD(v)
+	default:
+		return nil, false
+	}
+	for i := range geoNear {
+		if geoNear[i].Key == "query" {
+			geoNear[i].Value = c.notDeletedFilter(ctx, geoNear[i].Value)
+			return geoNear, true
+		}
+	}
+	return append(geoNear, bson.E{Key: "query", Value: c.notDeletedFilter(ctx, nil)}), true
+}
+
+// name and value of a stage document with a single key, name is empty if stage is not a document
+func stageOf(stage interface{}) (string, interface{}) {
+	switch s := stage.(type) {
+	case bson.D:
+		if len(s) == 1 {
+			return s[0].Key, s[0].Value
+		}
+	case bson.M:
+		if len(s) == 1 {
+			for key, value := range s {
+				return key, value
+			}
+		}
+	case map[string]interface{}:
+		if len(s) == 1 {
+			for key, value := range s {
+				return key, value
+			}
+		}
+	case bson.Raw:
+		elements, err := s.Elements()
+		if err == nil && len(elements) == 1 {
+			return elements[0].Key(), elements[0].Value()
+		}
+	}
+	return "", nil
+}
+
+// set the soft delete fields of the documents matched filter
+func (r *RepositoryBase) softDelete(ctx context.Context, filter interface{}, many bool, deleteOptions *MongodbrDeleteOptions) (*mongo.DeleteResult, error) {
+	update := r.softDeleteUpdate(ctx, deleteOptions.DeleterId)
+	// ignore documents which have been deleted
+	filter = r.configuration.notDeletedFilter(ctx, filter)
+
+	var result *mongo.UpdateResult
+	var err error
+	if many {
+		result, err = r.collection().UpdateMany(ctx, filter, update)
+	} else {
+		result, err = r.collection().UpdateOne(ctx, filter, update)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mongo.DeleteResult{
+		DeletedCount: result.ModifiedCount,
+		Acknowledged: result.Acknowledged,
+	}, nil
+}
+
+// the update document which sets the soft delete fields, deleterId is the current user if it's empty
+func (r *RepositoryBase) softDeleteUpdate(ctx context.Context, deleterId string) bson.D {
+	set := bson.D{
+		{Key: FieldIsDeleted, Value: true},
+		{Key: FieldDeletionTime, Value: r.configuration.Now()},
+	}
+	if len(deleterId) <= 0 {
+		deleterId = r.configuration.CurrentUser(ctx)
+	}
+	if len(deleterId) > 0 {
+		set = append(set, bson.E{Key: FieldDeleterId, Value: deleterId})
+	}
+	return bson.D{{Key: "$set", Value: set}}
+}
+
+// restore soft deleted documents matched filter,return the restored count
+func (r *RepositoryBase) Restore(filter interface{}, opts ...MongodbrUpdateOption) (_ int64, err error) {
+	uOptions := MergeMongodbrUpdateOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, uOptions.WithCtx)
+	defer cancel()
+
+	ctx, span := r.startSpan(ctx, "updateMany", filter)
+	defer endSpan(span, &err)
+
+	deletedFilter := bson.D{{Key: FieldIsDeleted, Value: true}}
+	if filter != nil {
+		deletedFilter = bson.D{{Key: "$and", Value: bson.A{filter, deletedFilter}}}
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: FieldIsDeleted, Value: false}}},
+		{Key: "$unset", Value: bson.D{
+			{Key: FieldDeletionTime, Value: ""},
+			{Key: FieldDeleterId, Value: ""},
+		}},
+	}
+	result, err := r.collection().UpdateMany(ctx, deletedFilter, update, asOptionLister(uOptions.UpdateManyOptions))
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// restore soft deleted document by _id
+func (r *RepositoryBase) RestoreById(id bson.ObjectID, opts ...MongodbrUpdateOption) (bool, error) {
+	count, err := r.Restore(bson.M{"_id": id}, opts...)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// physically remove the documents matched filter, whether soft delete is enabled or not,
+// filter cannot be nil, use HardDeleteAll to remove all documents
+func (r *RepositoryBase) HardDelete(filter interface{}, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	if filter == nil {
+		return nil, ErrNilFilter
+	}
+	deleteOptions := MergeMongodbrDeleteOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
+	defer cancel()
+
+	return r.delete(ctx, filter, true, false, deleteOptions)
+}
+
+// physically remove all documents of the collection, whether soft delete is enabled or not
+func (r *RepositoryBase) HardDeleteAll(opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	deleteOptions := MergeMongodbrDeleteOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
+	defer cancel()
+
+	return r.delete(ctx, bson.D{}, true, false, deleteOptions)
+}
+
+// physically remove the document by _id, whether soft delete is enabled or not
+func (r *RepositoryBase) HardDeleteById(id bson.ObjectID, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	deleteOptions := MergeMongodbrDeleteOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
+	defer cancel()
+
+	return r.delete(ctx, bson.M{"_id": id}, false, false, deleteOptions)
+}
+
+// physically remove the soft deleted documents whose deletion time is before deletedBefore
+func (r *RepositoryBase) Purge(deletedBefore time.Time, opts ...MongodbrDeleteOption) (*mongo.DeleteResult, error) {
+	deleteOptions := MergeMongodbrDeleteOption(opts...)
+	ctx, cancel := CreateContextAndCancelWith(r.configuration, deleteOptions.WithCtx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: FieldIsDeleted, Value: true},
+		{Key: FieldDeletionTime, Value: bson.D{{Key: "$lt", Value: deletedBefore}}},
+	}
+	return r.delete(ctx, filter, true, false, deleteOptions)
+}
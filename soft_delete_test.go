@@ -0,0 +1,122 @@
+package mongodbr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestNotDeletedPipeline(t *testing.T) {
+	notDeleted := bson.D{{Key: FieldIsDeleted, Value: bson.D{{Key: "$ne", Value: true}}}}
+	match := bson.D{{Key: "$match", Value: notDeleted}}
+	limit := bson.D{{Key: "$limit", Value: 10}}
+	near := bson.D{{Key: "type", Value: "Point"}, {Key: "coordinates", Value: bson.A{1.0, 2.0}}}
+	search := bson.D{{Key: "$search", Value: bson.D{{Key: "text", Value: "a"}}}}
+	cases := []struct {
+		name     string
+		pipeline interface{}
+		want     bson.A
+	}{
+		{
+			name:     "nil pipeline",
+			pipeline: nil,
+			want:     bson.A{match},
+		},
+		{
+			name:     "match is prepended",
+			pipeline: mongo.Pipeline{limit},
+			want:     bson.A{match, limit},
+		},
+		{
+			name:     "after $search",
+			pipeline: bson.A{search, limit},
+			want:     bson.A{search, match, limit},
+		},
+		{
+			name:     "after $vectorSearch of bson.M",
+			pipeline: []bson.M{{"$vectorSearch": bson.M{"index": "v"}}},
+			want:     bson.A{bson.M{"$vectorSearch": bson.M{"index": "v"}}, match},
+		},
+		{
+			name:     "after $collStats",
+			pipeline: bson.A{bson.D{{Key: "$collStats", Value: bson.D{}}}},
+			want:     bson.A{bson.D{{Key: "$collStats", Value: bson.D{}}}, match},
+		},
+		{
+			name:     "after $indexStats",
+			pipeline: bson.A{bson.D{{Key: "$indexStats", Value: bson.D{}}}, limit},
+			want:     bson.A{bson.D{{Key: "$indexStats", Value: bson.D{}}}, match, limit},
+		},
+		{
+			name:     "after $documents",
+			pipeline: bson.A{bson.D{{Key: "$documents", Value: bson.A{}}}},
+			want:     bson.A{bson.D{{Key: "$documents", Value: bson.A{}}}, match},
+		},
+		{
+			name:     "$search which is not the first stage",
+			pipeline: bson.A{limit, search},
+			want:     bson.A{match, limit, search},
+		},
+		{
+			name: "query is added to $geoNear",
+			pipeline: bson.A{
+				bson.D{{Key: "$geoNear", Value: bson.D{{Key: "near", Value: near}, {Key: "distanceField", Value: "d"}}}},
+				limit,
+			},
+			want: bson.A{
+				bson.D{{Key: "$geoNear", Value: bson.D{{Key: "near", Value: near}, {Key: "distanceField", Value: "d"}, {Key: "query", Value: notDeleted}}}},
+				limit,
+			},
+		},
+		{
+			name: "query of $geoNear is merged",
+			pipeline: bson.A{
+				bson.D{{Key: "$geoNear", Value: bson.D{{Key: "near", Value: near}, {Key: "query", Value: bson.D{{Key: "a", Value: 1}}}}}},
+			},
+			want: bson.A{
+				bson.D{{Key: "$geoNear", Value: bson.D{
+					{Key: "near", Value: near},
+					{Key: "query", Value: bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "a", Value: 1}}, notDeleted}}}},
+				}}},
+			},
+		},
+		{
+			name: "$geoNear of bson.M",
+			pipeline: bson.A{
+				bson.M{"$geoNear": bson.M{"near": near, "distanceField": "d"}},
+			},
+			want: bson.A{
+				bson.D{{Key: "$geoNear", Value: bson.D{{Key: "distanceField", Value: "d"}, {Key: "near", Value: near}, {Key: "query", Value: notDeleted}}}},
+			},
+		},
+	}
+	configuration := NewConfiguration()
+	WithSoftDelete()(configuration)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := configuration.notDeletedPipeline(context.Background(), c.pipeline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("pipeline = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNotDeletedPipelineDisabled(t *testing.T) {
+	pipeline := bson.A{bson.D{{Key: "$limit", Value: 1}}}
+	configuration := NewConfiguration()
+	WithSoftDelete()(configuration)
+	got, err := configuration.notDeletedPipeline(WithSoftDeleteDisabled(context.Background()), pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, pipeline) {
+		t.Errorf("pipeline = %v, want %v", got, pipeline)
+	}
+}
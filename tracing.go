@@ -0,0 +1,222 @@
+package mongodbr
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// attribute names of span, follow the opentelemetry semantic conventions of database
+const (
+	AttributeDbSystem     = "db.system"
+	AttributeDbName       = "db.name"
+	AttributeDbCollection = "db.collection"
+	AttributeDbOperation  = "db.operation"
+	// filter with values replaced by "?"
+	AttributeDbFilter = "db.mongodb.filter"
+	// documents are soft deleted
+	AttributeDbSoftDelete = "db.mongodb.soft_delete"
+	// attempt of transaction, starts from 1
+	AttributeDbTransactionAttempt = "db.mongodb.transaction.attempt"
+
+	DbSystemMongodb = "mongodb"
+)
+
+// key value pair of span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// new Attribute
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// span of one repository operation or transaction
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// end span, err is nil if the operation succeeded
+	End(err error)
+}
+
+// start spans around repository operations and transactions,
+// the returned context carries the span and is passed to the driver and hooks
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+// tracer which does nothing
+var NoopTracer Tracer = noopTracer{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+
+func (noopSpan) End(error) {}
+
+// adapt a func to Tracer, so that tracing systems can be wired without depending on them, such as opentelemetry:
+//
+//	tracer := otel.Tracer("mongodbr")
+//	mongodbr.SetDefaultTracer(&mongodbr.FuncTracer{
+//		StartFunc: func(ctx context.Context, spanName string, attrs []mongodbr.Attribute) (context.Context, func([]mongodbr.Attribute, error)) {
+//			ctx, span := tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(toOtel(attrs)...))
+//			return ctx, func(attrs []mongodbr.Attribute, err error) {
+//				span.SetAttributes(toOtel(attrs)...)
+//				if err != nil {
+//					span.RecordError(err)
+//					span.SetStatus(codes.Error, err.Error())
+//				}
+//				span.End()
+//			}
+//		},
+//	})
+type FuncTracer struct {
+	// start span and return the func which ends it with the attributes set after starting
+	StartFunc func(ctx context.Context, spanName string, attrs []Attribute) (context.Context, func(attrs []Attribute, err error))
+}
+
+var _ Tracer = (*FuncTracer)(nil)
+
+func (t *FuncTracer) Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span) {
+	if t == nil || t.StartFunc == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, end := t.StartFunc(ctx, spanName, attrs)
+	return ctx, &funcSpan{end: end}
+}
+
+type funcSpan struct {
+	lock  sync.Mutex
+	attrs []Attribute
+	end   func(attrs []Attribute, err error)
+}
+
+func (s *funcSpan) SetAttributes(attrs ...Attribute) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *funcSpan) End(err error) {
+	if s.end == nil {
+		return
+	}
+	s.lock.Lock()
+	attrs := s.attrs
+	s.lock.Unlock()
+	s.end(attrs, err)
+}
+
+var (
+	_defaultTracerLock sync.RWMutex
+	_defaultTracer     = NoopTracer
+)
+
+// set the global Tracer, which is used by transactions and by repositories without Tracer
+func SetDefaultTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = NoopTracer
+	}
+	_defaultTracerLock.Lock()
+	defer _defaultTracerLock.Unlock()
+
+	_defaultTracer = tracer
+}
+
+// get the global Tracer
+func DefaultTracer() Tracer {
+	_defaultTracerLock.RLock()
+	defer _defaultTracerLock.RUnlock()
+
+	return _defaultTracer
+}
+
+// set Tracer of repository
+func WithTracer(tracer Tracer) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.tracer = tracer
+	}
+}
+
+// get the Tracer of repository, or the global Tracer
+func (c *Configuration) Tracer() Tracer {
+	if c != nil && c.tracer != nil {
+		return c.tracer
+	}
+	return DefaultTracer()
+}
+
+// start span of operation on the collection, filter can be nil
+func (r *MongoCol) startSpan(ctx context.Context, operation string, filter interface{}) (context.Context, Span) {
+	tracer := r.configuration.Tracer()
+	if tracer == NoopTracer {
+		return ctx, noopSpan{}
+	}
+	databaseName := r.collection().Database().Name()
+	collectionName := r.collection().Name()
+	attrs := []Attribute{
+		Attr(AttributeDbSystem, DbSystemMongodb),
+		Attr(AttributeDbName, databaseName),
+		Attr(AttributeDbCollection, collectionName),
+		Attr(AttributeDbOperation, operation),
+	}
+	if filter != nil {
+		attrs = append(attrs, Attr(AttributeDbFilter, filterShape(filter)))
+	}
+	return tracer.Start(ctx, operation+" "+databaseName+"."+collectionName, attrs...)
+}
+
+// end span with the error which err points to, used with defer
+func endSpan(span Span, err *error) {
+	span.End(*err)
+}
+
+// render filter as extended json with all values replaced by "?",
+// so that the shape of filter is traced without leaking data
+func filterShape(filter interface{}) string {
+	data, err := bson.Marshal(filter)
+	if err != nil {
+		return ""
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return ""
+	}
+	result, err := bson.MarshalExtJSON(shapeOf(doc), false, false)
+	if err != nil {
+		return ""
+	}
+	return string(result)
+}
+
+func shapeOf(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		result := make(bson.D, 0, len(v))
+		for _, eachElement := range v {
+			result = append(result, bson.E{Key: eachElement.Key, Value: shapeOf(eachElement.Value)})
+		}
+		return result
+	case bson.A:
+		// keep the documents of $and,$or and so on, collapse the values of $in and so on
+		result := make(bson.A, 0, len(v))
+		for _, eachValue := range v {
+			if _, ok := eachValue.(bson.D); !ok {
+				return "?"
+			}
+			result = append(result, shapeOf(eachValue))
+		}
+		return result
+	default:
+		return "?"
+	}
+}
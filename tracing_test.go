@@ -0,0 +1,66 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestFilterShape(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter interface{}
+		want   string
+	}{
+		{
+			name:   "nil",
+			filter: nil,
+			want:   "",
+		},
+		{
+			name:   "empty",
+			filter: bson.D{},
+			want:   `{}`,
+		},
+		{
+			name:   "values are hidden",
+			filter: bson.D{{Key: "name", Value: "a"}, {Key: "age", Value: 18}},
+			want:   `{"name":"?","age":"?"}`,
+		},
+		{
+			name:   "operators are kept",
+			filter: bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 18}}}},
+			want:   `{"age":{"$gt":"?"}}`,
+		},
+		{
+			name:   "values of $in are collapsed",
+			filter: bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{1, 2, 3}}}}},
+			want:   `{"_id":{"$in":"?"}}`,
+		},
+		{
+			name: "documents of $or are kept",
+			filter: bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "name", Value: "a"}},
+				bson.D{{Key: "age", Value: bson.D{{Key: "$lt", Value: 18}}}},
+			}}},
+			want: `{"$or":[{"name":"?"},{"age":{"$lt":"?"}}]}`,
+		},
+		{
+			name:   "map",
+			filter: bson.M{"name": "a"},
+			want:   `{"name":"?"}`,
+		},
+		{
+			name:   "not a document",
+			filter: 1,
+			want:   "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := filterShape(c.filter); got != c.want {
+				t.Errorf("filterShape() = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -17,6 +16,14 @@ type RunTransactionOptions struct {
 	Timeout time.Duration
 	// 最大重试次数
 	MaxRetry int
+	// 已在事务中时的传播行为
+	Propagation Propagation
+	// 第一次重试前的等待时间，之后每次翻倍，默认为10ms
+	InitialBackoff time.Duration
+	// 最大等待时间，默认为1s
+	MaxBackoff time.Duration
+	// 每次重试前调用
+	OnRetry func(info *TransactionRetryInfo)
 
 	withSessionOptions     []*options.SessionOptions
 	withTransactionOptions []*options.TransactionOptions
@@ -59,14 +66,51 @@ func RunTransactionOptionWithMaxRetry(maxRetry int) func(options *RunTransaction
 	}
 }
 
+// 修改重试前的等待时间，等待时间从initial开始指数增长，不超过max，并加入随机抖动
+func RunTransactionOptionWithBackoff(initial time.Duration, max time.Duration) func(options *RunTransactionOptions) {
+	return func(runOptions *RunTransactionOptions) {
+		runOptions.InitialBackoff = initial
+		runOptions.MaxBackoff = max
+	}
+}
+
+// 设置每次重试前的回调
+func RunTransactionOptionWithOnRetry(onRetry func(info *TransactionRetryInfo)) func(options *RunTransactionOptions) {
+	return func(runOptions *RunTransactionOptions) {
+		runOptions.OnRetry = onRetry
+	}
+}
+
 func newDefaultRunTransactionOptions() *RunTransactionOptions {
 	return &RunTransactionOptions{
-		ClientKey: DefaultAlias,
-		Timeout:   30 * time.Second,
-		MaxRetry:  3,
+		ClientKey:      DefaultAlias,
+		Timeout:        30 * time.Second,
+		MaxRetry:       3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     time.Second,
 	}
 }
 
+const (
+	// the whole transaction is retried
+	TransactionRetryPhaseTransaction = "transaction"
+	// only commit is retried
+	TransactionRetryPhaseCommit = "commit"
+)
+
+// passed to OnRetry before retrying
+type TransactionRetryInfo struct {
+	// TransactionRetryPhaseTransaction or TransactionRetryPhaseCommit
+	Phase string
+	// the failed attempt of phase, starts from 1
+	Attempt  int
+	MaxRetry int
+	// error of the failed attempt
+	Err error
+	// time to wait before retrying
+	Backoff time.Duration
+}
+
 func RunTransaction(fn func(context.Context) error, opts ...RunTransactionOption) error {
 	return RunTransactionWithContext(context.Background(), fn, opts...)
 }
@@ -76,104 +120,56 @@ func RunTransactionWithContext(ctx context.Context,
 	fn func(context.Context) error,
 	opts ...RunTransactionOption) error {
 
-	transactionOptions := newDefaultRunTransactionOptions()
-	for _, eachOpt := range opts {
-		eachOpt(transactionOptions)
-	}
-
-	maxRetry := transactionOptions.MaxRetry
-	if maxRetry <= 0 {
-		maxRetry = 3
-	}
-
-	// Get MongoDB client
-	c := GetClient(transactionOptions.ClientKey)
-
-	// start session
-	session, err := c.StartSession(asOptionListers(transactionOptions.withSessionOptions)...)
-	if err != nil {
-		return err
-	}
-	defer session.EndSession(context.Background())
-
-	for attempt := 0; attempt < maxRetry; attempt++ {
-		var txnCtx context.Context
-		var cancel context.CancelFunc
-
-		// Timeout 控制：-1 表示不启用超时
-		if transactionOptions.Timeout == -1 {
-			txnCtx = ctx
-			cancel = func() {} // 空的 cancel，避免 nil panic
-		} else {
-			txnCtx, cancel = context.WithTimeout(ctx, transactionOptions.Timeout)
-		}
-
-		// 确保 cancel() 每次都能被调用
-		func() {
-			defer cancel()
-
-			// perform operation
-			err = mongo.WithSession(txnCtx, session, func(sc context.Context) error {
-				// start transaction
-				if err := session.StartTransaction(asOptionListers(transactionOptions.withTransactionOptions)...); err != nil {
-					return err
-				}
-
-				// 防止panic
-				innerErr := func() (err error) {
-					defer func() {
-						if r := recover(); r != nil {
-							err = fmt.Errorf("panic in transaction function: %v", r)
-						}
-					}()
-					return fn(sc)
-				}()
-
-				if innerErr != nil {
-					// roolback
-					_ = session.AbortTransaction(sc)
-					return innerErr
-				}
-
-				return session.CommitTransaction(sc)
-			})
-		}()
-		if err == nil {
-			return nil
-		}
-
-		// transient error: retry
-		if isTransientTransactionError(err) {
-			log.Printf("Transaction attempt %d/%d failed: %v", attempt+1, maxRetry, err)
-			continue
-		}
-
-		return err
-	}
-
-	return fmt.Errorf("RunTransactionWithContext: transaction failed after %d attempts, last error: %w", maxRetry, err)
+	_, err := RunTransactionWithResultWithContext(ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, opts...)
+	return err
 }
 
 func RunTransactionWithResult[T any](fn func(context.Context) (T, error), opts ...RunTransactionOption) (T, error) {
 	return RunTransactionWithResultWithContext(context.Background(), fn, opts...)
 }
 
-// run fn with mongodb transaction
+// run fn with mongodb transaction,
+// the whole transaction is retried on TransientTransactionError and the commit is retried on UnknownTransactionCommitResult,
+// both are retried at most MaxRetry times with exponential backoff
 func RunTransactionWithResultWithContext[T any](ctx context.Context,
 	fn func(context.Context) (T, error),
-	opts ...RunTransactionOption) (T, error) {
+	opts ...RunTransactionOption) (_ T, err error) {
 
 	var zero T
 	transactionOptions := newDefaultRunTransactionOptions()
 	for _, eachOpt := range opts {
 		eachOpt(transactionOptions)
 	}
-	maxRetry := transactionOptions.MaxRetry
-	if maxRetry <= 0 {
-		maxRetry = 3
+	if transactionOptions.MaxRetry <= 0 {
+		transactionOptions.MaxRetry = 3
 	}
+	ctx, span := DefaultTracer().Start(ctx, "transaction", Attr(AttributeDbSystem, DbSystemMongodb))
+	defer endSpan(span, &err)
+
 	// client
-	c := GetClient(transactionOptions.ClientKey)
+	c, err := LookupClient(transactionOptions.ClientKey)
+	if err != nil {
+		return zero, err
+	}
+	action, err := resolveTransactionAction(ctx, c, transactionOptions.Propagation)
+	if err != nil {
+		return zero, err
+	}
+	if action == transactionActionNone {
+		return callTransactionFunc(ctx, fn)
+	}
+	if action == transactionActionJoin {
+		// the outermost call commits or aborts, it aborts if the joined call failed even if the error is handled
+		result, err := callTransactionFunc(ctx, fn)
+		if err != nil {
+			if state := transactionStateFromContext(ctx); state != nil {
+				state.setRollbackOnly(err)
+			}
+		}
+		return result, err
+	}
 
 	// start session
 	session, err := c.StartSession(asOptionListers(transactionOptions.withSessionOptions)...)
@@ -182,67 +178,108 @@ func RunTransactionWithResultWithContext[T any](ctx context.Context,
 	}
 	defer session.EndSession(context.Background())
 
-	for attempt := 0; attempt < transactionOptions.MaxRetry; attempt++ {
-		// 每次事务执行新建一个超时ctx
-		txnCtx, cancel := context.WithTimeout(ctx, transactionOptions.Timeout)
-		defer cancel()
-
-		var result T
-
-		// perform operation
-		err = mongo.WithSession(txnCtx, session, func(sc context.Context) error {
-			// start transaction
-			if err := session.StartTransaction(asOptionListers(transactionOptions.withTransactionOptions)...); err != nil {
-				return err
-			}
-
-			var innerErr error
-			result, innerErr = fn(sc)
-			if innerErr != nil {
-				// roolback
-				_ = session.AbortTransaction(sc)
-				return innerErr
-			}
-			if err := session.CommitTransaction(sc); err != nil {
-				return err
-			}
-			return nil
-		})
-
+	maxRetry := transactionOptions.MaxRetry
+	for attempt := 1; ; attempt++ {
+		span.SetAttributes(Attr(AttributeDbTransactionAttempt, attempt))
+		result, err := runTransactionAttempt(ctx, session, fn, transactionOptions)
 		if err == nil {
 			return result, nil
 		}
-
-		// 如果是瞬时事务错误，允许重试
-		if isTransientTransactionError(err) {
-			continue
+		// 只有瞬时事务错误才重试整个事务
+		if !hasErrorLabel(err, transientTransactionErrorLabel) {
+			return zero, err
+		}
+		if attempt >= maxRetry {
+			return zero, fmt.Errorf("transaction failed after %d attempts, last error: %w", maxRetry, err)
 		}
+		if err := transactionOptions.waitRetry(ctx, TransactionRetryPhaseTransaction, attempt, err); err != nil {
+			return zero, err
+		}
+	}
+}
 
-		return zero, err
+// run fn in a new transaction of session, the commit is retried on UnknownTransactionCommitResult
+func runTransactionAttempt[T any](ctx context.Context,
+	session *mongo.Session,
+	fn func(context.Context) (T, error),
+	transactionOptions *RunTransactionOptions) (T, error) {
+
+	// 每次事务执行新建一个超时ctx，-1 表示不启用超时
+	txnCtx, cancel := ctx, context.CancelFunc(func() {})
+	if transactionOptions.Timeout != -1 {
+		txnCtx, cancel = context.WithTimeout(ctx, transactionOptions.Timeout)
 	}
+	defer cancel()
 
-	return zero, fmt.Errorf("RunTransactionWithResultWithContext: transaction failed after %d attempts, last error: %w", maxRetry, err)
-}
+	var result T
+	err := mongo.WithSession(txnCtx, session, func(sc context.Context) error {
+		// start transaction
+		if err := session.StartTransaction(asOptionListers(transactionOptions.withTransactionOptions)...); err != nil {
+			return err
+		}
 
-// isTransientTransactionError 判断是否是瞬时事务错误
-func isTransientTransactionError(err error) bool {
-	var cmdErr mongo.CommandError
-	if errors.As(err, &cmdErr) {
-		for _, label := range cmdErr.Labels {
-			if label == "TransientTransactionError" {
-				return true
-			}
+		state := &transactionState{}
+		var innerErr error
+		result, innerErr = callTransactionFunc(withTransactionState(sc, state), fn)
+		if innerErr == nil {
+			innerErr = state.rollbackOnlyError()
+		}
+		if innerErr != nil {
+			// rollback
+			_ = session.AbortTransaction(sc)
+			return innerErr
 		}
-	}
 
-	var writeErr mongo.WriteException
-	if errors.As(err, &writeErr) {
-		for _, label := range writeErr.Labels {
-			if label == "TransientTransactionError" {
-				return true
+		for attempt := 1; ; attempt++ {
+			err := session.CommitTransaction(sc)
+			if err == nil || !hasErrorLabel(err, unknownTransactionCommitResultLabel) || attempt >= transactionOptions.MaxRetry {
+				return err
+			}
+			if waitErr := transactionOptions.waitRetry(sc, TransactionRetryPhaseCommit, attempt, err); waitErr != nil {
+				return err
 			}
 		}
+	})
+	return result, err
+}
+
+// invoke OnRetry and wait for the backoff of attempt, return the error of ctx if it's done
+func (o *RunTransactionOptions) waitRetry(ctx context.Context, phase string, attempt int, err error) error {
+	backoff := o.backoff(attempt)
+	if o.OnRetry != nil {
+		o.OnRetry(&TransactionRetryInfo{
+			Phase:    phase,
+			Attempt:  attempt,
+			MaxRetry: o.MaxRetry,
+			Err:      err,
+			Backoff:  backoff,
+		})
+	}
+	if backoff <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
+
+// exponential backoff with equal jitter, the result is between half and all of InitialBackoff*2^(attempt-1)
+func (o *RunTransactionOptions) backoff(attempt int) time.Duration {
+	return ExponentialBackoff(o.InitialBackoff, o.MaxBackoff, attempt)
+}
+
+const (
+	transientTransactionErrorLabel      = "TransientTransactionError"
+	unknownTransactionCommitResultLabel = "UnknownTransactionCommitResult"
+)
 
-	return false
+// 判断错误是否包含指定的label
+func hasErrorLabel(err error, label string) bool {
+	var labeledErr mongo.LabeledError
+	return errors.As(err, &labeledErr) && labeledErr.HasErrorLabel(label)
 }
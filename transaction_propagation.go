@@ -0,0 +1,163 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// how RunTransaction* behaves when ctx is already in a transaction
+type Propagation int
+
+const (
+	// join the transaction of ctx if it's started by the same client, otherwise start a new one,
+	// only the outermost call commits, and it aborts with ErrTransactionRollbackOnly if any joined call failed
+	PropagationRequired Propagation = iota
+	// always start a new session and transaction, which commits independently of the transaction of ctx
+	PropagationRequiresNew
+	// mongodb has no savepoint, so nested transaction joins the transaction of ctx as PropagationRequired
+	PropagationNested
+	// run fn without transaction, return ErrTransactionExists if ctx is in a transaction
+	PropagationNever
+)
+
+var (
+	// ctx is in a transaction while PropagationNever is required
+	ErrTransactionExists = errors.New("transaction already exists")
+	// a joined call failed or SetRollbackOnly is called, so the outermost call aborts the transaction
+	ErrTransactionRollbackOnly = errors.New("transaction is marked as rollback only")
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationRequired:
+		return "REQUIRED"
+	case PropagationRequiresNew:
+		return "REQUIRES_NEW"
+	case PropagationNested:
+		return "NESTED"
+	case PropagationNever:
+		return "NEVER"
+	default:
+		return fmt.Sprintf("Propagation(%d)", int(p))
+	}
+}
+
+// 设置事务的传播行为，默认为PropagationRequired
+func RunTransactionOptionWithPropagation(propagation Propagation) func(options *RunTransactionOptions) {
+	return func(runOptions *RunTransactionOptions) {
+		runOptions.Propagation = propagation
+	}
+}
+
+// is ctx in a transaction, such as the ctx passed to fn of RunTransaction*
+func InTransaction(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	session := mongo.SessionFromContext(ctx)
+	return session != nil && session.TransactionRunning()
+}
+
+// state of a transaction started by RunTransaction*, shared by the joined calls
+type transactionState struct {
+	lock sync.Mutex
+	// the cause of rollback only, nil if the transaction can be committed
+	rollbackCause error
+}
+
+type transactionStateKey struct{}
+
+func withTransactionState(ctx context.Context, state *transactionState) context.Context {
+	return context.WithValue(ctx, transactionStateKey{}, state)
+}
+
+func transactionStateFromContext(ctx context.Context) *transactionState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(transactionStateKey{}).(*transactionState)
+	return state
+}
+
+// mark the transaction as rollback only, the first cause is kept
+func (s *transactionState) setRollbackOnly(cause error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.rollbackCause == nil {
+		s.rollbackCause = cause
+	}
+}
+
+// return nil if the transaction can be committed
+func (s *transactionState) rollbackOnlyError() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.rollbackCause == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrTransactionRollbackOnly, s.rollbackCause)
+}
+
+// mark the transaction of ctx as rollback only, so that the outermost RunTransaction* aborts it
+// even if its fn returns nil, return false if ctx is not in a transaction started by RunTransaction*
+func SetRollbackOnly(ctx context.Context) bool {
+	state := transactionStateFromContext(ctx)
+	if state == nil || !InTransaction(ctx) {
+		return false
+	}
+	state.setRollbackOnly(errors.New("rollback only is set"))
+	return true
+}
+
+// is the transaction of ctx marked as rollback only
+func IsRollbackOnly(ctx context.Context) bool {
+	state := transactionStateFromContext(ctx)
+	return state != nil && InTransaction(ctx) && state.rollbackOnlyError() != nil
+}
+
+type transactionAction int
+
+const (
+	// start a new session and transaction
+	transactionActionStart transactionAction = iota
+	// call fn with ctx, the transaction of ctx is used
+	transactionActionJoin
+	// call fn with ctx without transaction
+	transactionActionNone
+)
+
+// decide how to run fn with the transaction of ctx and propagation
+func resolveTransactionAction(ctx context.Context, client *mongo.Client, propagation Propagation) (transactionAction, error) {
+	inTransaction := InTransaction(ctx)
+	switch propagation {
+	case PropagationRequiresNew:
+		return transactionActionStart, nil
+	case PropagationNever:
+		if inTransaction {
+			return transactionActionNone, ErrTransactionExists
+		}
+		return transactionActionNone, nil
+	default:
+		// a session cannot be used by another client, so start a new one in that case
+		if inTransaction && mongo.SessionFromContext(ctx).Client() == client {
+			return transactionActionJoin, nil
+		}
+		return transactionActionStart, nil
+	}
+}
+
+// call fn and convert panic to error
+func callTransactionFunc[T any](ctx context.Context, fn func(context.Context) (T, error)) (result T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in transaction function: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,65 @@
+package mongodbr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestRunTransactionOptionsBackoff(t *testing.T) {
+	cases := []struct {
+		name    string
+		initial time.Duration
+		max     time.Duration
+		attempt int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "no backoff", initial: 0, max: time.Second, attempt: 3},
+		{name: "first attempt", initial: 10 * time.Millisecond, max: time.Second, attempt: 1, wantMin: 5 * time.Millisecond, wantMax: 10 * time.Millisecond},
+		{name: "doubled", initial: 10 * time.Millisecond, max: time.Second, attempt: 3, wantMin: 20 * time.Millisecond, wantMax: 40 * time.Millisecond},
+		{name: "capped by max", initial: 10 * time.Millisecond, max: 25 * time.Millisecond, attempt: 3, wantMin: 12500 * time.Microsecond, wantMax: 25 * time.Millisecond},
+		{name: "many attempts do not overflow", initial: 10 * time.Millisecond, max: time.Second, attempt: 1000, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "without max", initial: 10 * time.Millisecond, attempt: 4, wantMin: 40 * time.Millisecond, wantMax: 80 * time.Millisecond},
+		{name: "without max at attempt 64", initial: time.Second, attempt: 64, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+		{name: "without max at attempt 1000", initial: time.Second, attempt: 1000, wantMin: math.MaxInt64 / 2, wantMax: math.MaxInt64},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := &RunTransactionOptions{InitialBackoff: c.initial, MaxBackoff: c.max}
+			// jitter is random, so check the bounds several times
+			for i := 0; i < 100; i++ {
+				if got := o.backoff(c.attempt); got < c.wantMin || got > c.wantMax {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", c.attempt, got, c.wantMin, c.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestHasErrorLabel(t *testing.T) {
+	labeled := mongo.CommandError{Labels: []string{transientTransactionErrorLabel}}
+	cases := []struct {
+		name  string
+		err   error
+		label string
+		want  bool
+	}{
+		{name: "nil", err: nil, label: transientTransactionErrorLabel},
+		{name: "not labeled", err: errors.New("failed"), label: transientTransactionErrorLabel},
+		{name: "labeled", err: labeled, label: transientTransactionErrorLabel, want: true},
+		{name: "wrapped", err: fmt.Errorf("commit: %w", labeled), label: transientTransactionErrorLabel, want: true},
+		{name: "other label", err: labeled, label: unknownTransactionCommitResultLabel},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := hasErrorLabel(c.err, c.label); got != c.want {
+				t.Errorf("hasErrorLabel() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
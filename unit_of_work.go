@@ -0,0 +1,633 @@
+package mongodbr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type unitOfWorkState int
+
+const (
+	// loaded and unchanged since the last snapshot, or modified
+	unitOfWorkStateClean unitOfWorkState = iota
+	// inserted on commit
+	unitOfWorkStateNew
+	// deleted on commit
+	unitOfWorkStateDeleted
+)
+
+// entity tracked by UnitOfWork
+type unitOfWorkEntry struct {
+	repository *RepositoryBase
+	item       interface{}
+	state      unitOfWorkState
+	// the document when the entity was loaded or last committed
+	snapshot bson.D
+}
+
+// UnitOfWork tracks entities loaded through repositories,
+// detects new, modified and deleted entities by comparing them with their snapshots,
+// and writes the changes with one BulkWrite per collection inside one transaction on Commit.
+// it's not safe to modify the tracked entities while committing
+type UnitOfWork struct {
+	lock               sync.Mutex
+	transactionOptions []RunTransactionOption
+	entries            []*unitOfWorkEntry
+	// tracked entries by item pointer
+	itemIndex map[interface{}]*unitOfWorkEntry
+	// tracked entries by repository and _id
+	idIndex map[unitOfWorkKey]*unitOfWorkEntry
+	// Commit is running
+	committing bool
+}
+
+type unitOfWorkKey struct {
+	repository *RepositoryBase
+	id         string
+}
+
+// new UnitOfWork, opts are used to run the transaction of Commit
+func NewUnitOfWork(opts ...RunTransactionOption) *UnitOfWork {
+	return &UnitOfWork{
+		transactionOptions: opts,
+		itemIndex:          make(map[interface{}]*unitOfWorkEntry),
+		idIndex:            make(map[unitOfWorkKey]*unitOfWorkEntry),
+	}
+}
+
+// track a loaded entity, item must be a pointer with _id,
+// its current state is the snapshot which changes are detected against
+func (u *UnitOfWork) Attach(repository *RepositoryBase, item interface{}) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	_, err := u.attach(repository, item)
+	return err
+}
+
+func (u *UnitOfWork) attach(repository *RepositoryBase, item interface{}) (*unitOfWorkEntry, error) {
+	if err := checkUnitOfWorkItem(repository, item); err != nil {
+		return nil, err
+	}
+	if entry, ok := u.itemIndex[item]; ok {
+		return entry, nil
+	}
+	snapshot, err := marshalDocument(repository.bsonRegistry(), item)
+	if err != nil {
+		return nil, err
+	}
+	key, err := newUnitOfWorkKey(repository, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := u.idIndex[key]; ok {
+		return nil, fmt.Errorf("another entity with _id %s of %s is already tracked", key.id, repository.GetName())
+	}
+	entry := &unitOfWorkEntry{
+		repository: repository,
+		item:       item,
+		state:      unitOfWorkStateClean,
+		snapshot:   snapshot,
+	}
+	u.add(entry, key)
+	return entry, nil
+}
+
+// track a new entity which is inserted on Commit, item must be a pointer
+func (u *UnitOfWork) RegisterNew(repository *RepositoryBase, item interface{}) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if err := checkUnitOfWorkItem(repository, item); err != nil {
+		return err
+	}
+	if entry, ok := u.itemIndex[item]; ok {
+		if entry.state == unitOfWorkStateNew {
+			return nil
+		}
+		return fmt.Errorf("entity of %s is already tracked", repository.GetName())
+	}
+	// _id may be assigned on commit, so it's indexed after inserted
+	u.add(&unitOfWorkEntry{
+		repository: repository,
+		item:       item,
+		state:      unitOfWorkStateNew,
+	}, unitOfWorkKey{})
+	return nil
+}
+
+// mark entity as deleted, it's deleted or soft deleted on Commit,
+// a new entity is just not inserted, an untracked entity is attached first
+func (u *UnitOfWork) RegisterDeleted(repository *RepositoryBase, item interface{}) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if entry, ok := u.itemIndex[item]; ok && entry.state == unitOfWorkStateNew {
+		u.remove(entry)
+		return nil
+	}
+	entry, err := u.attach(repository, item)
+	if err != nil {
+		return err
+	}
+	entry.state = unitOfWorkStateDeleted
+	return nil
+}
+
+// stop tracking entity
+func (u *UnitOfWork) Detach(item interface{}) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if entry, ok := u.itemIndex[item]; ok {
+		u.remove(entry)
+	}
+}
+
+// stop tracking all entities
+func (u *UnitOfWork) Clear() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.entries = nil
+	u.itemIndex = make(map[interface{}]*unitOfWorkEntry)
+	u.idIndex = make(map[unitOfWorkKey]*unitOfWorkEntry)
+}
+
+// get the tracked entity by repository and _id
+func (u *UnitOfWork) tracked(repository *RepositoryBase, id interface{}) (interface{}, bool) {
+	key, err := newUnitOfWorkKey(repository, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return nil, false
+	}
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	entry, ok := u.idIndex[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.item, true
+}
+
+func (u *UnitOfWork) add(entry *unitOfWorkEntry, key unitOfWorkKey) {
+	u.entries = append(u.entries, entry)
+	u.itemIndex[entry.item] = entry
+	if key.repository != nil {
+		u.idIndex[key] = entry
+	}
+}
+
+func (u *UnitOfWork) remove(entry *unitOfWorkEntry) {
+	delete(u.itemIndex, entry.item)
+	for key, each := range u.idIndex {
+		if each == entry {
+			delete(u.idIndex, key)
+		}
+	}
+	for index, each := range u.entries {
+		if each == entry {
+			u.entries = append(u.entries[:index], u.entries[index+1:]...)
+			break
+		}
+	}
+}
+
+// #region commit
+
+// one tracked entity to commit, state and snapshot are copied when Commit starts
+type unitOfWorkChange struct {
+	entry    *unitOfWorkEntry
+	state    unitOfWorkState
+	snapshot bson.D
+}
+
+// changes of one collection
+type unitOfWorkBatch struct {
+	repository *RepositoryBase
+	registry   *bson.Registry
+	models     []mongo.WriteModel
+	// changes and filters of models, used by after hooks
+	changes []*unitOfWorkChange
+	filters []interface{}
+	// documents of modified entries after commit
+	documents []bson.D
+	// expected matched count of update models and deleted count of delete models
+	expectedMatched int64
+	expectedDeleted int64
+	// versions to restore if commit failed
+	versions map[IVersionedEntity]int64
+}
+
+// write all changes inside one transaction, before hooks are invoked before the transaction,
+// after hooks are invoked after committed, ErrConcurrencyConflict is returned when any
+// modified or deleted document does not match, such as its version has been changed by others
+// or it has been soft deleted.
+// uow is not locked while hooks and the transaction run, so hooks can track entities, which are committed next time
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	changeList, err := u.beginCommit()
+	if err != nil {
+		return err
+	}
+	defer u.endCommit()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	batchList, err := prepareUnitOfWork(ctx, changeList)
+	restoreVersions := func() {
+		for _, eachBatch := range batchList {
+			for entity, version := range eachBatch.versions {
+				entity.SetVersion(version)
+			}
+		}
+	}
+	if err != nil {
+		restoreVersions()
+		return err
+	}
+	if len(batchList) <= 0 {
+		return nil
+	}
+
+	err = RunTransactionWithContext(ctx, func(sc context.Context) error {
+		for _, eachBatch := range batchList {
+			if err := eachBatch.write(sc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, u.transactionOptions...)
+	if err != nil {
+		restoreVersions()
+		return err
+	}
+	u.committed(batchList)
+	return afterUnitOfWorkCommitted(ctx, batchList)
+}
+
+// copy the tracked changes, return ErrUnitOfWorkCommitting if another Commit is running
+func (u *UnitOfWork) beginCommit() ([]*unitOfWorkChange, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.committing {
+		return nil, ErrUnitOfWorkCommitting
+	}
+	u.committing = true
+	changeList := make([]*unitOfWorkChange, 0, len(u.entries))
+	for _, eachEntry := range u.entries {
+		changeList = append(changeList, &unitOfWorkChange{
+			entry:    eachEntry,
+			state:    eachEntry.state,
+			snapshot: eachEntry.snapshot,
+		})
+	}
+	return changeList, nil
+}
+
+func (u *UnitOfWork) endCommit() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.committing = false
+}
+
+// invoke before hooks and build write models, grouped by collection in the order of tracking
+func prepareUnitOfWork(ctx context.Context, changeList []*unitOfWorkChange) ([]*unitOfWorkBatch, error) {
+	batchList := make([]*unitOfWorkBatch, 0)
+	batchIndex := make(map[*RepositoryBase]*unitOfWorkBatch)
+	for _, eachChange := range changeList {
+		repository := eachChange.entry.repository
+		batch, ok := batchIndex[repository]
+		if !ok {
+			batch = &unitOfWorkBatch{
+				repository: repository,
+				registry:   repository.bsonRegistry(),
+				versions:   make(map[IVersionedEntity]int64),
+			}
+			batchIndex[repository] = batch
+			batchList = append(batchList, batch)
+		}
+		if err := batch.add(ctx, eachChange); err != nil {
+			return batchList, err
+		}
+	}
+	// ignore collections without changes
+	result := batchList[:0]
+	for _, eachBatch := range batchList {
+		if len(eachBatch.models) > 0 {
+			result = append(result, eachBatch)
+		}
+	}
+	return result, nil
+}
+
+func (b *unitOfWorkBatch) add(ctx context.Context, change *unitOfWorkChange) error {
+	configuration := b.repository.configuration
+	item := change.entry.item
+	switch change.state {
+	case unitOfWorkStateNew:
+		if err := configuration.hookBeforeCreate(ctx, item); err != nil {
+			return err
+		}
+		if err := configuration.validate(item); err != nil {
+			return err
+		}
+		document, err := marshalDocument(b.registry, item)
+		if err != nil {
+			return err
+		}
+		// _id must be assigned before inserted, so that the entity can be tracked after committed
+		if _, err := newUnitOfWorkKey(b.repository, document); err != nil {
+			return err
+		}
+		b.append(change, nil, document, mongo.NewInsertOneModel().SetDocument(item))
+	case unitOfWorkStateDeleted:
+		filter := b.entityFilter(change)
+		if err := configuration.hookBeforeDelete(ctx, filter); err != nil {
+			return err
+		}
+		// ignore the document which has been soft deleted
+		modelFilter := configuration.notDeletedFilter(ctx, filter)
+		if configuration.softDelete {
+			b.expectedMatched++
+			b.append(change, filter, nil, mongo.NewUpdateOneModel().
+				SetFilter(modelFilter).
+				SetUpdate(b.repository.softDeleteUpdate(ctx, "")))
+		} else {
+			b.expectedDeleted++
+			b.append(change, filter, nil, mongo.NewDeleteOneModel().SetFilter(modelFilter))
+		}
+	default:
+		current, err := marshalDocument(b.registry, item)
+		if err != nil {
+			return err
+		}
+		if set, unset := diffDocument(change.snapshot, current, ""); len(set) <= 0 && len(unset) <= 0 {
+			return nil
+		}
+		idFilter := bson.M{"_id": documentId(change.snapshot)}
+		if err := configuration.hookBeforeUpdate(ctx, idFilter, item); err != nil {
+			return err
+		}
+		if err := configuration.validate(item); err != nil {
+			return err
+		}
+		// the version is incremented after hooks, so that it's compared with the snapshot
+		filter := configuration.notDeletedFilter(ctx, b.entityFilter(change))
+		if versionedEntity, ok := item.(IVersionedEntity); ok {
+			b.versions[versionedEntity] = versionedEntity.GetVersion()
+			versionedEntity.SetVersion(versionedEntity.GetVersion() + 1)
+		}
+		current, err = marshalDocument(b.registry, item)
+		if err != nil {
+			return err
+		}
+		set, unset := diffDocument(change.snapshot, current, "")
+		update := bson.D{}
+		if len(set) > 0 {
+			update = append(update, bson.E{Key: "$set", Value: set})
+		}
+		if len(unset) > 0 {
+			update = append(update, bson.E{Key: "$unset", Value: unset})
+		}
+		b.expectedMatched++
+		b.append(change, idFilter, current, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update))
+	}
+	return nil
+}
+
+func (b *unitOfWorkBatch) append(change *unitOfWorkChange, filter interface{}, document bson.D, model mongo.WriteModel) {
+	b.changes = append(b.changes, change)
+	b.filters = append(b.filters, filter)
+	b.documents = append(b.documents, document)
+	b.models = append(b.models, model)
+}
+
+// filter by _id of snapshot, and by the expected version if entity implements IVersionedEntity
+func (b *unitOfWorkBatch) entityFilter(change *unitOfWorkChange) bson.D {
+	filter := bson.D{{Key: "_id", Value: documentId(change.snapshot)}}
+	if versionedEntity, ok := change.entry.item.(IVersionedEntity); ok {
+		filter = append(filter, VersionFilter(versionedEntity.GetVersion()))
+	}
+	return filter
+}
+
+func (b *unitOfWorkBatch) write(ctx context.Context) error {
+	ctx, span := b.repository.startSpan(ctx, "bulkWrite", nil)
+	res, err := b.repository.collection().BulkWrite(ctx, b.models, options.BulkWrite().SetOrdered(true))
+	span.End(err)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount < b.expectedMatched || res.DeletedCount < b.expectedDeleted {
+		return fmt.Errorf("%w: %d of %d documents matched, %d of %d documents deleted in %s",
+			ErrConcurrencyConflict, res.MatchedCount, b.expectedMatched, res.DeletedCount, b.expectedDeleted, b.repository.GetName())
+	}
+	return nil
+}
+
+// refresh snapshots and states, the entities detached while committing are ignored
+func (u *UnitOfWork) committed(batchList []*unitOfWorkBatch) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for _, eachBatch := range batchList {
+		for index, eachChange := range eachBatch.changes {
+			entry := eachChange.entry
+			if u.itemIndex[entry.item] != entry {
+				continue
+			}
+			switch eachChange.state {
+			case unitOfWorkStateNew:
+				if entry.state == unitOfWorkStateNew {
+					entry.state = unitOfWorkStateClean
+				}
+				entry.snapshot = eachBatch.documents[index]
+				if key, err := newUnitOfWorkKey(entry.repository, entry.snapshot); err == nil {
+					u.idIndex[key] = entry
+				}
+			case unitOfWorkStateDeleted:
+				u.remove(entry)
+			default:
+				entry.snapshot = eachBatch.documents[index]
+			}
+		}
+	}
+}
+
+// invoke after hooks, the errors of hooks are returned together
+func afterUnitOfWorkCommitted(ctx context.Context, batchList []*unitOfWorkBatch) error {
+	errList := make([]error, 0)
+	for _, eachBatch := range batchList {
+		configuration := eachBatch.repository.configuration
+		for index, eachChange := range eachBatch.changes {
+			var err error
+			switch eachChange.state {
+			case unitOfWorkStateNew:
+				err = configuration.hookAfterCreate(ctx, eachChange.entry.item)
+			case unitOfWorkStateDeleted:
+				err = configuration.hookAfterDelete(ctx, eachBatch.filters[index], nil)
+			default:
+				err = configuration.hookAfterUpdate(ctx, eachBatch.filters[index], eachChange.entry.item)
+			}
+			if err != nil {
+				errList = append(errList, err)
+			}
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// #endregion
+
+// #region tracked find
+
+// find T by _id and track it with uow, the tracked instance is returned if it has been tracked,
+// return nil if not found
+func FindTrackedById[T any](uow *UnitOfWork, repository *RepositoryBase, id interface{}, opts ...MongodbrFindOneOption) (*T, error) {
+	if item, ok := uow.tracked(repository, id); ok {
+		if result, ok := item.(*T); ok {
+			return result, nil
+		}
+		return nil, ErrInvalidType
+	}
+	result, err := FindOneTByFilter[T](repository, bson.M{"_id": id}, opts...)
+	if err != nil || result == nil {
+		return result, err
+	}
+	if err := uow.Attach(repository, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// find T list by filter and track them with uow, the tracked instances are returned for tracked documents
+func FindTracked[T any](uow *UnitOfWork, repository *RepositoryBase, filter interface{}, opts ...MongodbrFindOption) ([]*T, error) {
+	list, err := FindTByFilter[T](repository, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for index, eachItem := range list {
+		document, err := marshalDocument(repository.bsonRegistry(), eachItem)
+		if err != nil {
+			return nil, err
+		}
+		if item, ok := uow.tracked(repository, documentId(document)); ok {
+			tracked, ok := item.(*T)
+			if !ok {
+				return nil, ErrInvalidType
+			}
+			list[index] = tracked
+			continue
+		}
+		if err := uow.Attach(repository, eachItem); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// #endregion
+
+// #region document diff
+
+func checkUnitOfWorkItem(repository *RepositoryBase, item interface{}) error {
+	if repository == nil {
+		return errors.New("repository cannot be nil")
+	}
+	if item == nil {
+		return ErrNilItem
+	}
+	if v := reflect.ValueOf(item); v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("%w: entity must be a non nil pointer, but got %T", ErrInvalidType, item)
+	}
+	return nil
+}
+
+func newUnitOfWorkKey(repository *RepositoryBase, document bson.D) (unitOfWorkKey, error) {
+	id := documentId(document)
+	if id == nil {
+		return unitOfWorkKey{}, fmt.Errorf("%w: entity of %s has no _id", ErrInvalidType, repository.GetName())
+	}
+	// _id may not be comparable, such as bson.Binary, so it's compared by its bson bytes
+	data, err := bson.Marshal(bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return unitOfWorkKey{}, err
+	}
+	return unitOfWorkKey{repository: repository, id: string(data)}, nil
+}
+
+func documentId(document bson.D) interface{} {
+	for _, eachElement := range document {
+		if eachElement.Key == "_id" {
+			return eachElement.Value
+		}
+	}
+	return nil
+}
+
+// marshal item with registry and decode it as bson.D, embedded documents are bson.D and arrays are bson.A
+func marshalDocument(registry *bson.Registry, item interface{}) (bson.D, error) {
+	buf := new(bytes.Buffer)
+	encoder := bson.NewEncoder(bson.NewDocumentWriter(buf))
+	encoder.SetRegistry(registry)
+	if err := encoder.Encode(item); err != nil {
+		return nil, err
+	}
+	var document bson.D
+	if err := bson.Unmarshal(buf.Bytes(), &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// compute the $set and $unset documents which change oldDocument to newDocument,
+// embedded documents are compared field by field with dotted paths, arrays and other values are set as a whole
+func diffDocument(oldDocument bson.D, newDocument bson.D, prefix string) (set bson.D, unset bson.D) {
+	oldValues := make(map[string]interface{}, len(oldDocument))
+	for _, eachElement := range oldDocument {
+		oldValues[eachElement.Key] = eachElement.Value
+	}
+	newKeys := make(map[string]struct{}, len(newDocument))
+	for _, eachElement := range newDocument {
+		newKeys[eachElement.Key] = struct{}{}
+		if len(prefix) <= 0 && eachElement.Key == "_id" {
+			continue
+		}
+		path := prefix + eachElement.Key
+		oldValue, ok := oldValues[eachElement.Key]
+		if !ok {
+			set = append(set, bson.E{Key: path, Value: eachElement.Value})
+			continue
+		}
+		oldEmbedded, oldIsDocument := oldValue.(bson.D)
+		newEmbedded, newIsDocument := eachElement.Value.(bson.D)
+		if oldIsDocument && newIsDocument && len(newEmbedded) > 0 {
+			embeddedSet, embeddedUnset := diffDocument(oldEmbedded, newEmbedded, path+".")
+			set = append(set, embeddedSet...)
+			unset = append(unset, embeddedUnset...)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, eachElement.Value) {
+			set = append(set, bson.E{Key: path, Value: eachElement.Value})
+		}
+	}
+	for _, eachElement := range oldDocument {
+		if _, ok := newKeys[eachElement.Key]; !ok {
+			unset = append(unset, bson.E{Key: prefix + eachElement.Key, Value: ""})
+		}
+	}
+	return set, unset
+}
+
+// #endregion
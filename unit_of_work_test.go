@@ -0,0 +1,127 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDiffDocument(t *testing.T) {
+	cases := []struct {
+		name      string
+		old       bson.D
+		new       bson.D
+		wantSet   bson.D
+		wantUnset bson.D
+	}{
+		{
+			name: "unchanged",
+			old:  bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}},
+			new:  bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}},
+		},
+		{
+			name:    "changed value",
+			old:     bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}, {Key: "age", Value: int32(1)}},
+			new:     bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "b"}, {Key: "age", Value: int32(1)}},
+			wantSet: bson.D{{Key: "name", Value: "b"}},
+		},
+		{
+			name:    "added field",
+			old:     bson.D{{Key: "_id", Value: int32(1)}},
+			new:     bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}},
+			wantSet: bson.D{{Key: "name", Value: "a"}},
+		},
+		{
+			name:      "removed field",
+			old:       bson.D{{Key: "_id", Value: int32(1)}, {Key: "name", Value: "a"}},
+			new:       bson.D{{Key: "_id", Value: int32(1)}},
+			wantUnset: bson.D{{Key: "name", Value: ""}},
+		},
+		{
+			name: "_id is ignored",
+			old:  bson.D{{Key: "_id", Value: int32(1)}},
+			new:  bson.D{{Key: "_id", Value: int32(2)}},
+		},
+		{
+			name: "embedded document by dotted path",
+			old: bson.D{{Key: "addr", Value: bson.D{
+				{Key: "city", Value: "a"},
+				{Key: "zip", Value: "1"},
+				{Key: "_id", Value: int32(1)},
+			}}},
+			new: bson.D{{Key: "addr", Value: bson.D{
+				{Key: "city", Value: "b"},
+				{Key: "_id", Value: int32(2)},
+			}}},
+			wantSet:   bson.D{{Key: "addr.city", Value: "b"}, {Key: "addr._id", Value: int32(2)}},
+			wantUnset: bson.D{{Key: "addr.zip", Value: ""}},
+		},
+		{
+			name:    "empty embedded document is set as a whole",
+			old:     bson.D{{Key: "addr", Value: bson.D{{Key: "city", Value: "a"}}}},
+			new:     bson.D{{Key: "addr", Value: bson.D{}}},
+			wantSet: bson.D{{Key: "addr", Value: bson.D{}}},
+		},
+		{
+			name:    "document replaces value",
+			old:     bson.D{{Key: "addr", Value: "a"}},
+			new:     bson.D{{Key: "addr", Value: bson.D{{Key: "city", Value: "a"}}}},
+			wantSet: bson.D{{Key: "addr", Value: bson.D{{Key: "city", Value: "a"}}}},
+		},
+		{
+			name:    "array is set as a whole",
+			old:     bson.D{{Key: "tags", Value: bson.A{"a"}}},
+			new:     bson.D{{Key: "tags", Value: bson.A{"a", "b"}}},
+			wantSet: bson.D{{Key: "tags", Value: bson.A{"a", "b"}}},
+		},
+		{
+			name: "equal array",
+			old:  bson.D{{Key: "tags", Value: bson.A{"a", bson.D{{Key: "k", Value: "v"}}}}},
+			new:  bson.D{{Key: "tags", Value: bson.A{"a", bson.D{{Key: "k", Value: "v"}}}}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			set, unset := diffDocument(c.old, c.new, "")
+			if !reflect.DeepEqual(set, c.wantSet) {
+				t.Errorf("set = %v, want %v", set, c.wantSet)
+			}
+			if !reflect.DeepEqual(unset, c.wantUnset) {
+				t.Errorf("unset = %v, want %v", unset, c.wantUnset)
+			}
+		})
+	}
+}
+
+func TestMarshalDocument(t *testing.T) {
+	type address struct {
+		City string `bson:"city"`
+	}
+	type person struct {
+		Id      bson.ObjectID `bson:"_id"`
+		Name    string        `bson:"name,omitempty"`
+		Address address       `bson:"address"`
+		Tags    []string      `bson:"tags"`
+	}
+	id := bson.NewObjectID()
+	document, err := marshalDocument(_keyRegistry, &person{
+		Id:      id,
+		Address: address{City: "a"},
+		Tags:    []string{"x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "a"}}},
+		{Key: "tags", Value: bson.A{"x"}},
+	}
+	if !reflect.DeepEqual(document, want) {
+		t.Errorf("marshalDocument() = %v, want %v", document, want)
+	}
+	if got := documentId(document); got != id {
+		t.Errorf("documentId() = %v, want %v", got, id)
+	}
+}
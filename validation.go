@@ -1,14 +1,434 @@
 package mongodbr
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// struct tag name of validation rules
+	ValidationTagName = "validate"
+)
+
+var (
+	ErrValidation = errors.New("validation failed")
+)
+
 type IValidation interface {
 	Validate() error
 }
 
-// validate object if object implement IValidation interface
+// validate object with the rules of struct tags, then with IValidation if object implements it,
+// supported rules, separated by comma:
+//
+//	required      value cannot be zero, nil or empty
+//	min=<n>       minimum of number, or minimum length of string,slice and map
+//	max=<n>       maximum of number, or maximum length of string,slice and map
+//	len=<n>       exact length of string,slice,array and map
+//	enum=<a|b|c>  value must be one of the listed values
+//	regex=<expr>  string must match expr, it must be the last rule since expr may contain comma
+//
+// e.g.
+//
+//	Name   string `bson:"name" validate:"required,max=64"`
+//	Status string `bson:"status" validate:"enum=draft|published"`
+//	Code   string `bson:"code" validate:"regex=^[A-Z]{2,4}$"`
+//
+// nested structs are validated too, the rules are skipped for nil pointer unless required
 func Validate(v interface{}) error {
+	if err := ValidateStruct(v); err != nil {
+		return err
+	}
 	validation, ok := v.(IValidation)
 	if !ok || validation == nil {
 		return nil
 	}
 	return validation.Validate()
 }
+
+// validate every item of itemList, errors of all items are aggregated into one *MultiValidationError
+func ValidateMany(itemList []interface{}) error {
+	multiError := &MultiValidationError{}
+	for index, eachItem := range itemList {
+		if err := Validate(eachItem); err != nil {
+			multiError.Errors = append(multiError.Errors, &ItemValidationError{
+				Index: index,
+				Err:   err,
+			})
+		}
+	}
+	if len(multiError.Errors) > 0 {
+		return multiError
+	}
+	return nil
+}
+
+// validate object with the rules of struct tags only, return ValidationErrors if any rule failed
+func ValidateStruct(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	errorList := make(ValidationErrors, 0)
+	if err := validateStructValue(value, "", &errorList); err != nil {
+		return err
+	}
+	if len(errorList) > 0 {
+		return errorList
+	}
+	return nil
+}
+
+// #region errors
+
+// one failed rule of field
+type FieldError struct {
+	// path of field in bson document, such as address.city or items.0.name
+	Field string
+	Rule  string
+	// the value of rule, such as 10 of max=10
+	Param   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// failed rules of one object
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	messageList := make([]string, 0, len(e))
+	for _, eachError := range e {
+		messageList = append(messageList, eachError.Error())
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation.Error(), strings.Join(messageList, "; "))
+}
+
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// validation error of the item at Index
+type ItemValidationError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemValidationError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validation errors of many items, returned by CreateMany
+type MultiValidationError struct {
+	Errors []*ItemValidationError
+}
+
+func (e *MultiValidationError) Error() string {
+	messageList := make([]string, 0, len(e.Errors))
+	for _, eachError := range e.Errors {
+		messageList = append(messageList, eachError.Error())
+	}
+	return strings.Join(messageList, "\n")
+}
+
+func (e *MultiValidationError) Unwrap() []error {
+	errorList := make([]error, 0, len(e.Errors))
+	for _, eachError := range e.Errors {
+		errorList = append(errorList, eachError)
+	}
+	return errorList
+}
+
+func (e *MultiValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// #endregion
+
+// enable or disable validation before Create,CreateMany,Replace,FindOneAndUpdate and BulkWriteEntityList,
+// validation is enabled by default
+func WithValidation(enabled bool) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.disableValidation = !enabled
+	}
+}
+
+func (c *Configuration) validate(v interface{}) error {
+	if c != nil && c.disableValidation {
+		return nil
+	}
+	return Validate(v)
+}
+
+func (c *Configuration) validateMany(itemList []interface{}) error {
+	if c != nil && c.disableValidation {
+		return nil
+	}
+	return ValidateMany(itemList)
+}
+
+// #region struct tag rules
+
+type validationRule struct {
+	name  string
+	param string
+	// parsed param
+	number float64
+	enum   []string
+	regex  *regexp.Regexp
+}
+
+type validationField struct {
+	index    int
+	name     string
+	inline   bool
+	ruleList []*validationRule
+}
+
+var _validationFieldCache sync.Map
+
+var _timeType = reflect.TypeOf(time.Time{})
+
+func validateStructValue(value reflect.Value, prefix string, errorList *ValidationErrors) error {
+	fieldList, err := getValidationFields(value.Type())
+	if err != nil {
+		return err
+	}
+	for _, eachField := range fieldList {
+		fieldValue := value.Field(eachField.index)
+		path := prefix + eachField.name
+		if eachField.inline {
+			path = strings.TrimSuffix(prefix, ".")
+		}
+		for _, eachRule := range eachField.ruleList {
+			if fieldError := checkValidationRule(fieldValue, eachRule); fieldError != nil {
+				fieldError.Field = path
+				*errorList = append(*errorList, fieldError)
+			}
+		}
+		if err := validateNestedValue(fieldValue, path, errorList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate struct, pointer to struct and slice of struct
+func validateNestedValue(value reflect.Value, path string, errorList *ValidationErrors) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	subPrefix := path + "."
+	if len(path) <= 0 {
+		subPrefix = ""
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == _timeType {
+			return nil
+		}
+		return validateStructValue(value, subPrefix, errorList)
+	case reflect.Slice, reflect.Array:
+		elemType := value.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		if elemType.Kind() != reflect.Struct && elemType.Kind() != reflect.Interface {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := validateNestedValue(value.Index(i), subPrefix+strconv.Itoa(i), errorList); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parse and cache the rules of struct fields
+func getValidationFields(t reflect.Type) ([]*validationField, error) {
+	if v, ok := _validationFieldCache.Load(t); ok {
+		return v.([]*validationField), nil
+	}
+	fieldList := make([]*validationField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !isBsonField(field) {
+			continue
+		}
+		fieldName, inline, skip := bsonFieldName(field)
+		if skip {
+			continue
+		}
+		ruleList, err := parseValidationTag(field.Tag.Get(ValidationTagName))
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag of field %s.%s: %w", t.Name(), field.Name, err)
+		}
+		fieldList = append(fieldList, &validationField{
+			index:    i,
+			name:     fieldName,
+			inline:   inline,
+			ruleList: ruleList,
+		})
+	}
+	_validationFieldCache.Store(t, fieldList)
+	return fieldList, nil
+}
+
+func parseValidationTag(tag string) ([]*validationRule, error) {
+	ruleList := make([]*validationRule, 0)
+	for len(tag) > 0 {
+		var part string
+		tag = strings.TrimLeft(tag, " ")
+		if strings.HasPrefix(tag, "regex=") {
+			// regex is the last rule
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if len(name) <= 0 {
+			continue
+		}
+		rule := &validationRule{name: name, param: param}
+		switch name {
+		case "required":
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %s", name, param)
+			}
+			rule.number = n
+		case "enum":
+			rule.enum = strings.Split(param, "|")
+		case "regex":
+			regex, err := regexp.Compile(param)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %s: %w", param, err)
+			}
+			rule.regex = regex
+		default:
+			return nil, fmt.Errorf("unknown validate rule %s", name)
+		}
+		ruleList = append(ruleList, rule)
+	}
+	return ruleList, nil
+}
+
+// check value with rule, return nil if passed
+func checkValidationRule(value reflect.Value, rule *validationRule) *FieldError {
+	if rule.name == "required" {
+		if isEmptyValue(value) {
+			return newFieldError(rule, "is required")
+		}
+		return nil
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch rule.name {
+	case "min", "max":
+		n, isLength, ok := validationNumberOf(value)
+		if !ok {
+			return nil
+		}
+		if rule.name == "min" && n < rule.number {
+			if isLength {
+				return newFieldError(rule, fmt.Sprintf("length must be at least %s", rule.param))
+			}
+			return newFieldError(rule, fmt.Sprintf("must be at least %s", rule.param))
+		}
+		if rule.name == "max" && n > rule.number {
+			if isLength {
+				return newFieldError(rule, fmt.Sprintf("length must be at most %s", rule.param))
+			}
+			return newFieldError(rule, fmt.Sprintf("must be at most %s", rule.param))
+		}
+	case "len":
+		n, isLength, ok := validationNumberOf(value)
+		if ok && isLength && n != rule.number {
+			return newFieldError(rule, fmt.Sprintf("length must be %s", rule.param))
+		}
+	case "enum":
+		// value of the fields of embedded unexported struct cannot be converted to interface
+		s := fmt.Sprint(value)
+		for _, eachValue := range rule.enum {
+			if s == eachValue {
+				return nil
+			}
+		}
+		return newFieldError(rule, fmt.Sprintf("must be one of %s", strings.Join(rule.enum, ",")))
+	case "regex":
+		if value.Kind() != reflect.String {
+			return nil
+		}
+		if !rule.regex.MatchString(value.String()) {
+			return newFieldError(rule, fmt.Sprintf("must match %s", rule.param))
+		}
+	}
+	return nil
+}
+
+func newFieldError(rule *validationRule, message string) *FieldError {
+	return &FieldError{
+		Rule:    rule.name,
+		Param:   rule.param,
+		Message: message,
+	}
+}
+
+// number of value, or length of string,slice,array and map
+func validationNumberOf(value reflect.Value) (n float64, isLength bool, ok bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// #endregion
@@ -0,0 +1,235 @@
+package mongodbr
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validationStatus string
+
+func (s validationStatus) String() string {
+	return "status-" + string(s)
+}
+
+type validationTenant struct {
+	TenantId string `bson:"tenantId" validate:"required"`
+}
+
+type validationLine struct {
+	Name string `bson:"name" validate:"required,max=4"`
+	Qty  int    `bson:"qty" validate:"min=1"`
+}
+
+type validationOrder struct {
+	validationTenant `bson:",inline"`
+
+	Code   string            `bson:"code" validate:"max=6, regex=^[A-Z]{2,4}-[0-9]{1,2}$"`
+	Status string            `bson:"status" validate:"enum=draft|published"`
+	Level  int               `bson:"level" validate:"enum=1|2|3"`
+	Kind   validationStatus  `bson:"kind" validate:"enum=status-a"`
+	Score  float64           `bson:"score" validate:"min=0,max=100"`
+	Tags   []string          `bson:"tags" validate:"min=1,max=2"`
+	Note   *string           `bson:"note" validate:"min=2"`
+	Items  []validationLine  `bson:"items"`
+	Lines  []*validationLine `bson:"lines"`
+	Extra  interface{}       `bson:"extra"`
+	Hidden string            `bson:"-" validate:"required"`
+}
+
+func validOrder() *validationOrder {
+	return &validationOrder{
+		validationTenant: validationTenant{TenantId: "t1"},
+		Code:             "AB-1",
+		Status:           "draft",
+		Level:            2,
+		Kind:             "a",
+		Score:            100,
+		Tags:             []string{"a"},
+		Items:            []validationLine{{Name: "a", Qty: 1}},
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	short := "a"
+	cases := []struct {
+		name   string
+		modify func(o *validationOrder)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(o *validationOrder) {},
+		},
+		{
+			name:   "required field of embedded unexported struct",
+			modify: func(o *validationOrder) { o.TenantId = "" },
+			want:   []string{"tenantId required"},
+		},
+		{
+			name:   "regex with comma",
+			modify: func(o *validationOrder) { o.Code = "A-1" },
+			want:   []string{"code regex"},
+		},
+		{
+			name:   "max length of string counts runes",
+			modify: func(o *validationOrder) { o.Code = "ABCD-12" },
+			want:   []string{"code max"},
+		},
+		{
+			name:   "enum of string",
+			modify: func(o *validationOrder) { o.Status = "deleted" },
+			want:   []string{"status enum"},
+		},
+		{
+			name:   "enum of number",
+			modify: func(o *validationOrder) { o.Level = 4 },
+			want:   []string{"level enum"},
+		},
+		{
+			name:   "enum of Stringer",
+			modify: func(o *validationOrder) { o.Kind = "b" },
+			want:   []string{"kind enum"},
+		},
+		{
+			name:   "min and max of number",
+			modify: func(o *validationOrder) { o.Score = 100.5 },
+			want:   []string{"score max"},
+		},
+		{
+			name:   "min and max of slice length",
+			modify: func(o *validationOrder) { o.Tags = []string{"a", "b", "c"} },
+			want:   []string{"tags max"},
+		},
+		{
+			name:   "rules of nil pointer are skipped",
+			modify: func(o *validationOrder) { o.Note = nil },
+		},
+		{
+			name:   "min of pointer to string",
+			modify: func(o *validationOrder) { o.Note = &short },
+			want:   []string{"note min"},
+		},
+		{
+			name: "nested slice paths",
+			modify: func(o *validationOrder) {
+				o.Items = append(o.Items, validationLine{Name: "", Qty: 0})
+				o.Lines = []*validationLine{nil, {Name: "abcde", Qty: 1}}
+				o.Extra = &validationLine{Qty: 1}
+			},
+			want: []string{"items.1.name required", "items.1.qty min", "lines.1.name max", "extra.name required"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := validOrder()
+			c.modify(o)
+			err := ValidateStruct(o)
+			if len(c.want) <= 0 {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("err = %v, want ErrValidation", err)
+			}
+			var errorList ValidationErrors
+			if !errors.As(err, &errorList) {
+				t.Fatalf("err = %T, want ValidationErrors", err)
+			}
+			got := make([]string, 0, len(errorList))
+			for _, eachError := range errorList {
+				got = append(got, eachError.Field+" "+eachError.Rule)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("errors = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseValidationTag(t *testing.T) {
+	cases := []struct {
+		name    string
+		tag     string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "empty",
+			tag:  "",
+			want: []string{},
+		},
+		{
+			name: "rules with spaces",
+			tag:  "required, min=1 ,max=2,",
+			want: []string{"required:", "min:1", "max:2"},
+		},
+		{
+			name: "regex is the last rule",
+			tag:  "len=5,regex=^[a-z]{1,3},[0-9]$",
+			want: []string{"len:5", "regex:^[a-z]{1,3},[0-9]$"},
+		},
+		{
+			name: "regex after space",
+			tag:  "required, regex=^a{1,2}$",
+			want: []string{"required:", "regex:^a{1,2}$"},
+		},
+		{
+			name: "enum",
+			tag:  "enum=a|b|c",
+			want: []string{"enum:a|b|c"},
+		},
+		{
+			name:    "invalid number",
+			tag:     "min=one",
+			wantErr: "invalid min value one",
+		},
+		{
+			name:    "invalid regex",
+			tag:     "regex=[a-",
+			wantErr: "invalid regex",
+		},
+		{
+			name:    "unknown rule",
+			tag:     "email",
+			wantErr: "unknown validate rule email",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ruleList, err := parseValidationTag(c.tag)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v, want %s", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(ruleList))
+			for _, eachRule := range ruleList {
+				got = append(got, eachRule.name+":"+eachRule.param)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("rules = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateMany(t *testing.T) {
+	invalid := validOrder()
+	invalid.Status = "deleted"
+	err := ValidateMany([]interface{}{validOrder(), invalid, nil})
+	var multiError *MultiValidationError
+	if !errors.As(err, &multiError) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("err = %v, want *MultiValidationError", err)
+	}
+	if len(multiError.Errors) != 1 || multiError.Errors[0].Index != 1 {
+		t.Errorf("errors = %v, want error of item 1", multiError.Errors)
+	}
+}
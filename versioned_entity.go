@@ -0,0 +1,53 @@
+package mongodbr
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	FieldVersion = "version"
+)
+
+type IVersionedEntity interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+var _ IVersionedEntity = (*VersionedEntity)(nil)
+
+// entity with version field, used by optimistic concurrency.
+// embed it inline next to Entity or AuditedEntity,
+// update and replace paths will only match the expected version and increment it
+type VersionedEntity struct {
+	Version int64 `json:"version" bson:"version"`
+}
+
+// #region IVersionedEntity Members
+
+func (e *VersionedEntity) GetVersion() int64 {
+	return e.Version
+}
+
+func (e *VersionedEntity) SetVersion(version int64) {
+	e.Version = version
+}
+
+// #endregion
+
+// build the filter element matching the expected version,
+// version 0 also matches documents which have no version field
+func VersionFilter(version int64) bson.E {
+	if version == 0 {
+		return bson.E{Key: FieldVersion, Value: bson.D{{Key: "$in", Value: bson.A{int64(0), nil}}}}
+	}
+	return bson.E{Key: FieldVersion, Value: version}
+}
+
+// combine filter with expected version filter
+func withVersionFilter(filter interface{}, version int64) interface{} {
+	versionD := bson.D{VersionFilter(version)}
+	if filter == nil {
+		return versionD
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, versionD}}}
+}